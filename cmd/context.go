@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 
@@ -19,24 +20,28 @@ import (
 	"github.com/idebeijer/kubert/internal/fzf"
 	"github.com/idebeijer/kubert/internal/kubeconfig"
 	"github.com/idebeijer/kubert/internal/kubert"
+	"github.com/idebeijer/kubert/internal/protection"
 	"github.com/idebeijer/kubert/internal/state"
+	"github.com/idebeijer/kubert/internal/tool"
 )
 
 type ContextOptions struct {
 	Out    io.Writer
 	ErrOut io.Writer
 
-	Args   []string
-	Nested bool
+	Args     []string
+	Nested   bool
+	Readonly bool
 
 	Config         config.Config
+	Aliases        *kubeconfig.Aliases
 	ContextLoader  func() ([]kubeconfig.Context, error)
 	StateManager   func() (*state.Manager, error)
 	Selector       func([]string) (string, error)
 	IsInteractive  func() bool
-	ShellLauncher  func(kubeconfigPath, originalPath, contextName string, cfg config.Config) error
-	TempFileWriter func(kubeconfigPath, contextName, namespace string) (*os.File, func(), error)
-	InPlaceWriter  func(kubeconfigPath, contextName, namespace, targetPath string) error
+	ShellLauncher  func(kubeconfigPath, originalPath, contextName string, readonly bool, cfg config.Config) error
+	TempFileWriter func(ctx kubeconfig.Context, namespace string, readonly *config.ReadonlyIdentity) (*os.File, func(), error)
+	InPlaceWriter  func(ctx kubeconfig.Context, namespace, targetPath string, readonly *config.ReadonlyIdentity) error
 }
 
 func NewContextOptions() *ContextOptions {
@@ -46,15 +51,19 @@ func NewContextOptions() *ContextOptions {
 
 		ContextLoader: func() ([]kubeconfig.Context, error) {
 			cfg := config.Cfg
-			fsProvider := kubeconfig.NewFileSystemProvider(cfg.KubeconfigPaths.Include, cfg.KubeconfigPaths.Exclude)
-			loader := kubeconfig.NewLoader(kubeconfig.WithProvider(fsProvider))
+			loader, err := kubeconfig.NewLoaderFromConfig(cfg)
+			if err != nil {
+				return nil, err
+			}
 			return loader.LoadContexts()
 		},
 		StateManager:  state.NewManager,
 		Selector:      fzf.Select,
 		IsInteractive: fzf.IsInteractive,
-		ShellLauncher: func(kubeconfigPath, originalPath, contextName string, cfg config.Config) error {
-			return launchShellWithKubeconfig(kubeconfigPath, originalPath, contextName, cfg)
+		ShellLauncher: func(kubeconfigPath, originalPath, contextName string, readonly bool, cfg config.Config) error {
+			opts := DefaultShellOptions()
+			opts.Readonly = readonly
+			return launchShellWithKubeconfig(kubeconfigPath, originalPath, contextName, cfg, opts)
 		},
 		TempFileWriter: createTempKubeconfigFile,
 		InPlaceWriter:  writeContextToExistingFile,
@@ -70,7 +79,12 @@ func NewContextCommand() *cobra.Command {
 		Long: `Start a shell with the KUBECONFIG environment variable set to the selected context.
 Kubert will issue a temporary kubeconfig file with the selected context, so that multiple shells can be spawned with different contexts.
 
-Use '-' to switch to the previously selected context.`,
+Use '-' to switch to the previously selected context.
+
+Use --readonly to start a shell whose kubeconfig impersonates the read-only user or groups
+configured for the context under protection.readonly, so every request from the shell, also
+from plain kubectl, is read-only at the API server. Switching contexts in a read-only shell
+keeps it read-only.`,
 		Example: `  # Select a context interactively
   kubert ctx
 
@@ -78,7 +92,10 @@ Use '-' to switch to the previously selected context.`,
   kubert ctx my-cluster
 
   # Switch to the previously selected context
-  kubert ctx -`,
+  kubert ctx -
+
+  # Start a read-only shell
+  kubert ctx --readonly prod-eu`,
 		Aliases:           []string{"context"},
 		SilenceUsage:      true,
 		ValidArgsFunction: validContextArgsFunction,
@@ -94,6 +111,7 @@ Use '-' to switch to the previously selected context.`,
 	}
 
 	cmd.Flags().BoolVar(&o.Nested, "nested", false, "spawn a nested sub-shell instead of switching context in-place")
+	cmd.Flags().BoolVar(&o.Readonly, "readonly", false, "impersonate the read-only identity configured for the context, always spawns a new shell unless the current one is read-only")
 
 	return cmd
 }
@@ -104,6 +122,12 @@ func (o *ContextOptions) Complete(cmd *cobra.Command, args []string) error {
 	o.Args = args
 	o.Config = config.Cfg
 	o.Nested = o.Nested || o.Config.Nested
+
+	aliases, err := kubeconfig.NewAliases(o.Config.Aliases)
+	if err != nil {
+		return err
+	}
+	o.Aliases = aliases
 	return nil
 }
 
@@ -122,29 +146,50 @@ func (o *ContextOptions) Run() error {
 		return fmt.Errorf("error loading contexts: %w", err)
 	}
 	slog.Debug("Contexts loaded", "count", len(contexts))
+	if err := protection.InheritRenamed(sm, contexts); err != nil {
+		return fmt.Errorf("error keeping protection of renamed contexts: %w", err)
+	}
 
-	contextNames := getContextNames(contexts)
+	contextNames := getDisplayNames(contexts, o.Aliases)
 	sort.Strings(contextNames)
 
-	selectedContextName, err := o.selectContextName(contextNames, sm)
+	selectedName, err := o.selectContextName(contextNames, sm)
 	if err != nil {
 		return err
 	}
-	if selectedContextName == "" {
+	if selectedName == "" {
 		return nil
 	}
 
-	selectedContext, found := findContextByName(contexts, selectedContextName)
-	if !found {
-		return fmt.Errorf("context %s not found", selectedContextName)
+	selectedContext, err := o.Aliases.Find(contexts, selectedName)
+	if err != nil {
+		return err
+	}
+	selectedContextName := selectedContext.Name
+
+	inPlace := os.Getenv(kubert.ShellActiveEnvVar) == "1" && !o.Nested
+	readonlyShell := os.Getenv(kubert.ShellReadonlyEnvVar) == "1"
+	readonly := o.Readonly || (inPlace && readonlyShell)
+	// The environment of the running shell cannot be changed from here, so a shell only
+	// becomes read-only when it is started.
+	if inPlace && readonly && !readonlyShell {
+		inPlace = false
+	}
+
+	var identity *config.ReadonlyIdentity
+	if readonly {
+		identity, err = o.readonlyIdentity(sm, selectedContextName)
+		if err != nil {
+			return err
+		}
 	}
 
-	if os.Getenv(kubert.ShellActiveEnvVar) == "1" && !o.Nested {
-		return o.switchContextInPlace(sm, selectedContextName, selectedContext)
+	if inPlace {
+		return o.switchContextInPlace(sm, selectedContextName, selectedContext, identity)
 	}
 
 	contextInState, _ := sm.ContextInfo(selectedContextName)
-	tempKubeconfig, cleanup, err := o.TempFileWriter(selectedContext.FilePath, selectedContextName, contextInState.LastNamespace)
+	tempKubeconfig, cleanup, err := o.TempFileWriter(selectedContext, contextInState.LastNamespace, identity)
 	if err != nil {
 		return err
 	}
@@ -156,7 +201,20 @@ func (o *ContextOptions) Run() error {
 		slog.Warn("Failed to save last context", "error", err)
 	}
 
-	return o.ShellLauncher(tempKubeconfig.Name(), selectedContext.FilePath, selectedContextName, o.Config)
+	return o.ShellLauncher(tempKubeconfig.Name(), selectedContext.FilePath, selectedContextName, readonly, o.Config)
+}
+
+// readonlyIdentity returns the identity a read-only shell of the context impersonates.
+func (o *ContextOptions) readonlyIdentity(sm *state.Manager, contextName string) (*config.ReadonlyIdentity, error) {
+	engine, err := protection.NewEngine(o.Config, sm)
+	if err != nil {
+		return nil, err
+	}
+	identity, ok := engine.ReadonlyIdentity(contextName)
+	if !ok {
+		return nil, fmt.Errorf("no read-only identity is configured for context %q, add one to protection.readonly in the config", contextName)
+	}
+	return &identity, nil
 }
 
 func validateManagedKubeconfigPath(path string) error {
@@ -175,7 +233,7 @@ func validateManagedKubeconfigPath(path string) error {
 	return nil
 }
 
-func (o *ContextOptions) switchContextInPlace(sm *state.Manager, contextName string, ctx kubeconfig.Context) error {
+func (o *ContextOptions) switchContextInPlace(sm *state.Manager, contextName string, ctx kubeconfig.Context, readonly *config.ReadonlyIdentity) error {
 	existingKubeconfigPath := os.Getenv(kubert.ShellKubeconfigEnvVar)
 	if existingKubeconfigPath == "" {
 		return fmt.Errorf("KUBERT_SHELL_KUBECONFIG not set; cannot switch context in-place")
@@ -225,7 +283,7 @@ func (o *ContextOptions) switchContextInPlace(sm *state.Manager, contextName str
 		}
 	}
 
-	if err := o.InPlaceWriter(ctx.FilePath, contextName, contextInState.LastNamespace, existingKubeconfigPath); err != nil {
+	if err := o.InPlaceWriter(ctx, contextInState.LastNamespace, existingKubeconfigPath, readonly); err != nil {
 		return fmt.Errorf("failed to update kubeconfig: %w", err)
 	}
 
@@ -282,32 +340,33 @@ func (o *ContextOptions) printContextNames(contextNames []string) {
 	}
 }
 
-func getContextNames(contexts []kubeconfig.Context) []string {
+// getDisplayNames returns the names to show for the contexts: their alias if they have one.
+func getDisplayNames(contexts []kubeconfig.Context, aliases *kubeconfig.Aliases) []string {
 	names := make([]string, 0, len(contexts))
 	for _, context := range contexts {
-		names = append(names, context.Name)
+		names = append(names, aliases.DisplayName(context.Name))
 	}
 	return names
 }
 
-func findContextByName(contexts []kubeconfig.Context, name string) (kubeconfig.Context, bool) {
-	for _, context := range contexts {
-		if context.Name == name {
-			return context, true
+// buildKubeconfigForContext returns a kubeconfig with only the selected context, named as
+// kubert names it. With a read-only identity, the user of the context impersonates it.
+func buildKubeconfigForContext(ctx kubeconfig.Context, namespace string, readonly *config.ReadonlyIdentity) (*api.Config, error) {
+	// Encrypted kubeconfigs are decrypted in memory, the temp kubeconfig is the only plaintext copy.
+	// Providers that are not cached only keep their kubeconfigs in memory.
+	cfg := ctx.Config
+	if cfg == nil {
+		loaded, err := kubeconfig.LoadFile(ctx.FilePath)
+		if err != nil {
+			return nil, err
 		}
-	}
-	return kubeconfig.Context{}, false
-}
-
-func buildKubeconfigForContext(kubeconfigPath, selectedContextName, namespace string) (*api.Config, error) {
-	cfg, err := clientcmd.LoadFromFile(kubeconfigPath)
-	if err != nil {
-		return nil, err
+		cfg = loaded.Config
 	}
 
-	selectedContext := cfg.Contexts[selectedContextName]
+	selectedContextName := ctx.Name
+	selectedContext := cfg.Contexts[ctx.KubeconfigName()]
 	if selectedContext == nil {
-		return nil, fmt.Errorf("context %s not found in kubeconfig", selectedContextName)
+		return nil, fmt.Errorf("context %s not found in kubeconfig", ctx.KubeconfigName())
 	}
 	selectedCluster := cfg.Clusters[selectedContext.Cluster]
 	if selectedCluster == nil {
@@ -319,9 +378,19 @@ func buildKubeconfigForContext(kubeconfigPath, selectedContextName, namespace st
 	}
 
 	newConfig := api.NewConfig()
-	newConfig.Contexts[selectedContextName] = selectedContext
+	// The namespace is set on a copy, so ctx.Config is not modified.
+	contextCopy := *selectedContext
+	newConfig.Contexts[selectedContextName] = &contextCopy
 	newConfig.Clusters[selectedContext.Cluster] = selectedCluster
 	newConfig.AuthInfos[selectedContext.AuthInfo] = selectedAuthInfo
+	if readonly != nil {
+		authInfo := *selectedAuthInfo
+		authInfo.Impersonate = readonly.User
+		authInfo.ImpersonateGroups = readonly.Groups
+		authInfo.ImpersonateUID = ""
+		authInfo.ImpersonateUserExtra = nil
+		newConfig.AuthInfos[selectedContext.AuthInfo] = &authInfo
+	}
 	newConfig.CurrentContext = selectedContextName
 	if namespace != "" {
 		newConfig.Contexts[selectedContextName].Namespace = namespace
@@ -330,8 +399,8 @@ func buildKubeconfigForContext(kubeconfigPath, selectedContextName, namespace st
 	return newConfig, nil
 }
 
-func createTempKubeconfigFile(kubeconfigPath, selectedContextName, namespace string) (*os.File, func(), error) {
-	newConfig, err := buildKubeconfigForContext(kubeconfigPath, selectedContextName, namespace)
+func createTempKubeconfigFile(ctx kubeconfig.Context, namespace string, readonly *config.ReadonlyIdentity) (*os.File, func(), error) {
+	newConfig, err := buildKubeconfigForContext(ctx, namespace, readonly)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -360,8 +429,8 @@ func createTempKubeconfigFile(kubeconfigPath, selectedContextName, namespace str
 	return tempKubeconfig, cleanup, nil
 }
 
-func writeContextToExistingFile(kubeconfigPath, selectedContextName, namespace, targetPath string) error {
-	newConfig, err := buildKubeconfigForContext(kubeconfigPath, selectedContextName, namespace)
+func writeContextToExistingFile(ctx kubeconfig.Context, namespace, targetPath string, readonly *config.ReadonlyIdentity) error {
+	newConfig, err := buildKubeconfigForContext(ctx, namespace, readonly)
 	if err != nil {
 		return err
 	}
@@ -395,6 +464,9 @@ type ShellOptions struct {
 	Stdin  io.Reader
 	Stdout io.Writer
 	Stderr io.Writer
+
+	// Readonly marks the shell as read-only in its environment.
+	Readonly bool
 }
 
 func DefaultShellOptions() ShellOptions {
@@ -412,9 +484,21 @@ func launchShellWithKubeconfig(kubeconfigPath, originalKubeconfigPath, contextNa
 	}
 
 	env := os.Environ()
+	// A nested shell inherits the KUBECONFIG of the user from its parent shell.
+	if os.Getenv(kubert.ShellActiveEnvVar) != "1" {
+		env = append(env, kubert.OriginalKubeconfigEnvVar+"="+os.Getenv("KUBECONFIG"))
+	}
 	env = append(env, "KUBECONFIG="+kubeconfigPath)
 	env = append(env, kubert.ShellActiveEnvVar+"=1")
 	env = append(env, kubert.ShellKubeconfigEnvVar+"="+kubeconfigPath)
+	if opt.Readonly {
+		env = append(env, kubert.ShellReadonlyEnvVar+"=1")
+	} else {
+		// A shell started from a read-only shell is not read-only itself.
+		env = slices.DeleteFunc(env, func(e string) bool {
+			return strings.HasPrefix(e, kubert.ShellReadonlyEnvVar+"=")
+		})
+	}
 	// Only set KUBERT_SHELL_CONTEXT and KUBERT_SHELL_ORIGINAL_KUBECONFIG when the
 	// shell function is active. Without it there is no mechanism to update these vars
 	// after in-place switches, so a stale value is worse than no value.
@@ -426,6 +510,12 @@ func launchShellWithKubeconfig(kubeconfigPath, originalKubeconfigPath, contextNa
 	statefile, _ := state.FilePath()
 	env = append(env, kubert.ShellStateFilePathEnvVar+"="+statefile)
 
+	if path, err := shimPath(cfg); err != nil {
+		slog.Warn("Failed to install shims", "error", err)
+	} else if path != "" {
+		env = append(env, "PATH="+path)
+	}
+
 	// Execute pre-shell hook if configured
 	if cfg.Hooks.PreShell != "" {
 		if err := executeHook(cfg.Hooks.PreShell, "pre-shell",
@@ -436,6 +526,10 @@ func launchShellWithKubeconfig(kubeconfigPath, originalKubeconfigPath, contextNa
 		}
 	}
 
+	if opt.Readonly {
+		fmt.Fprintf(opt.Stderr, "Read-only shell for context %q: requests impersonate its read-only identity until you exit the shell.\n", contextName)
+	}
+
 	// Launch the shell with the current environment, including the modified KUBECONFIG
 	shellCmd := exec.Command(getUserShell())
 	shellCmd.Env = env
@@ -465,6 +559,34 @@ func launchShellWithKubeconfig(kubeconfigPath, originalKubeconfigPath, contextNa
 	return nil
 }
 
+// shimPath installs the shims configured in protection.shims and returns the PATH of a kubert
+// shell with the shim directory in front. It returns an empty string when shims are disabled.
+func shimPath(cfg config.Config) (string, error) {
+	if !cfg.Protection.Shims.Enabled {
+		return "", nil
+	}
+
+	kubertPath, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+
+	shims := map[string][]string{kubectlBin: {"kubectl"}}
+	for _, name := range cfg.Protection.Shims.Tools {
+		t, err := tool.Lookup(cfg, name)
+		if err != nil {
+			return "", err
+		}
+		shims[filepath.Base(t.Binary)] = []string{"wrap", name}
+	}
+
+	dir := kubert.ShimDir()
+	if err := kubert.InstallShims(dir, kubertPath, shims); err != nil {
+		return "", err
+	}
+	return kubert.PrependPath(os.Getenv("PATH"), dir), nil
+}
+
 func executeHook(hookCommand, hookType string, extraEnv ...string) error {
 	hookCmd := exec.Command(getUserShell(), "-c", hookCommand)
 	env := os.Environ()
@@ -482,15 +604,25 @@ func executeHook(hookCommand, hookType string, extraEnv ...string) error {
 
 func validContextArgsFunction(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	cfg := config.Cfg
-	fsProvider := kubeconfig.NewFileSystemProvider(cfg.KubeconfigPaths.Include, cfg.KubeconfigPaths.Exclude)
-	loader := kubeconfig.NewLoader(kubeconfig.WithProvider(fsProvider))
+	loader, err := kubeconfig.NewLoaderFromConfig(cfg)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
 
 	contexts, err := loader.LoadContexts()
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveError
 	}
 
-	contextNames := getContextNames(contexts)
+	// Completion should not fail on a broken alias config, fall back to the context names.
+	aliases, _ := kubeconfig.NewAliases(cfg.Aliases)
+	contextNames := getDisplayNames(contexts, aliases)
+	// Contexts that do not come from the kubeconfig files are described with their provider.
+	for i, context := range contexts {
+		if context.Provider != kubeconfig.LocalProviderName {
+			contextNames[i] += "\t" + context.Provider
+		}
+	}
 	sort.Strings(contextNames)
 
 	return contextNames, cobra.ShellCompDirectiveNoFileComp
@@ -3,6 +3,7 @@ package cmd
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -91,7 +92,7 @@ func TestCreateTempKubeconfigFile_Isolation(t *testing.T) {
 	}
 
 	// Now try to isolate "ctx-2"
-	isolatedFile, cleanup, err := createTempKubeconfigFile(tempFile.Name(), "ctx-2", "")
+	isolatedFile, cleanup, err := createTempKubeconfigFile(kubeconfig.Context{Name: "ctx-2", WithPath: kubeconfig.WithPath{FilePath: tempFile.Name()}}, "", nil)
 	if err != nil {
 		t.Fatalf("createTempKubeconfigFile failed: %v", err)
 	}
@@ -221,6 +222,103 @@ func TestLaunchShellWithKubeconfig(t *testing.T) {
 	}
 }
 
+func TestShimPath(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	xdg.Reload()
+	t.Cleanup(xdg.Reload)
+	t.Setenv("PATH", "/usr/bin")
+
+	path, err := shimPath(config.Config{})
+	if err != nil || path != "" {
+		t.Fatalf("shimPath() = (%q, %v), want no shims when disabled", path, err)
+	}
+
+	cfg := config.Config{Protection: config.Protection{Shims: config.ProtectionShims{Enabled: true, Tools: []string{"helm"}}}}
+	path, err = shimPath(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != kubert.ShimDir()+string(os.PathListSeparator)+"/usr/bin" {
+		t.Errorf("shimPath() = %q, want the shim directory in front", path)
+	}
+	for _, name := range []string{"kubectl", "helm"} {
+		if _, err := os.Stat(filepath.Join(kubert.ShimDir(), name)); err != nil {
+			t.Errorf("Expected a %s shim: %v", name, err)
+		}
+	}
+
+	cfg.Protection.Shims.Tools = []string{"terraform"}
+	if _, err := shimPath(cfg); err == nil {
+		t.Error("Expected an error for an unknown tool")
+	}
+}
+
+func TestLaunchShellWithKubeconfig_ReadonlyNotice(t *testing.T) {
+	shell, err := exec.LookPath("true")
+	if err != nil {
+		t.Skip("true not found")
+	}
+	t.Setenv("SHELL", shell)
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	xdg.Reload()
+	t.Cleanup(xdg.Reload)
+
+	for _, readonly := range []bool{false, true} {
+		var stderr bytes.Buffer
+		opts := ShellOptions{Stdin: strings.NewReader(""), Stdout: io.Discard, Stderr: &stderr, Readonly: readonly}
+		if err := launchShellWithKubeconfig("kubeconfig", "original", "prod", config.Config{}, opts); err != nil {
+			t.Fatalf("launchShellWithKubeconfig() error = %v", err)
+		}
+		if got := strings.Contains(stderr.String(), `Read-only shell for context "prod"`); got != readonly {
+			t.Errorf("readonly %v: stderr = %q", readonly, stderr.String())
+		}
+	}
+}
+
+func TestLaunchShellWithKubeconfig_OriginalKubeconfig(t *testing.T) {
+	shell := filepath.Join(t.TempDir(), "shell.sh")
+	if err := os.WriteFile(shell, []byte("#!/bin/sh\necho \"$"+kubert.OriginalKubeconfigEnvVar+"\"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SHELL", shell)
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	xdg.Reload()
+	t.Cleanup(xdg.Reload)
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{
+			name: "outside a kubert shell",
+			env:  map[string]string{"KUBECONFIG": "/home/user/.kube/config", kubert.ShellActiveEnvVar: "", kubert.OriginalKubeconfigEnvVar: ""},
+			want: "/home/user/.kube/config",
+		},
+		{
+			name: "nested in a kubert shell",
+			env:  map[string]string{"KUBECONFIG": "/tmp/kubert-shell.yaml", kubert.ShellActiveEnvVar: "1", kubert.OriginalKubeconfigEnvVar: "/home/user/.kube/config"},
+			want: "/home/user/.kube/config",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			var stdout bytes.Buffer
+			opts := ShellOptions{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: io.Discard}
+			if err := launchShellWithKubeconfig("kubeconfig", "original", "prod", config.Config{}, opts); err != nil {
+				t.Fatalf("launchShellWithKubeconfig() error = %v", err)
+			}
+			if got := strings.TrimSpace(stdout.String()); got != tt.want {
+				t.Errorf("%s = %q, want %q", kubert.OriginalKubeconfigEnvVar, got, tt.want)
+			}
+		})
+	}
+}
+
 // Note: This test is experimental and more of an integration test which may be flaky and should
 // be run inside the ./testdata/Dockerfile container.
 // Running locally would require all shells to be installed.
@@ -378,14 +476,14 @@ func TestContextOptions_Run_WithContextName(t *testing.T) {
 		IsInteractive: func() bool {
 			return true
 		},
-		ShellLauncher: func(kubeconfigPath, originalPath, contextName string, cfg config.Config) error {
+		ShellLauncher: func(kubeconfigPath, originalPath, contextName string, _ bool, cfg config.Config) error {
 			shellLauncherCalled = true
 			if contextName != "test-cluster" {
 				t.Errorf("Expected context name 'test-cluster', got '%s'", contextName)
 			}
 			return nil
 		},
-		TempFileWriter: func(kubeconfigPath, contextName, namespace string) (*os.File, func(), error) {
+		TempFileWriter: func(_ kubeconfig.Context, namespace string, _ *config.ReadonlyIdentity) (*os.File, func(), error) {
 			tempFileCreated = true
 			tempFile, _ := os.CreateTemp("", "test-*.yaml")
 			cleanup := func() {
@@ -410,6 +508,65 @@ func TestContextOptions_Run_WithContextName(t *testing.T) {
 	}
 }
 
+func TestContextOptions_Run_Alias(t *testing.T) {
+	const fullName = "arn:aws:eks:eu-west-1:1234:cluster/prod-a"
+	aliases, err := kubeconfig.NewAliases(config.Aliases{Contexts: []config.ContextAlias{{Name: fullName, Alias: "prod-a"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var launched, selectorItems []string
+	for _, args := range [][]string{{"prod-a"}, nil} {
+		o := &ContextOptions{
+			Out:     &bytes.Buffer{},
+			ErrOut:  &bytes.Buffer{},
+			Args:    args,
+			Config:  config.Config{},
+			Aliases: aliases,
+			ContextLoader: func() ([]kubeconfig.Context, error) {
+				return []kubeconfig.Context{
+					{Name: fullName, WithPath: kubeconfig.WithPath{FilePath: "/tmp/config"}},
+					{Name: "kind-dev", WithPath: kubeconfig.WithPath{FilePath: "/tmp/config"}},
+				}, nil
+			},
+			StateManager: func() (*state.Manager, error) {
+				setupTestXDGDataHome(t)
+				return state.NewManager()
+			},
+			Selector: func(items []string) (string, error) {
+				selectorItems = items
+				return "prod-a", nil
+			},
+			IsInteractive: func() bool { return true },
+			ShellLauncher: func(_, _, contextName string, _ bool, _ config.Config) error {
+				launched = append(launched, contextName)
+				return nil
+			},
+			TempFileWriter: func(ctx kubeconfig.Context, _ string, _ *config.ReadonlyIdentity) (*os.File, func(), error) {
+				if ctx.Name != fullName {
+					t.Errorf("TempFileWriter received context %q, want %q", ctx.Name, fullName)
+				}
+				f, err := os.CreateTemp("", "test-*.yaml")
+				if err != nil {
+					return nil, nil, err
+				}
+				return f, func() { _ = os.Remove(f.Name()) }, nil
+			},
+		}
+
+		if err := o.Run(); err != nil {
+			t.Fatalf("Run() with args %v returned unexpected error: %v", args, err)
+		}
+	}
+
+	if len(launched) != 2 || launched[0] != fullName || launched[1] != fullName {
+		t.Errorf("launched shells for %v, want the full context name twice", launched)
+	}
+	if len(selectorItems) != 2 || selectorItems[0] != "kind-dev" || selectorItems[1] != "prod-a" {
+		t.Errorf("Selector received %v, want the alias instead of the full name", selectorItems)
+	}
+}
+
 func TestContextOptions_Run_PreviousContext(t *testing.T) {
 	var buf bytes.Buffer
 	shellLauncherCalled := false
@@ -436,14 +593,14 @@ func TestContextOptions_Run_PreviousContext(t *testing.T) {
 		StateManager: func() (*state.Manager, error) {
 			return sm, nil
 		},
-		ShellLauncher: func(kubeconfigPath, originalPath, contextName string, cfg config.Config) error {
+		ShellLauncher: func(kubeconfigPath, originalPath, contextName string, _ bool, cfg config.Config) error {
 			shellLauncherCalled = true
 			if contextName != "previous-cluster" {
 				t.Errorf("Expected context name 'previous-cluster', got '%s'", contextName)
 			}
 			return nil
 		},
-		TempFileWriter: func(kubeconfigPath, contextName, namespace string) (*os.File, func(), error) {
+		TempFileWriter: func(_ kubeconfig.Context, namespace string, _ *config.ReadonlyIdentity) (*os.File, func(), error) {
 			tempFile, _ := os.CreateTemp("", "test-*.yaml")
 			cleanup := func() {
 				_ = tempFile.Close()
@@ -525,11 +682,11 @@ func TestContextOptions_Run_InteractiveSelection(t *testing.T) {
 		IsInteractive: func() bool {
 			return true
 		},
-		ShellLauncher: func(kubeconfigPath, originalPath, contextName string, cfg config.Config) error {
+		ShellLauncher: func(kubeconfigPath, originalPath, contextName string, _ bool, cfg config.Config) error {
 			shellLauncherCalled = true
 			return nil
 		},
-		TempFileWriter: func(kubeconfigPath, contextName, namespace string) (*os.File, func(), error) {
+		TempFileWriter: func(_ kubeconfig.Context, namespace string, _ *config.ReadonlyIdentity) (*os.File, func(), error) {
 			tempFile, _ := os.CreateTemp("", "test-*.yaml")
 			cleanup := func() {
 				_ = tempFile.Close()
@@ -578,7 +735,7 @@ func TestContextOptions_Run_NonInteractivePrintOnly(t *testing.T) {
 		IsInteractive: func() bool {
 			return false
 		},
-		ShellLauncher: func(kubeconfigPath, originalPath, contextName string, cfg config.Config) error {
+		ShellLauncher: func(kubeconfigPath, originalPath, contextName string, _ bool, cfg config.Config) error {
 			t.Error("ShellLauncher should not be called when printing only")
 			return nil
 		},
@@ -673,52 +830,27 @@ func TestContextOptions_Run_StateManagerError(t *testing.T) {
 	}
 }
 
-func TestGetContextNames(t *testing.T) {
+func TestGetDisplayNames(t *testing.T) {
 	contexts := []kubeconfig.Context{
 		{Name: "cluster-1"},
-		{Name: "cluster-2"},
-		{Name: "cluster-3"},
+		{Name: "arn:aws:eks:eu-west-1:1234:cluster/prod-a"},
 	}
 
-	names := getContextNames(contexts)
-
-	if len(names) != 3 {
-		t.Errorf("Expected 3 names, got %d", len(names))
+	names := getDisplayNames(contexts, nil)
+	if len(names) != 2 || names[0] != "cluster-1" || names[1] != "arn:aws:eks:eu-west-1:1234:cluster/prod-a" {
+		t.Errorf("getDisplayNames() without aliases = %v, want the context names", names)
 	}
 
-	expectedNames := map[string]bool{"cluster-1": true, "cluster-2": true, "cluster-3": true}
-	for _, name := range names {
-		if !expectedNames[name] {
-			t.Errorf("Unexpected name: %s", name)
-		}
+	aliases, err := kubeconfig.NewAliases(config.Aliases{Rewrites: []config.AliasRewrite{
+		{Regex: `^arn:aws:eks:[^:]+:\d+:cluster/(.+)$`, Replacement: "$1"},
+	}})
+	if err != nil {
+		t.Fatal(err)
 	}
-}
-
-func TestFindContextByName(t *testing.T) {
-	contexts := []kubeconfig.Context{
-		{Name: "cluster-1", WithPath: kubeconfig.WithPath{FilePath: "/tmp/config1"}},
-		{Name: "cluster-2", WithPath: kubeconfig.WithPath{FilePath: "/tmp/config2"}},
+	names = getDisplayNames(contexts, aliases)
+	if len(names) != 2 || names[0] != "cluster-1" || names[1] != "prod-a" {
+		t.Errorf("getDisplayNames() = %v, want [cluster-1 prod-a]", names)
 	}
-
-	t.Run("context found", func(t *testing.T) {
-		ctx, found := findContextByName(contexts, "cluster-1")
-		if !found {
-			t.Error("Expected to find cluster-1")
-		}
-		if ctx.Name != "cluster-1" {
-			t.Errorf("Expected name 'cluster-1', got '%s'", ctx.Name)
-		}
-		if ctx.FilePath != "/tmp/config1" {
-			t.Errorf("Expected path '/tmp/config1', got '%s'", ctx.FilePath)
-		}
-	})
-
-	t.Run("context not found", func(t *testing.T) {
-		_, found := findContextByName(contexts, "nonexistent")
-		if found {
-			t.Error("Should not find nonexistent context")
-		}
-	})
 }
 
 func TestContextOptions_Run_WarningSuppressedAfterMax(t *testing.T) {
@@ -751,11 +883,13 @@ func TestContextOptions_Run_WarningSuppressedAfterMax(t *testing.T) {
 					{Name: "ctx-b", WithPath: kubeconfig.WithPath{FilePath: "/tmp/config"}},
 				}, nil
 			},
-			StateManager:   func() (*state.Manager, error) { return sm, nil },
-			IsInteractive:  func() bool { return false },
-			ShellLauncher:  func(_, _, _ string, _ config.Config) error { return nil },
-			TempFileWriter: func(_, _, _ string) (*os.File, func(), error) { return nil, nil, nil },
-			InPlaceWriter:  func(_, _, _, _ string) error { return nil },
+			StateManager:  func() (*state.Manager, error) { return sm, nil },
+			IsInteractive: func() bool { return false },
+			ShellLauncher: func(_, _, _ string, _ bool, _ config.Config) error { return nil },
+			TempFileWriter: func(_ kubeconfig.Context, _ string, _ *config.ReadonlyIdentity) (*os.File, func(), error) {
+				return nil, nil, nil
+			},
+			InPlaceWriter: func(_ kubeconfig.Context, _, _ string, _ *config.ReadonlyIdentity) error { return nil },
 		}
 	}
 
@@ -813,18 +947,18 @@ func TestContextOptions_Run_InPlaceSwitch(t *testing.T) {
 			return state.NewManager()
 		},
 		IsInteractive: func() bool { return false },
-		ShellLauncher: func(_, _, _ string, _ config.Config) error {
+		ShellLauncher: func(_, _, _ string, _ bool, _ config.Config) error {
 			shellLauncherCalled = true
 			return nil
 		},
-		TempFileWriter: func(_, _, _ string) (*os.File, func(), error) {
+		TempFileWriter: func(_ kubeconfig.Context, _ string, _ *config.ReadonlyIdentity) (*os.File, func(), error) {
 			t.Error("TempFileWriter should not be called for in-place switch")
 			return nil, nil, nil
 		},
-		InPlaceWriter: func(_, contextName, _, targetPath string) error {
+		InPlaceWriter: func(ctx kubeconfig.Context, _, targetPath string, _ *config.ReadonlyIdentity) error {
 			inPlaceWriterCalled = true
-			if contextName != "ctx-b" {
-				t.Errorf("Expected context name 'ctx-b', got '%s'", contextName)
+			if ctx.Name != "ctx-b" {
+				t.Errorf("Expected context name 'ctx-b', got '%s'", ctx.Name)
 			}
 			if targetPath != existingKubeconfig.Name() {
 				t.Errorf("Expected target path %q, got %q", existingKubeconfig.Name(), targetPath)
@@ -876,18 +1010,18 @@ func TestContextOptions_Run_InPlaceSwitch_Nested(t *testing.T) {
 			return state.NewManager()
 		},
 		IsInteractive: func() bool { return false },
-		ShellLauncher: func(_, _, _ string, _ config.Config) error {
+		ShellLauncher: func(_, _, _ string, _ bool, _ config.Config) error {
 			shellLauncherCalled = true
 			return nil
 		},
-		TempFileWriter: func(_, _, _ string) (*os.File, func(), error) {
+		TempFileWriter: func(_ kubeconfig.Context, _ string, _ *config.ReadonlyIdentity) (*os.File, func(), error) {
 			f, err := os.CreateTemp("", "test-*.yaml")
 			if err != nil {
 				return nil, nil, err
 			}
 			return f, func() { _ = os.Remove(f.Name()) }, nil
 		},
-		InPlaceWriter: func(_, _, _, _ string) error {
+		InPlaceWriter: func(_ kubeconfig.Context, _, _ string, _ *config.ReadonlyIdentity) error {
 			t.Error("InPlaceWriter should not be called when --nested is set")
 			return nil
 		},
@@ -902,6 +1036,207 @@ func TestContextOptions_Run_InPlaceSwitch_Nested(t *testing.T) {
 	}
 }
 
+func TestContextOptions_Run_Readonly(t *testing.T) {
+	cfg := config.Config{Protection: config.Protection{Readonly: []config.ReadonlyIdentity{
+		{Contexts: "^prod-", User: "viewer", Groups: []string{"readonly"}},
+	}}}
+
+	tests := []struct {
+		name          string
+		context       string
+		readonly      bool
+		shellActive   string
+		shellReadonly string
+		wantShell     bool
+		wantInPlace   bool
+		wantIdentity  bool
+		wantErr       string
+	}{
+		{
+			name:         "readonly outside a kubert shell",
+			context:      "prod-eu",
+			readonly:     true,
+			wantShell:    true,
+			wantIdentity: true,
+		},
+		{
+			name:         "readonly from a kubert shell starts a new shell",
+			context:      "prod-eu",
+			readonly:     true,
+			shellActive:  "1",
+			wantShell:    true,
+			wantIdentity: true,
+		},
+		{
+			name:          "switching in a readonly shell stays readonly",
+			context:       "prod-us",
+			shellActive:   "1",
+			shellReadonly: "1",
+			wantInPlace:   true,
+			wantIdentity:  true,
+		},
+		{
+			name:        "no identity for the context",
+			context:     "dev",
+			readonly:    true,
+			shellActive: "1",
+			wantErr:     "no read-only identity",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existingKubeconfig, err := os.CreateTemp("", "kubert-existing-*.yaml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				_ = existingKubeconfig.Close()
+				_ = os.Remove(existingKubeconfig.Name())
+			})
+
+			t.Setenv(kubert.ShellActiveEnvVar, tt.shellActive)
+			t.Setenv(kubert.ShellKubeconfigEnvVar, existingKubeconfig.Name())
+			t.Setenv(kubert.ShellReadonlyEnvVar, tt.shellReadonly)
+
+			var (
+				shellReadonly    bool
+				shellLaunched    bool
+				switchedInPlace  bool
+				receivedIdentity *config.ReadonlyIdentity
+			)
+			o := &ContextOptions{
+				Out:      &bytes.Buffer{},
+				ErrOut:   &bytes.Buffer{},
+				Args:     []string{tt.context},
+				Readonly: tt.readonly,
+				Config:   cfg,
+				ContextLoader: func() ([]kubeconfig.Context, error) {
+					return []kubeconfig.Context{
+						{Name: tt.context, WithPath: kubeconfig.WithPath{FilePath: "/tmp/config"}},
+					}, nil
+				},
+				StateManager: func() (*state.Manager, error) {
+					setupTestXDGDataHome(t)
+					return state.NewManager()
+				},
+				IsInteractive: func() bool { return false },
+				ShellLauncher: func(_, _, _ string, readonly bool, _ config.Config) error {
+					shellLaunched = true
+					shellReadonly = readonly
+					return nil
+				},
+				TempFileWriter: func(_ kubeconfig.Context, _ string, identity *config.ReadonlyIdentity) (*os.File, func(), error) {
+					receivedIdentity = identity
+					f, err := os.CreateTemp("", "test-*.yaml")
+					if err != nil {
+						return nil, nil, err
+					}
+					return f, func() { _ = os.Remove(f.Name()) }, nil
+				},
+				InPlaceWriter: func(_ kubeconfig.Context, _, _ string, identity *config.ReadonlyIdentity) error {
+					switchedInPlace = true
+					receivedIdentity = identity
+					return nil
+				},
+			}
+
+			err = o.Run()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Run() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				if shellLaunched || switchedInPlace {
+					t.Error("no kubeconfig should be written without a read-only identity")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() returned unexpected error: %v", err)
+			}
+
+			if shellLaunched != tt.wantShell || switchedInPlace != tt.wantInPlace {
+				t.Errorf("shell launched = %v, switched in place = %v, want %v and %v", shellLaunched, switchedInPlace, tt.wantShell, tt.wantInPlace)
+			}
+			if tt.wantShell && !shellReadonly {
+				t.Error("ShellLauncher should have been asked for a read-only shell")
+			}
+			if tt.wantIdentity && (receivedIdentity == nil || receivedIdentity.User != "viewer") {
+				t.Errorf("identity = %+v, want the viewer identity", receivedIdentity)
+			}
+		})
+	}
+}
+
+func TestBuildKubeconfigForContext_Readonly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	cfg := api.NewConfig()
+	cfg.Clusters["prod"] = &api.Cluster{Server: "https://prod.example.com"}
+	cfg.AuthInfos["admin"] = &api.AuthInfo{Token: "token", Impersonate: "someone-else"}
+	cfg.Contexts["prod"] = &api.Context{Cluster: "prod", AuthInfo: "admin"}
+	if err := clientcmd.WriteToFile(*cfg, path); err != nil {
+		t.Fatal(err)
+	}
+
+	newConfig, err := buildKubeconfigForContext(kubeconfig.Context{Name: "prod", WithPath: kubeconfig.WithPath{FilePath: path}}, "", &config.ReadonlyIdentity{User: "viewer", Groups: []string{"readonly"}})
+	if err != nil {
+		t.Fatalf("buildKubeconfigForContext() error = %v", err)
+	}
+
+	authInfo := newConfig.AuthInfos["admin"]
+	if authInfo.Token != "token" {
+		t.Errorf("Token = %q, want the credentials of the context to be kept", authInfo.Token)
+	}
+	if authInfo.Impersonate != "viewer" || len(authInfo.ImpersonateGroups) != 1 || authInfo.ImpersonateGroups[0] != "readonly" {
+		t.Errorf("impersonation = %q %v, want viewer [readonly]", authInfo.Impersonate, authInfo.ImpersonateGroups)
+	}
+}
+
+func TestBuildKubeconfigForContext_RenamedDuplicate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dev.yaml")
+	cfg := api.NewConfig()
+	cfg.Clusters["kind"] = &api.Cluster{Server: "https://127.0.0.1:6443"}
+	cfg.AuthInfos["kind"] = &api.AuthInfo{Token: "token"}
+	cfg.Contexts["kind-kind"] = &api.Context{Cluster: "kind", AuthInfo: "kind"}
+	if err := clientcmd.WriteToFile(*cfg, path); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := kubeconfig.Context{Name: "dev.yaml/kind-kind", OriginalName: "kind-kind", WithPath: kubeconfig.WithPath{FilePath: path}}
+	newConfig, err := buildKubeconfigForContext(ctx, "", nil)
+	if err != nil {
+		t.Fatalf("buildKubeconfigForContext() error = %v", err)
+	}
+
+	if newConfig.CurrentContext != "dev.yaml/kind-kind" {
+		t.Errorf("CurrentContext = %q, want the qualified name", newConfig.CurrentContext)
+	}
+	if _, ok := newConfig.Contexts["kind-kind"]; ok || len(newConfig.Contexts) != 1 {
+		t.Errorf("Contexts = %v, want only the qualified name", newConfig.Contexts)
+	}
+}
+
+func TestBuildKubeconfigForContext_InMemory(t *testing.T) {
+	cfg := api.NewConfig()
+	cfg.Clusters["inventory"] = &api.Cluster{Server: "https://inventory.example.com"}
+	cfg.AuthInfos["inventory"] = &api.AuthInfo{Token: "token"}
+	cfg.Contexts["acme-dev"] = &api.Context{Cluster: "inventory", AuthInfo: "inventory"}
+
+	// Providers that are not cached have no file.
+	ctx := kubeconfig.Context{Name: "acme-dev", WithPath: kubeconfig.WithPath{Config: cfg}}
+	newConfig, err := buildKubeconfigForContext(ctx, "payments", nil)
+	if err != nil {
+		t.Fatalf("buildKubeconfigForContext() error = %v", err)
+	}
+
+	if got := newConfig.Contexts["acme-dev"].Namespace; got != "payments" {
+		t.Errorf("Namespace = %q, want payments", got)
+	}
+	if cfg.Contexts["acme-dev"].Namespace != "" {
+		t.Error("buildKubeconfigForContext() modified the kubeconfig of the context")
+	}
+}
+
 func TestContextOptions_Run_InPlaceSwitch_HooksFire(t *testing.T) {
 	preHookFired := false
 	postHookFired := false
@@ -941,15 +1276,15 @@ func TestContextOptions_Run_InPlaceSwitch_HooksFire(t *testing.T) {
 			return state.NewManager()
 		},
 		IsInteractive: func() bool { return false },
-		ShellLauncher: func(_, _, _ string, _ config.Config) error {
+		ShellLauncher: func(_, _, _ string, _ bool, _ config.Config) error {
 			t.Error("ShellLauncher should not be called for in-place switch")
 			return nil
 		},
-		TempFileWriter: func(_, _, _ string) (*os.File, func(), error) {
+		TempFileWriter: func(_ kubeconfig.Context, _ string, _ *config.ReadonlyIdentity) (*os.File, func(), error) {
 			t.Error("TempFileWriter should not be called for in-place switch")
 			return nil, nil, nil
 		},
-		InPlaceWriter: func(_, _, _, _ string) error { return nil },
+		InPlaceWriter: func(_ kubeconfig.Context, _, _ string, _ *config.ReadonlyIdentity) error { return nil },
 	}
 
 	if err := o.Run(); err != nil {
@@ -1096,11 +1431,11 @@ func TestContextOptions_Run_RestoresLastNamespace_InPlace(t *testing.T) {
 		},
 		StateManager:  func() (*state.Manager, error) { return sm, nil },
 		IsInteractive: func() bool { return false },
-		ShellLauncher: func(_, _, _ string, _ config.Config) error { return nil },
-		TempFileWriter: func(_, _, _ string) (*os.File, func(), error) {
+		ShellLauncher: func(_, _, _ string, _ bool, _ config.Config) error { return nil },
+		TempFileWriter: func(_ kubeconfig.Context, _ string, _ *config.ReadonlyIdentity) (*os.File, func(), error) {
 			return nil, func() {}, nil
 		},
-		InPlaceWriter: func(_, _, namespace, _ string) error {
+		InPlaceWriter: func(_ kubeconfig.Context, namespace, _ string, _ *config.ReadonlyIdentity) error {
 			gotNamespace = namespace
 			return nil
 		},
@@ -1137,8 +1472,8 @@ func TestContextOptions_Run_RestoresLastNamespace_Nested(t *testing.T) {
 		},
 		StateManager:  func() (*state.Manager, error) { return sm, nil },
 		IsInteractive: func() bool { return false },
-		ShellLauncher: func(_, _, _ string, _ config.Config) error { return nil },
-		TempFileWriter: func(_, _, namespace string) (*os.File, func(), error) {
+		ShellLauncher: func(_, _, _ string, _ bool, _ config.Config) error { return nil },
+		TempFileWriter: func(_ kubeconfig.Context, namespace string, _ *config.ReadonlyIdentity) (*os.File, func(), error) {
 			gotNamespace = namespace
 			f, err := os.CreateTemp("", "test-*.yaml")
 			if err != nil {
@@ -1146,7 +1481,7 @@ func TestContextOptions_Run_RestoresLastNamespace_Nested(t *testing.T) {
 			}
 			return f, func() { _ = os.Remove(f.Name()) }, nil
 		},
-		InPlaceWriter: func(_, _, _, _ string) error { return nil },
+		InPlaceWriter: func(_ kubeconfig.Context, _, _ string, _ *config.ReadonlyIdentity) error { return nil },
 	}
 
 	if err := o.Run(); err != nil {
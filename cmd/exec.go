@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
@@ -14,9 +16,14 @@ import (
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 
+	"github.com/idebeijer/kubert/internal/audit"
 	"github.com/idebeijer/kubert/internal/config"
 	"github.com/idebeijer/kubert/internal/fzf"
 	"github.com/idebeijer/kubert/internal/kubeconfig"
+	"github.com/idebeijer/kubert/internal/kubectl"
+	"github.com/idebeijer/kubert/internal/kubert"
+	"github.com/idebeijer/kubert/internal/prompt"
+	"github.com/idebeijer/kubert/internal/protection"
 	"github.com/idebeijer/kubert/internal/state"
 )
 
@@ -42,26 +49,33 @@ var execExample = `  # Run kubectl get pods in all production contexts
   
   # Dry run to see which contexts would be used
   kubert exec "prod*" --dry-run -- kubectl get pods
+
+  # Confirm protected contexts without prompting, for scripts
+  kubert exec "prod*" --yes-protected -- kubectl rollout restart deployment/web
 `
 
 type ExecOptions struct {
 	Out    io.Writer
 	ErrOut io.Writer
 
-	Namespace string
-	Regex     bool
-	Parallel  bool
-	DryRun    bool
-	Output    string
+	Namespace    string
+	Regex        bool
+	Parallel     bool
+	DryRun       bool
+	Output       string
+	YesProtected bool
 
 	Patterns    []string
 	CommandArgs []string
 
 	Config        config.Config
+	Aliases       *kubeconfig.Aliases
 	ContextLoader func() ([]kubeconfig.Context, error)
 	StateManager  func() (*state.Manager, error)
 	IsInteractive func() bool
 	Selector      func([]string) ([]string, error)
+	Prompter      func(mode prompt.Mode, context string) bool
+	AuditRecorder func(audit.Entry) error
 }
 
 func NewExecOptions() *ExecOptions {
@@ -71,13 +85,17 @@ func NewExecOptions() *ExecOptions {
 
 		ContextLoader: func() ([]kubeconfig.Context, error) {
 			cfg := config.Cfg
-			fsProvider := kubeconfig.NewFileSystemProvider(cfg.KubeconfigPaths.Include, cfg.KubeconfigPaths.Exclude)
-			loader := kubeconfig.NewLoader(kubeconfig.WithProvider(fsProvider))
+			loader, err := kubeconfig.NewLoaderFromConfig(cfg)
+			if err != nil {
+				return nil, err
+			}
 			return loader.LoadContexts()
 		},
 		StateManager:  state.NewManager,
 		IsInteractive: fzf.IsInteractive,
 		Selector:      fzf.SelectMulti,
+		Prompter:      prompt.Confirm,
+		AuditRecorder: audit.Record,
 	}
 }
 
@@ -91,12 +109,25 @@ func NewExecCommand() *cobra.Command {
 
 The command will run against all contexts matching the provided patterns.
 By default, uses glob-style wildcards (* and ?). Use --regex for regex patterns.
+Patterns match context names as well as their aliases.
 
 If no patterns are provided and running in an interactive shell with fzf,
-you can select multiple contexts interactively (use Tab/Shift-Tab to select).`,
+you can select multiple contexts interactively (use Tab/Shift-Tab to select).
+
+Protection is evaluated for the command in every context. Contexts where the command is
+denied are skipped. If the command needs confirmation in any context, kubert asks once
+up front, listing those contexts, and skips them if the answer is no. Use --yes-protected
+to confirm without prompting. Rules with the preview action ask for confirmation without
+showing a preview; previews are only shown by "kubert kubectl".`,
 		Example:      execExample,
 		SilenceUsage: true,
 		Args:         cobra.MinimumNArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if cmd.ArgsLenAtDash() >= 0 {
+				return nil, cobra.ShellCompDirectiveDefault
+			}
+			return validContextArgsFunction(cmd, args, toComplete)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := o.Complete(cmd, args); err != nil {
 				return err
@@ -113,6 +144,7 @@ you can select multiple contexts interactively (use Tab/Shift-Tab to select).`,
 	cmd.Flags().BoolVarP(&o.Parallel, "parallel", "p", false, "Execute commands in parallel across all contexts")
 	cmd.Flags().BoolVar(&o.DryRun, "dry-run", false, "Show which contexts would be used without executing the command")
 	cmd.Flags().StringVarP(&o.Output, "output", "o", "", "Output format (e.g., 'json')")
+	cmd.Flags().BoolVar(&o.YesProtected, "yes-protected", false, "Confirm running the command in protected contexts that would prompt, without prompting")
 
 	return cmd
 }
@@ -122,6 +154,12 @@ func (o *ExecOptions) Complete(cmd *cobra.Command, args []string) error {
 	o.ErrOut = cmd.ErrOrStderr()
 	o.Config = config.Cfg
 
+	aliases, err := kubeconfig.NewAliases(o.Config.Aliases)
+	if err != nil {
+		return err
+	}
+	o.Aliases = aliases
+
 	dashIdx := cmd.ArgsLenAtDash()
 	switch dashIdx {
 	case -1:
@@ -169,22 +207,96 @@ func (o *ExecOptions) Run() error {
 		return fmt.Errorf("error creating state manager: %w", err)
 	}
 
+	targets, err := planExec(matchedContexts, o.CommandArgs, o.Namespace, sm, o.Config)
+	if err != nil {
+		return fmt.Errorf("error checking context protection: %w", err)
+	}
+
 	if o.DryRun {
-		return showDryRun(o.Out, matchedContexts, o.CommandArgs, o.Namespace, sm, o.Config)
+		showDryRun(o.Out, targets, o.CommandArgs, o.Namespace, o.YesProtected)
+		return nil
 	}
 
+	o.confirmProtected(targets)
+
 	if o.Output != outputJSON {
-		fmt.Fprintf(o.Out, "Executing command against %d context(s):\n", len(matchedContexts))
-		for _, ctx := range matchedContexts {
-			fmt.Fprintf(o.Out, "  - %s\n", ctx.Name)
-		}
-		fmt.Fprintln(o.Out)
+		printExecPlan(o.Out, targets)
 	}
 
 	if o.Parallel {
-		return executeParallel(o.Out, matchedContexts, o.CommandArgs, o.Namespace, sm, o.Config, o.Output)
+		return executeParallel(o.Out, targets, o.CommandArgs, o.Namespace, o.Output, o.AuditRecorder)
+	}
+	return executeSequential(o.Out, targets, o.CommandArgs, o.Namespace, o.Output, o.AuditRecorder)
+}
+
+// printExecPlan lists the contexts the command runs in, and the protected contexts it skips.
+func printExecPlan(out io.Writer, targets []execTarget) {
+	var run, skipped []execTarget
+	for _, target := range targets {
+		if target.skipped() {
+			skipped = append(skipped, target)
+		} else {
+			run = append(run, target)
+		}
+	}
+
+	fmt.Fprintf(out, "Executing command against %d context(s):\n", len(run))
+	for _, target := range run {
+		fmt.Fprintf(out, "  - %s\n", target.ctx.Name)
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(out, "Skipping %d protected context(s):\n", len(skipped))
+		for _, target := range skipped {
+			fmt.Fprintf(out, "  - %s (%s)\n", target.ctx.Name, target.decision.Reason)
+		}
+	}
+	fmt.Fprintln(out)
+}
+
+// confirmProtected asks once for all contexts where the command needs confirmation, and marks
+// them confirmed if the answer is yes or --yes-protected is set.
+func (o *ExecOptions) confirmProtected(targets []execTarget) {
+	var names []string
+	mode := prompt.ModeYes
+	previews := 0
+	for _, target := range targets {
+		if target.decision.Action.Confirms() {
+			names = append(names, target.ctx.Name)
+			mode = prompt.Strictest(mode, target.decision.Confirm)
+		}
+		if target.decision.Action == protection.ActionPreview {
+			previews++
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+
+	confirmed := o.YesProtected
+	if !confirmed {
+		yellow := color.New(color.FgHiYellow).SprintFunc()
+		fmt.Fprintf(o.ErrOut, "%s: \"%s\" is protected in %d context(s):\n", yellow("WARNING"), strings.Join(o.CommandArgs, " "), len(names))
+		for _, name := range names {
+			fmt.Fprintf(o.ErrOut, "  - %s\n", name)
+		}
+		if previews > 0 {
+			fmt.Fprintln(o.ErrOut, previewSkippedNote(previews))
+		}
+		fmt.Fprintln(o.ErrOut, "Contexts that are not confirmed will be skipped.")
+
+		// A single context name cannot confirm several contexts.
+		if mode == prompt.ModeContext && len(names) > 1 {
+			mode = prompt.ModeChallenge
+		}
+		confirmed = o.Prompter(mode, names[0])
+		fmt.Fprintln(o.ErrOut)
+	}
+
+	for i := range targets {
+		if targets[i].decision.Action.Confirms() {
+			targets[i].confirmed = confirmed
+		}
 	}
-	return executeSequential(o.Out, matchedContexts, o.CommandArgs, o.Namespace, sm, o.Config, o.Output)
 }
 
 func (o *ExecOptions) resolveContexts(contexts []kubeconfig.Context) ([]kubeconfig.Context, error) {
@@ -192,7 +304,7 @@ func (o *ExecOptions) resolveContexts(contexts []kubeconfig.Context) ([]kubeconf
 		return o.resolveInteractive(contexts)
 	}
 
-	matched, err := filterContextsByPatterns(contexts, o.Patterns, o.Regex)
+	matched, err := filterContextsByPatterns(contexts, o.Patterns, o.Regex, o.Aliases)
 	if err != nil {
 		return nil, fmt.Errorf("error filtering contexts: %w", err)
 	}
@@ -205,7 +317,7 @@ func (o *ExecOptions) resolveContexts(contexts []kubeconfig.Context) ([]kubeconf
 }
 
 func (o *ExecOptions) resolveInteractive(contexts []kubeconfig.Context) ([]kubeconfig.Context, error) {
-	contextNames := getContextNames(contexts)
+	contextNames := getDisplayNames(contexts, o.Aliases)
 	sort.Strings(contextNames)
 
 	selectedNames, err := o.Selector(contextNames)
@@ -219,7 +331,7 @@ func (o *ExecOptions) resolveInteractive(contexts []kubeconfig.Context) ([]kubec
 
 	var matched []kubeconfig.Context
 	for _, name := range selectedNames {
-		if ctx, found := findContextByName(contexts, name); found {
+		if ctx, err := o.Aliases.Find(contexts, name); err == nil {
 			matched = append(matched, ctx)
 		}
 	}
@@ -233,11 +345,11 @@ type contextExecResult struct {
 	err         error
 }
 
-func filterContextsByPatterns(contexts []kubeconfig.Context, patterns []string, useRegex bool) ([]kubeconfig.Context, error) {
+func filterContextsByPatterns(contexts []kubeconfig.Context, patterns []string, useRegex bool, aliases *kubeconfig.Aliases) ([]kubeconfig.Context, error) {
 	matchedMap := make(map[string]kubeconfig.Context)
 
 	for _, pattern := range patterns {
-		matched, err := filterContextsByPattern(contexts, pattern, useRegex)
+		matched, err := filterContextsByPattern(contexts, pattern, useRegex, aliases)
 		if err != nil {
 			return nil, err
 		}
@@ -259,7 +371,8 @@ func filterContextsByPatterns(contexts []kubeconfig.Context, patterns []string,
 	return result, nil
 }
 
-func filterContextsByPattern(contexts []kubeconfig.Context, pattern string, useRegex bool) ([]kubeconfig.Context, error) {
+// filterContextsByPattern returns the contexts whose name or alias matches the pattern.
+func filterContextsByPattern(contexts []kubeconfig.Context, pattern string, useRegex bool, aliases *kubeconfig.Aliases) ([]kubeconfig.Context, error) {
 	var regexPattern string
 
 	if useRegex {
@@ -275,7 +388,8 @@ func filterContextsByPattern(contexts []kubeconfig.Context, pattern string, useR
 
 	var matched []kubeconfig.Context
 	for _, ctx := range contexts {
-		if regex.MatchString(ctx.Name) {
+		alias := aliases.Alias(ctx.Name)
+		if regex.MatchString(ctx.Name) || (alias != "" && regex.MatchString(alias)) {
 			matched = append(matched, ctx)
 		}
 	}
@@ -294,16 +408,16 @@ func globToRegex(pattern string) string {
 	return "^" + pattern + "$"
 }
 
-func executeSequential(out io.Writer, contexts []kubeconfig.Context, args []string, namespace string, sm *state.Manager, cfg config.Config, outputFormat string) error {
+func executeSequential(out io.Writer, targets []execTarget, args []string, namespace string, outputFormat string, record func(audit.Entry) error) error {
 	hasErrors := false
 	var allResults []contextExecResult
 
-	for i, ctx := range contexts {
+	for i, target := range targets {
 		if outputFormat != outputJSON && i > 0 {
 			fmt.Fprintln(out)
 		}
 
-		result := executeInContext(ctx, args, namespace, sm, cfg, outputFormat)
+		result := executeInContext(target, args, namespace, outputFormat, record)
 
 		if outputFormat == outputJSON {
 			allResults = append(allResults, result)
@@ -327,18 +441,18 @@ func executeSequential(out io.Writer, contexts []kubeconfig.Context, args []stri
 	return nil
 }
 
-func executeParallel(out io.Writer, contexts []kubeconfig.Context, args []string, namespace string, sm *state.Manager, cfg config.Config, outputFormat string) error {
+func executeParallel(out io.Writer, targets []execTarget, args []string, namespace string, outputFormat string, record func(audit.Entry) error) error {
 	var wg sync.WaitGroup
 
-	resultsChan := make(chan contextExecResult, len(contexts))
+	resultsChan := make(chan contextExecResult, len(targets))
 
-	for _, ctx := range contexts {
+	for _, target := range targets {
 		wg.Add(1)
-		go func(ctx kubeconfig.Context) {
+		go func(target execTarget) {
 			defer wg.Done()
-			result := executeInContext(ctx, args, namespace, sm, cfg, outputFormat)
+			result := executeInContext(target, args, namespace, outputFormat, record)
 			resultsChan <- result
-		}(ctx)
+		}(target)
 	}
 
 	wg.Wait()
@@ -378,28 +492,42 @@ func executeParallel(out io.Writer, contexts []kubeconfig.Context, args []string
 	return nil
 }
 
-func executeInContext(ctx kubeconfig.Context, args []string, namespace string, sm *state.Manager, cfg config.Config, outputFormat string) contextExecResult {
+func executeInContext(target execTarget, args []string, namespace string, outputFormat string, record func(audit.Entry) error) contextExecResult {
+	ctx := target.ctx
 	result := contextExecResult{
 		contextName: ctx.Name,
 	}
 
-	locked, err := isContextProtected(sm, ctx.Name, cfg)
-	if err != nil {
-		result.err = fmt.Errorf("error checking context protection: %w", err)
-		return result
+	decision := target.decision
+	skip := ""
+	switch decision.Action {
+	case protection.ActionDeny:
+		recordExecAudit(record, args, target.request, decision, audit.DecisionDenied)
+		skip = decision.Reason
+	case protection.ActionPrompt, protection.ActionPreview:
+		if !target.confirmed {
+			recordExecAudit(record, args, target.request, decision, audit.DecisionPromptedNo)
+			skip = decision.Reason + ", not confirmed"
+		} else {
+			recordExecAudit(record, args, target.request, decision, audit.DecisionPromptedYes)
+		}
+	default:
+		if audit.ShouldRecord(decision) {
+			recordExecAudit(record, args, target.request, decision, audit.DecisionAllowed)
+		}
 	}
 
-	if locked {
+	if skip != "" {
 		warningText := "WARNING"
 		if outputFormat != outputJSON {
 			yellow := color.New(color.FgHiYellow).SprintFunc()
 			warningText = yellow(warningText)
 		}
-		result.err = fmt.Errorf("%s: context %s is protected, skipping", warningText, ctx.Name)
+		result.err = fmt.Errorf("%s: context %s is protected (%s), skipping", warningText, ctx.Name, skip)
 		return result
 	}
 
-	tempKubeconfig, cleanup, err := createTempKubeconfigFile(ctx.FilePath, ctx.Name, namespace)
+	tempKubeconfig, cleanup, err := createTempKubeconfigFile(ctx, namespace, nil)
 	if err != nil {
 		result.err = fmt.Errorf("failed to create temp kubeconfig: %w", err)
 		return result
@@ -413,13 +541,94 @@ func executeInContext(ctx kubeconfig.Context, args []string, namespace string, s
 	return result
 }
 
+// recordExecAudit writes a decision to the audit log. A failure to write is logged but does not
+// stop the command.
+func recordExecAudit(record func(audit.Entry) error, args []string, req protection.Request, decision protection.Decision, outcome audit.Decision) {
+	if record == nil {
+		return
+	}
+	if err := record(audit.NewEntry("exec", args, req.Namespace, decision, outcome)); err != nil {
+		slog.Warn("failed to write audit log", "context", req.Context, "error", err)
+	}
+}
+
+// execTarget is a context to run the command in, with the protection decision for it.
+type execTarget struct {
+	ctx      kubeconfig.Context
+	request  protection.Request
+	decision protection.Decision
+
+	// confirmed is set when running the command was confirmed for a context that prompts.
+	confirmed bool
+}
+
+// skipped reports whether the command is not run in the context, because it is denied or was
+// not confirmed.
+func (t execTarget) skipped() bool {
+	return t.decision.Action == protection.ActionDeny || t.decision.Action.Confirms() && !t.confirmed
+}
+
+// planExec evaluates protection for running args in each context. The dry run and the real
+// run share the plan, so they always agree.
+func planExec(contexts []kubeconfig.Context, args []string, namespace string, sm *state.Manager, cfg config.Config) ([]execTarget, error) {
+	if err := protection.InheritRenamed(sm, contexts); err != nil {
+		return nil, err
+	}
+	engine, err := protection.NewEngine(cfg, sm)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]execTarget, 0, len(contexts))
+	for _, ctx := range contexts {
+		req := execProtectionRequest(ctx, args, namespace)
+		decision, err := engine.Evaluate(req)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ctx.Name, err)
+		}
+		targets = append(targets, execTarget{ctx: ctx, request: req, decision: decision})
+	}
+	return targets, nil
+}
+
+// execProtectionRequest builds the protection request for running args in a context. Only
+// kubectl commands can be inspected, anything else is handled as a protected command.
+func execProtectionRequest(ctx kubeconfig.Context, args []string, namespace string) protection.Request {
+	req := protection.Request{Context: ctx.Name, Namespace: namespace}
+	if req.Namespace == "" {
+		req.Namespace = ctx.Namespace
+	}
+
+	if len(args) > 0 && filepath.Base(args[0]) == kubectlBin {
+		req.Command = kubectl.Parse(args[1:])
+		if ns, ok := req.Command.Flag("namespace"); ok && ns != "" {
+			req.Namespace = ns
+		}
+		req.AllNamespaces = req.Command.BoolFlag("all-namespaces")
+	} else {
+		req.Opaque = true
+	}
+
+	if req.Namespace == "" {
+		req.Namespace = "default"
+	}
+
+	return req
+}
+
 func runCommand(args []string, kubeconfigPath string) (string, error) {
 	if len(args) == 0 {
 		return "", fmt.Errorf("no command provided")
 	}
 
-	cmd := exec.Command(args[0], args[1:]...)
-	cmd.Env = append(os.Environ(), "KUBECONFIG="+kubeconfigPath)
+	// Protection has already been applied, so the command and anything it runs must not go
+	// through the shims of the kubert shell.
+	name := args[0]
+	if path, err := kubert.LookPath(name); err == nil {
+		name = path
+	}
+	cmd := exec.Command(name, args[1:]...)
+	cmd.Env = append(os.Environ(), "KUBECONFIG="+kubeconfigPath, "PATH="+kubert.PathWithoutShims(os.Getenv("PATH")))
 
 	output, err := cmd.CombinedOutput()
 	return string(output), err
@@ -483,7 +692,7 @@ func printJSONResults(out io.Writer, results []contextExecResult) {
 	fmt.Fprintln(out, string(jsonBytes))
 }
 
-func showDryRun(out io.Writer, contexts []kubeconfig.Context, args []string, namespace string, sm *state.Manager, cfg config.Config) error {
+func showDryRun(out io.Writer, targets []execTarget, args []string, namespace string, yesProtected bool) {
 	yellow := color.New(color.FgYellow).SprintFunc()
 	green := color.New(color.FgGreen).SprintFunc()
 
@@ -493,30 +702,53 @@ func showDryRun(out io.Writer, contexts []kubeconfig.Context, args []string, nam
 	if namespace != "" {
 		fmt.Fprintf(out, "Namespace: %s\n", namespace)
 	}
-	fmt.Fprintf(out, "Total contexts: %d\n", len(contexts))
+	fmt.Fprintf(out, "Total contexts: %d\n", len(targets))
 	fmt.Fprintln(out)
 
+	prompts, previews := 0, 0
 	fmt.Fprintln(out, "Contexts to execute against:")
-	for _, ctx := range contexts {
-		locked, err := isContextProtected(sm, ctx.Name, cfg)
-		if err != nil {
-			return fmt.Errorf("error checking context protection for %s: %w", ctx.Name, err)
-		}
-
+	for _, target := range targets {
 		status := green("✓")
 		statusText := ""
-		if locked {
-			if !cfg.Protection.Prompt {
-				status = yellow("⊘")
-				statusText = " (protected - will be skipped)"
+		switch target.decision.Action {
+		case protection.ActionDeny:
+			status = yellow("⊘")
+			statusText = " (protected - will be skipped)"
+		case protection.ActionPrompt, protection.ActionPreview:
+			if yesProtected {
+				statusText = " (protected - confirmed by --yes-protected"
 			} else {
+				prompts++
 				status = yellow("⚠")
-				statusText = " (protected - will prompt)"
+				statusText = " (protected - will prompt, skipped if not confirmed"
+			}
+			if target.decision.Action == protection.ActionPreview {
+				previews++
+				statusText += ", preview skipped"
+			}
+			statusText += ")"
+		default:
+			if target.decision.Status.Protected {
+				statusText = " (protected - command allowed)"
 			}
 		}
 
-		fmt.Fprintf(out, "  %s %s%s\n", status, ctx.Name, statusText)
+		fmt.Fprintf(out, "  %s %s%s\n", status, target.ctx.Name, statusText)
 	}
 
-	return nil
+	if prompts > 0 || previews > 0 {
+		fmt.Fprintln(out)
+	}
+	if prompts > 0 {
+		fmt.Fprintf(out, "You will be asked once to confirm the command in %d protected context(s).\n", prompts)
+	}
+	if previews > 0 {
+		fmt.Fprintln(out, previewSkippedNote(previews))
+	}
+}
+
+// previewSkippedNote explains that preview rules only prompt in kubert exec.
+func previewSkippedNote(previews int) string {
+	return fmt.Sprintf("A preview is required in %d context(s), but previews are only shown by \"kubert kubectl\": "+
+		"\"kubert exec\" asks for confirmation without one.", previews)
 }
@@ -15,8 +15,11 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
 
+	"github.com/idebeijer/kubert/internal/audit"
 	"github.com/idebeijer/kubert/internal/config"
 	"github.com/idebeijer/kubert/internal/kubeconfig"
+	"github.com/idebeijer/kubert/internal/prompt"
+	"github.com/idebeijer/kubert/internal/protection"
 	"github.com/idebeijer/kubert/internal/state"
 )
 
@@ -133,7 +136,7 @@ func TestFilterContextsByPattern(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			matched, err := filterContextsByPattern(contexts, tt.pattern, tt.useRegex)
+			matched, err := filterContextsByPattern(contexts, tt.pattern, tt.useRegex, nil)
 			if err != nil {
 				t.Fatalf("filterContextsByPattern failed: %v", err)
 			}
@@ -154,6 +157,40 @@ func TestFilterContextsByPattern(t *testing.T) {
 	}
 }
 
+func TestFilterContextsByPattern_Aliases(t *testing.T) {
+	contexts := []kubeconfig.Context{
+		{Name: "arn:aws:eks:eu-west-1:1234:cluster/prod-a"},
+		{Name: "arn:aws:eks:eu-west-1:1234:cluster/dev"},
+		{Name: "prod-local"},
+	}
+	aliases, err := kubeconfig.NewAliases(config.Aliases{Rewrites: []config.AliasRewrite{
+		{Regex: `^arn:aws:eks:[^:]+:\d+:cluster/(.+)$`, Replacement: "$1"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matched, err := filterContextsByPattern(contexts, "prod*", false, aliases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(matched))
+	for i, ctx := range matched {
+		names[i] = ctx.Name
+	}
+	if !stringSlicesEqual(names, []string{"arn:aws:eks:eu-west-1:1234:cluster/prod-a", "prod-local"}) {
+		t.Errorf("expected the aliased and the plain prod context, got %v", names)
+	}
+
+	matched, err = filterContextsByPattern(contexts, "*cluster/dev", false, aliases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matched) != 1 || matched[0].Name != "arn:aws:eks:eu-west-1:1234:cluster/dev" {
+		t.Errorf("expected the full name to keep matching, got %v", matched)
+	}
+}
+
 func TestFilterContextsByPatterns(t *testing.T) {
 	contexts := []kubeconfig.Context{
 		{Name: "prod-east", WithPath: kubeconfig.WithPath{FilePath: "/tmp/config1"}},
@@ -224,7 +261,7 @@ func TestFilterContextsByPatterns(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			matched, err := filterContextsByPatterns(contexts, tt.patterns, tt.useRegex)
+			matched, err := filterContextsByPatterns(contexts, tt.patterns, tt.useRegex, nil)
 			if err != nil {
 				t.Fatalf("filterContextsByPatterns failed: %v", err)
 			}
@@ -270,7 +307,7 @@ func TestExecuteInContextKubeconfigSetup(t *testing.T) {
 
 	args := []string{"sh", "-c", "echo $KUBECONFIG"}
 
-	result := executeInContext(contexts[0], args, "", sm, testConfig, "")
+	result := executeInContext(planTarget(t, contexts[0], args, "", sm, testConfig), args, "", "", nil)
 
 	if result.err != nil {
 		t.Fatalf("executeInContext failed: %v", result.err)
@@ -316,7 +353,7 @@ func TestExecuteInContextWithNamespace(t *testing.T) {
 
 	args := []string{"sh", "-c", "cat $KUBECONFIG | grep namespace"}
 
-	result := executeInContext(contexts[0], args, namespace, sm, testConfig, "")
+	result := executeInContext(planTarget(t, contexts[0], args, namespace, sm, testConfig), args, namespace, "", nil)
 
 	if result.err != nil {
 		t.Fatalf("executeInContext failed: %v", result.err)
@@ -327,6 +364,45 @@ func TestExecuteInContextWithNamespace(t *testing.T) {
 	}
 }
 
+// planTarget plans running args in a single context.
+func planTarget(t *testing.T, ctx kubeconfig.Context, args []string, namespace string, sm *state.Manager, cfg config.Config) execTarget {
+	t.Helper()
+	targets, err := planExec([]kubeconfig.Context{ctx}, args, namespace, sm, cfg)
+	if err != nil {
+		t.Fatalf("planExec failed: %v", err)
+	}
+	return targets[0]
+}
+
+func TestExecuteInContextRecordsAudit(t *testing.T) {
+	tempDir := t.TempDir()
+	kubeconfigPath := filepath.Join(tempDir, "test-config")
+	cfg := createTestKubeconfig(t, kubeconfigPath, "prod-context", "prod-cluster", "prod-user")
+	ctx := kubeconfig.Context{Name: "prod-context", WithPath: kubeconfig.WithPath{Config: cfg, FilePath: kubeconfigPath}}
+
+	prodRegex := "^prod"
+	testConfig := config.Config{Protection: config.Protection{Regex: &prodRegex}}
+
+	var entries []audit.Entry
+	record := func(e audit.Entry) error {
+		entries = append(entries, e)
+		return nil
+	}
+
+	args := []string{"sh", "-c", "true"}
+	result := executeInContext(planTarget(t, ctx, args, "web", &state.Manager{}, testConfig), args, "web", "", record)
+	if result.err == nil {
+		t.Fatal("expected opaque command in protected context to be skipped")
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 audit entry, got %d", len(entries))
+	}
+	if e := entries[0]; e.Decision != audit.DecisionDenied || e.Context != "prod-context" || e.Namespace != "web" || e.Command != "exec" {
+		t.Errorf("unexpected audit entry: %+v", e)
+	}
+}
+
 func TestExecuteParallelIsolation(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -364,7 +440,7 @@ func TestExecuteParallelIsolation(t *testing.T) {
 			defer wg.Done()
 
 			args := []string{"sh", "-c", "echo $KUBECONFIG"}
-			result := executeInContext(ctx, args, "", sm, testConfig, "")
+			result := executeInContext(planTarget(t, ctx, args, "", sm, testConfig), args, "", "", nil)
 
 			mu.Lock()
 			kubeconfigPath := strings.TrimSpace(result.output)
@@ -861,3 +937,190 @@ func TestPrintJSONResults(t *testing.T) {
 		t.Errorf("Expected empty string fallback for empty output string, got %s", outputJSON)
 	}
 }
+
+func TestPlanExec(t *testing.T) {
+	prodRegex := "^prod"
+	cfg := config.Config{
+		Protection: config.Protection{
+			Regex:    &prodRegex,
+			Commands: []string{"delete"},
+			Prompt:   false,
+			Rules: []config.ProtectionRule{
+				{Name: "system", Namespaces: []string{"kube-system"}, Action: "deny"},
+			},
+		},
+	}
+	ctx := kubeconfig.Context{Name: "prod-cluster"}
+
+	tests := []struct {
+		name      string
+		args      []string
+		namespace string
+		expected  protection.Action
+	}{
+		{name: "read-only kubectl command", args: []string{"kubectl", "get", "pods"}, expected: protection.ActionAllow},
+		{name: "protected kubectl command", args: []string{"kubectl", "delete", "pod", "x"}, expected: protection.ActionDeny},
+		{name: "kubectl by path", args: []string{"/usr/local/bin/kubectl", "-n", "x", "delete", "pod", "y"}, expected: protection.ActionDeny},
+		{name: "other command", args: []string{"helm", "list"}, expected: protection.ActionDeny},
+		{name: "namespace flag of exec", args: []string{"kubectl", "get", "pods"}, namespace: "kube-system", expected: protection.ActionDeny},
+		{name: "namespace flag of kubectl", args: []string{"kubectl", "get", "pods", "-n", "kube-system"}, expected: protection.ActionDeny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := planTarget(t, ctx, tt.args, tt.namespace, &state.Manager{}, cfg).decision
+			if decision.Action != tt.expected {
+				t.Errorf("planExec() = %s, want %s (%s)", decision.Action, tt.expected, decision.Reason)
+			}
+		})
+	}
+}
+
+func newProtectedExecOptions(t *testing.T, buf *bytes.Buffer) *ExecOptions {
+	t.Helper()
+	tempDir := t.TempDir()
+
+	var contexts []kubeconfig.Context
+	for _, name := range []string{"dev", "prod-eu", "prod-us"} {
+		path := filepath.Join(tempDir, name)
+		cfg := createTestKubeconfig(t, path, name, "cluster-"+name, "user-"+name)
+		contexts = append(contexts, kubeconfig.Context{Name: name, WithPath: kubeconfig.WithPath{Config: cfg, FilePath: path}})
+	}
+
+	prodRegex := "^prod"
+	return &ExecOptions{
+		Out:         buf,
+		ErrOut:      buf,
+		Patterns:    []string{"*"},
+		CommandArgs: []string{"sh", "-c", "true"},
+		Config: config.Config{
+			Protection: config.Protection{Regex: &prodRegex, Prompt: true},
+		},
+		ContextLoader: func() ([]kubeconfig.Context, error) {
+			return contexts, nil
+		},
+		StateManager: func() (*state.Manager, error) {
+			return &state.Manager{}, nil
+		},
+	}
+}
+
+func TestExecOptions_Run_PromptsOnceForProtectedContexts(t *testing.T) {
+	tests := []struct {
+		name        string
+		answer      bool
+		preview     bool
+		expectError bool
+	}{
+		{name: "confirmed", answer: true},
+		{name: "declined", answer: false, expectError: true},
+		{name: "preview rule prompts without preview", answer: true, preview: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			o := newProtectedExecOptions(t, &buf)
+			if tt.preview {
+				o.Config.Protection.Rules = []config.ProtectionRule{{Name: "preview-all", Action: "preview"}}
+			}
+
+			var prompts int
+			o.Prompter = func(prompt.Mode, string) bool {
+				prompts++
+				return tt.answer
+			}
+			var entries []audit.Entry
+			o.AuditRecorder = func(e audit.Entry) error {
+				entries = append(entries, e)
+				return nil
+			}
+
+			err := o.Run()
+			if (err != nil) != tt.expectError {
+				t.Fatalf("Run() error = %v, expectError %v\n%s", err, tt.expectError, buf.String())
+			}
+
+			if prompts != 1 {
+				t.Errorf("Expected a single prompt, got %d", prompts)
+			}
+			output := buf.String()
+			if !strings.Contains(output, "- prod-eu") || !strings.Contains(output, "- prod-us") {
+				t.Errorf("Expected prompt to list protected contexts, got: %s", output)
+			}
+			if got := strings.Contains(output, "previews are only shown by \"kubert kubectl\""); got != tt.preview {
+				t.Errorf("Expected a note about the skipped preview only for preview rules, got: %s", output)
+			}
+			if got := strings.Contains(output, "not confirmed), skipping"); got == tt.answer {
+				t.Errorf("Expected skipped contexts only when declined, got: %s", output)
+			}
+			header := "Executing command against 3 context(s):"
+			if !tt.answer {
+				header = "Executing command against 1 context(s):\n  - dev\nSkipping 2 protected context(s):"
+			}
+			if !strings.Contains(output, header) {
+				t.Errorf("Expected header %q, got: %s", header, output)
+			}
+
+			expected := audit.DecisionPromptedNo
+			if tt.answer {
+				expected = audit.DecisionPromptedYes
+			}
+			if len(entries) != 2 || entries[0].Decision != expected || entries[1].Decision != expected {
+				t.Errorf("Expected 2 audit entries with decision %s, got %+v", expected, entries)
+			}
+		})
+	}
+}
+
+func TestExecOptions_Run_YesProtected(t *testing.T) {
+	var buf bytes.Buffer
+	o := newProtectedExecOptions(t, &buf)
+	o.YesProtected = true
+	o.Prompter = func(prompt.Mode, string) bool {
+		t.Error("Prompter should not be called with --yes-protected")
+		return false
+	}
+
+	if err := o.Run(); err != nil {
+		t.Fatalf("Run() returned unexpected error: %v\n%s", err, buf.String())
+	}
+}
+
+func TestExecOptions_Run_DryRunMatchesPrompt(t *testing.T) {
+	var buf bytes.Buffer
+	o := newProtectedExecOptions(t, &buf)
+	o.DryRun = true
+
+	if err := o.Run(); err != nil {
+		t.Fatalf("Run() returned unexpected error: %v", err)
+	}
+	output := buf.String()
+	if strings.Count(output, "will prompt") != 2 || !strings.Contains(output, "asked once") {
+		t.Errorf("Expected dry run to show a single prompt for both protected contexts, got: %s", output)
+	}
+
+	buf.Reset()
+	o.YesProtected = true
+	if err := o.Run(); err != nil {
+		t.Fatalf("Run() returned unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "confirmed by --yes-protected") || strings.Contains(buf.String(), "will prompt") {
+		t.Errorf("Expected dry run to show --yes-protected confirmation, got: %s", buf.String())
+	}
+}
+
+func TestExecOptions_Run_DryRunPreview(t *testing.T) {
+	var buf bytes.Buffer
+	o := newProtectedExecOptions(t, &buf)
+	o.Config.Protection.Rules = []config.ProtectionRule{{Name: "preview-all", Action: "preview"}}
+	o.DryRun = true
+
+	if err := o.Run(); err != nil {
+		t.Fatalf("Run() returned unexpected error: %v", err)
+	}
+	output := buf.String()
+	if strings.Count(output, "preview skipped") != 2 || !strings.Contains(output, "A preview is required in 2 context(s)") {
+		t.Errorf("Expected dry run to say the preview is skipped in both protected contexts, got: %s", output)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"path/filepath"
 
 	"github.com/spf13/cobra"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
 
 	"github.com/idebeijer/kubert/internal/config"
@@ -15,9 +14,10 @@ import (
 )
 
 type lintResult struct {
-	FilePath string
-	Errors   []string
-	Warnings []string
+	FilePath  string
+	Encrypted bool
+	Errors    []string
+	Warnings  []string
 }
 
 func newLintCommand() *cobra.Command {
@@ -28,7 +28,8 @@ func newLintCommand() *cobra.Command {
 		Long: `Lint kubeconfig files to check for errors, warnings, and potential issues.
 
 If no files are provided, all kubeconfig files from the configured include patterns will be linted.
-If file paths are provided as arguments (including glob patterns), only those files will be linted.`,
+If file paths are provided as arguments (including glob patterns, where "**" matches any number
+of directories), only those files will be linted.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var filesToLint []string
 
@@ -41,8 +42,10 @@ If file paths are provided as arguments (including glob patterns), only those fi
 			} else {
 				// Lint all included kubeconfig files
 				cfg := config.Cfg
-				fsProvider := kubeconfig.NewFileSystemProvider(cfg.KubeconfigPaths.Include, cfg.KubeconfigPaths.Exclude)
-				loader := kubeconfig.NewLoader(kubeconfig.WithProvider(fsProvider))
+				loader, err := kubeconfig.NewLoaderFromConfig(cfg)
+				if err != nil {
+					return fmt.Errorf("failed to load kubeconfigs: %w", err)
+				}
 
 				kubeconfigs, err := loader.LoadAll()
 				if err != nil {
@@ -50,7 +53,9 @@ If file paths are provided as arguments (including glob patterns), only those fi
 				}
 
 				for _, k := range kubeconfigs {
-					filesToLint = append(filesToLint, k.FilePath)
+					if k.FilePath != "" {
+						filesToLint = append(filesToLint, k.FilePath)
+					}
 				}
 			}
 
@@ -77,7 +82,7 @@ func expandGlobs(patterns []string) ([]string, error) {
 			return nil, err
 		}
 
-		matches, err := filepath.Glob(expandedPattern)
+		matches, err := kubeconfig.Glob(expandedPattern, config.Cfg.KubeconfigPaths.MaxDepth)
 		if err != nil {
 			return nil, fmt.Errorf("failed to glob pattern %s: %w", expandedPattern, err)
 		}
@@ -120,13 +125,15 @@ func lintFiles(files []string) []lintResult {
 			Warnings: make([]string, 0),
 		}
 
-		// Try to load the kubeconfig
-		cfg, err := clientcmd.LoadFromFile(file)
+		// Try to load the kubeconfig, encrypted kubeconfigs are linted decrypted
+		loaded, err := kubeconfig.LoadFile(file)
+		result.Encrypted = loaded.Encrypted
 		if err != nil {
 			result.Errors = append(result.Errors, fmt.Sprintf("failed to load kubeconfig: %v", err))
 			results = append(results, result)
 			continue
 		}
+		cfg := loaded.Config
 
 		// Validate the kubeconfig structure
 		validateKubeconfig(cfg, &result)
@@ -232,6 +239,9 @@ func printLintResults(results []lintResult) error {
 		if err != nil {
 			absPath = result.FilePath
 		}
+		if result.Encrypted {
+			absPath += " (encrypted)"
+		}
 
 		if len(result.Errors) > 0 || len(result.Warnings) > 0 {
 			fmt.Printf("\n%s:\n", absPath)
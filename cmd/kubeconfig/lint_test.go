@@ -1,13 +1,19 @@
 package kubeconfig
 
 import (
+	"bytes"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"filippo.io/age"
+	"filippo.io/age/armor"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/idebeijer/kubert/internal/config"
 )
 
 func TestValidateKubeconfig(t *testing.T) {
@@ -129,6 +135,61 @@ func TestLintFiles(t *testing.T) {
 	}
 }
 
+func TestLintFiles_Encrypted(t *testing.T) {
+	tmpDir := t.TempDir()
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	identityFile := filepath.Join(tmpDir, "keys.txt")
+	if err := os.WriteFile(identityFile, []byte(identity.String()+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old := config.Cfg.KubeconfigPaths.AgeIdentityFile
+	config.Cfg.KubeconfigPaths.AgeIdentityFile = identityFile
+	t.Cleanup(func() { config.Cfg.KubeconfigPaths.AgeIdentityFile = old })
+
+	// An encrypted config that references a missing cluster
+	plaintext, err := clientcmd.Write(api.Config{
+		Clusters:  map[string]*api.Cluster{},
+		AuthInfos: map[string]*api.AuthInfo{"user": {}},
+		Contexts:  map[string]*api.Context{"ctx": {Cluster: "missing", AuthInfo: "user"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var encrypted bytes.Buffer
+	armorWriter := armor.NewWriter(&encrypted)
+	w, err := age.Encrypt(armorWriter, identity.Recipient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(w, bytes.NewReader(plaintext)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := armorWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(tmpDir, "config.age")
+	if err := os.WriteFile(path, encrypted.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	results := lintFiles([]string{path})
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	if !results[0].Encrypted {
+		t.Error("encrypted file is not reported as encrypted")
+	}
+	if !containsAny(results[0].Errors, "non-existent cluster") {
+		t.Errorf("expected the decrypted content to be linted, got errors: %v", results[0].Errors)
+	}
+}
+
 func TestExpandGlobs(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -18,9 +18,10 @@ func NewListCommand() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg := config.Cfg
 
-			fsProvider := kubeconfig.NewFileSystemProvider(cfg.KubeconfigPaths.Include, cfg.KubeconfigPaths.Exclude)
-
-			loader := kubeconfig.NewLoader(kubeconfig.WithProvider(fsProvider))
+			loader, err := kubeconfig.NewLoaderFromConfig(cfg)
+			if err != nil {
+				return err
+			}
 
 			kubeconfigs, err := loader.LoadAll()
 			if err != nil {
@@ -28,6 +29,14 @@ func NewListCommand() *cobra.Command {
 			}
 
 			for _, k8sconfig := range kubeconfigs {
+				if k8sconfig.FilePath == "" {
+					// Kept in memory by a provider that is not cached.
+					continue
+				}
+				if k8sconfig.Encrypted {
+					fmt.Println(k8sconfig.FilePath, "(encrypted)")
+					continue
+				}
 				fmt.Println(k8sconfig.FilePath)
 			}
 
@@ -1,13 +1,10 @@
 package cmd
 
 import (
-	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
-	"regexp"
-	"slices"
 	"strings"
 	"time"
 
@@ -15,8 +12,12 @@ import (
 	"github.com/spf13/cobra"
 	"k8s.io/client-go/tools/clientcmd/api"
 
+	"github.com/idebeijer/kubert/internal/audit"
 	"github.com/idebeijer/kubert/internal/config"
+	"github.com/idebeijer/kubert/internal/kubectl"
 	"github.com/idebeijer/kubert/internal/kubert"
+	"github.com/idebeijer/kubert/internal/prompt"
+	"github.com/idebeijer/kubert/internal/protection"
 	"github.com/idebeijer/kubert/internal/state"
 	"github.com/idebeijer/kubert/internal/util"
 )
@@ -29,11 +30,13 @@ type KubectlOptions struct {
 
 	Args []string
 
-	Config             config.Config
-	StateManager       func() (*state.Manager, error)
-	ClientConfigLoader func() (*api.Config, error)
-	CommandRunner      func([]string) error
-	Prompter           func() bool
+	Config               config.Config
+	StateManager         func() (*state.Manager, error)
+	ClientConfigLoader   func() (*api.Config, error)
+	KubeconfigFileLoader func(path string) (*api.Config, error)
+	CommandRunner        func([]string) error
+	Prompter             func(mode prompt.Mode, context string) bool
+	AuditRecorder        func(audit.Entry) error
 }
 
 func NewKubectlOptions() *KubectlOptions {
@@ -43,14 +46,15 @@ func NewKubectlOptions() *KubectlOptions {
 
 		StateManager: state.NewManager,
 		ClientConfigLoader: func() (*api.Config, error) {
-			clientConfig, err := util.KubeClientConfig()
-			if err != nil {
-				return nil, err
-			}
-			return clientConfig, nil
+			return util.LoadKubeClientConfig("")
 		},
+		KubeconfigFileLoader: util.LoadKubeClientConfig,
 		CommandRunner: func(args []string) error {
-			kubectlCmd := exec.Command(kubectlBin, args...)
+			kubectlPath, err := kubert.LookPath(kubectlBin)
+			if err != nil {
+				return fmt.Errorf("kubectl not found in PATH")
+			}
+			kubectlCmd := exec.Command(kubectlPath, args...)
 			kubectlCmd.Stdin = os.Stdin
 			kubectlCmd.Stdout = os.Stdout
 			kubectlCmd.Stderr = os.Stderr
@@ -63,7 +67,8 @@ func NewKubectlOptions() *KubectlOptions {
 			}
 			return nil
 		},
-		Prompter: promptUserConfirmation,
+		Prompter:      prompt.Confirm,
+		AuditRecorder: audit.Record,
 	}
 }
 
@@ -76,7 +81,7 @@ func NewKubectlCommand() *cobra.Command {
 		Long:               `Wrapper for kubectl, to support context protection with "kubert protection".`,
 		DisableFlagParsing: true,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
-			_, err := exec.LookPath(kubectlBin)
+			_, err := kubert.LookPath(kubectlBin)
 			if err != nil {
 				return fmt.Errorf("kubectl not found in PATH")
 			}
@@ -117,43 +122,202 @@ func (o *KubectlOptions) Run() error {
 		return err
 	}
 
-	clientConfig, err := o.ClientConfigLoader()
+	command := kubectl.Parse(o.Args)
+	target, err := o.resolveTarget(command)
 	if err != nil {
 		return err
 	}
 
-	locked, err := isContextProtected(sm, clientConfig.CurrentContext, o.Config)
+	engine, err := protection.NewEngine(o.Config, sm)
 	if err != nil {
 		return err
 	}
 
-	if locked && isCommandProtected(o.Args, o.Config.Protection.Commands) {
-		// Protection is active and command is protected
-		if !o.Config.Protection.Prompt {
-			fmt.Fprintf(o.Out, "You tried to run the protected kubectl command \"%s\" in the protected context \"%s\".\n\n"+
-				"The command has not been executed and kubert will exit immediately.\n"+
-				"Exiting...\n", o.Args[0], clientConfig.CurrentContext)
-			return nil
-		}
+	decision, err := engine.Evaluate(protection.Request{
+		Context:       target.Context,
+		Namespace:     target.Namespace,
+		AllNamespaces: target.AllNamespaces,
+		Command:       command,
+	})
+	if err != nil {
+		return err
+	}
+
+	guard := guardedCommand{
+		tool:     kubectlBin,
+		verb:     command.Verb,
+		args:     o.Args,
+		target:   target,
+		out:      o.Out,
+		errOut:   o.ErrOut,
+		prompter: o.Prompter,
+		recorder: o.AuditRecorder,
+		preview:  func() { o.preview(command) },
+	}
+	if !guard.confirm(decision) {
+		return nil
+	}
+
+	return o.CommandRunner(o.Args)
+}
+
+// guardedCommand is an invocation of kubectl or another tool that protection is applied to.
+type guardedCommand struct {
+	tool   string
+	verb   string
+	args   []string
+	target commandTarget
+
+	out      io.Writer
+	errOut   io.Writer
+	prompter func(mode prompt.Mode, context string) bool
+	recorder func(audit.Entry) error
+
+	// preview shows what the command would change, nil if the tool has no preview.
+	preview func()
+}
 
+// confirm acts on a protection decision: it reports denied commands, asks for confirmation
+// when needed and records the outcome in the audit log. It reports whether the command may run.
+func (g guardedCommand) confirm(decision protection.Decision) bool {
+	switch decision.Action {
+	case protection.ActionDeny:
+		fmt.Fprintf(g.out, "You tried to run the protected %s command \"%s\" in the protected %s.\n%s\n"+
+			"The command has not been executed and kubert will exit immediately.\n"+
+			"Exiting...\n", g.tool, g.verb, protectedTarget(decision), ruleNote(decision))
+		g.record(decision, audit.DecisionDenied)
+		return false
+	case protection.ActionPrompt, protection.ActionPreview:
 		yellow := color.New(color.FgHiYellow).SprintFunc()
-		fmt.Fprintf(o.Out, "%s: you tried to run the protected kubectl command \"%s\" in the protected context \"%s\".\n\n",
-			yellow("WARNING"), o.Args[0], clientConfig.CurrentContext)
-		if !o.Prompter() {
-			fmt.Fprintln(o.Out, "Exiting...")
-			return nil
+		fmt.Fprintf(g.out, "%s: you tried to run the protected %s command \"%s\" in the protected %s.\n%s\n",
+			yellow("WARNING"), g.tool, g.verb, protectedTarget(decision), ruleNote(decision))
+		if decision.Action == protection.ActionPreview && g.preview != nil {
+			g.preview()
+		}
+		if !g.prompter(decision.Confirm, g.target.Context) {
+			g.record(decision, audit.DecisionPromptedNo)
+			fmt.Fprintln(g.out, "Exiting...")
+			return false
 		}
-		fmt.Fprintln(o.Out)
+		g.record(decision, audit.DecisionPromptedYes)
+		fmt.Fprintln(g.out)
+	default:
+		if audit.ShouldRecord(decision) {
+			g.record(decision, audit.DecisionAllowed)
+		}
+	}
+	return true
+}
+
+// record writes a decision to the audit log. A failure to write is reported but does not
+// stop the command.
+func (g guardedCommand) record(decision protection.Decision, outcome audit.Decision) {
+	if g.recorder == nil {
+		return
+	}
+	entry := audit.NewEntry(g.tool, g.args, g.target.Namespace, decision, outcome)
+	if err := g.recorder(entry); err != nil {
+		fmt.Fprintf(g.errOut, "Failed to write audit log: %v\n", err)
 	}
+}
 
-	return o.CommandRunner(o.Args)
+// preview shows what a command would change by running its diff or server-side dry run. A
+// failing preview is reported, the user can still decide whether to run the command.
+func (o *KubectlOptions) preview(command kubectl.Command) {
+	args, ok := kubectl.Preview(command)
+	if !ok {
+		fmt.Fprintf(o.Out, "No preview is available for \"%s\".\n\n", strings.Join(o.Args, " "))
+		return
+	}
+
+	fmt.Fprintf(o.Out, "Preview (kubectl %s):\n", strings.Join(args, " "))
+	if err := o.CommandRunner(args); err != nil {
+		fmt.Fprintf(o.ErrOut, "Failed to preview changes: %v\n", err)
+	}
+	fmt.Fprintln(o.Out)
+}
+
+// protectedTarget describes what is protected: the context, a namespace of the context or all
+// its namespaces.
+func protectedTarget(decision protection.Decision) string {
+	status := decision.Status
+	switch status.Namespace {
+	case "":
+		return fmt.Sprintf("context \"%s\"", status.Context)
+	case protection.AllNamespaces:
+		return fmt.Sprintf("namespaces of context \"%s\"", status.Context)
+	default:
+		return fmt.Sprintf("namespace \"%s\" of context \"%s\"", status.Namespace, status.Context)
+	}
+}
+
+// ruleNote returns a line naming the protection rule, profile and freeze behind a decision, if any.
+func ruleNote(decision protection.Decision) string {
+	var note string
+	switch {
+	case decision.Rule != "" && decision.Status.Profile != "":
+		note = fmt.Sprintf("This is enforced by protection rule \"%s\" of profile \"%s\".\n", decision.Rule, decision.Status.Profile)
+	case decision.Rule != "":
+		note = fmt.Sprintf("This is enforced by protection rule \"%s\".\n", decision.Rule)
+	case decision.Status.Profile != "":
+		note = fmt.Sprintf("This is enforced by protection profile \"%s\".\n", decision.Status.Profile)
+	}
+	if f := decision.Status.Freeze; f != nil {
+		note += fmt.Sprintf("Changes are frozen by freeze \"%s\" until %s.\n", f.Name, f.Until.Format(time.RFC3339))
+	}
+	return note
+}
+
+// commandTarget is the context and namespace a kubectl or tool invocation will talk to.
+type commandTarget struct {
+	Context       string
+	Namespace     string
+	AllNamespaces bool
+}
+
+// resolveTarget resolves the context and namespace kubectl will talk to, honoring the
+// --kubeconfig, --context, --namespace and --all-namespaces flags of the invocation the
+// same way kubectl does.
+func (o *KubectlOptions) resolveTarget(command kubectl.Command) (commandTarget, error) {
+	kubeconfigPath, _ := command.Flag("kubeconfig")
+	context, _ := command.Flag("context")
+	namespace, _ := command.Flag("namespace")
+	return resolveTarget(o.ClientConfigLoader, o.KubeconfigFileLoader, kubeconfigPath, context, namespace, command.BoolFlag("all-namespaces"))
 }
 
-func promptUserConfirmation() bool {
-	var response string
-	fmt.Print("Are you sure you want to continue? [y/N]: ")
-	_, _ = fmt.Scanln(&response)
-	return strings.ToLower(response) == "y" || strings.ToLower(response) == "yes"
+// resolveTarget resolves the context and namespace from the flags of an invocation, falling
+// back to the current context of the kubeconfig and the namespace configured for the context.
+func resolveTarget(loadDefault func() (*api.Config, error), loadFile func(path string) (*api.Config, error),
+	kubeconfigPath, context, namespace string, allNamespaces bool,
+) (commandTarget, error) {
+	var clientConfig *api.Config
+	var err error
+	if kubeconfigPath != "" {
+		clientConfig, err = loadFile(kubeconfigPath)
+		if err != nil {
+			return commandTarget{}, fmt.Errorf("failed to load kubeconfig %s: %w", kubeconfigPath, err)
+		}
+	} else {
+		clientConfig, err = loadDefault()
+		if err != nil {
+			return commandTarget{}, err
+		}
+	}
+
+	target := commandTarget{Context: clientConfig.CurrentContext, AllNamespaces: allNamespaces}
+	if context != "" {
+		target.Context = context
+	}
+
+	if namespace != "" {
+		target.Namespace = namespace
+	} else if ctx, ok := clientConfig.Contexts[target.Context]; ok && ctx.Namespace != "" {
+		target.Namespace = ctx.Namespace
+	} else {
+		target.Namespace = "default"
+	}
+
+	return target, nil
 }
 
 func validKubectlArgsFunction(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -166,7 +330,11 @@ func validKubectlArgsFunction(cmd *cobra.Command, args []string, toComplete stri
 	env = append(env, "COMP_LINE=kubectl "+strings.Join(append(args, toComplete), " "))
 	env = append(env, fmt.Sprintf("COMP_POINT=%d", len("kubectl ")+len(strings.Join(append(args, toComplete), " "))))
 
-	kubectlComp := exec.Command("kubectl", compCmd...)
+	kubectlPath, err := kubert.LookPath(kubectlBin)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	kubectlComp := exec.Command(kubectlPath, compCmd...)
 	kubectlComp.Env = env
 	out, err := kubectlComp.Output()
 	if err != nil {
@@ -185,47 +353,3 @@ func validKubectlArgsFunction(cmd *cobra.Command, args []string, toComplete stri
 	}
 	return validCompletions, cobra.ShellCompDirectiveDefault
 }
-
-func isCommandProtected(args []string, blockedCmds []string) bool {
-	if len(args) == 0 {
-		return false
-	}
-	return slices.Contains(blockedCmds, args[0])
-}
-
-func isContextProtected(sm *state.Manager, context string, cfg config.Config) (bool, error) {
-	// First check explicit protection and lift status via state manager
-	explicitlyProtected, err := sm.IsContextProtected(context)
-	if err != nil {
-		// Context doesn't exist in state, check regex-based default
-		var contextNotFoundError *state.ContextNotFoundError
-		if !errors.As(err, &contextNotFoundError) {
-			return false, err
-		}
-		// Fall through to regex check
-	} else {
-		// Check if there's an explicit protection setting
-		contextInfo, _ := sm.ContextInfo(context)
-		if contextInfo.Protected != nil {
-			return explicitlyProtected, nil
-		}
-		// If lift is active, protection is already handled by IsContextProtected
-		if contextInfo.ProtectedUntil != nil && time.Now().Before(*contextInfo.ProtectedUntil) {
-			return false, nil
-		}
-	}
-
-	// No explicit protection set, check regex-based default
-	if cfg.Protection.Regex != nil {
-		regex, err := regexp.Compile(*cfg.Protection.Regex)
-		if err != nil {
-			return false, fmt.Errorf("failed to compile regex: %w", err)
-		}
-
-		if regex.MatchString(context) {
-			return true, nil
-		}
-	}
-
-	return false, nil
-}
@@ -9,7 +9,10 @@ import (
 	"github.com/spf13/cobra"
 	"k8s.io/client-go/tools/clientcmd/api"
 
+	"github.com/idebeijer/kubert/internal/audit"
 	"github.com/idebeijer/kubert/internal/config"
+	"github.com/idebeijer/kubert/internal/kubectl"
+	"github.com/idebeijer/kubert/internal/prompt"
 	"github.com/idebeijer/kubert/internal/state"
 )
 
@@ -37,116 +40,6 @@ func TestKubectlOptions_Complete_SetsConfig(t *testing.T) {
 	}
 }
 
-func TestIsCommandProtected(t *testing.T) {
-	tests := []struct {
-		name        string
-		args        []string
-		blockedCmds []string
-		expected    bool
-	}{
-		{
-			name:        "command in blocked list",
-			args:        []string{"apply", "-f", "deployment.yaml"},
-			blockedCmds: []string{"apply", "delete", "edit"},
-			expected:    true,
-		},
-		{
-			name:        "command not in blocked list",
-			args:        []string{"get", "pods"},
-			blockedCmds: []string{"apply", "delete", "edit"},
-			expected:    false,
-		},
-		{
-			name:        "empty args",
-			args:        []string{},
-			blockedCmds: []string{"apply", "delete"},
-			expected:    false,
-		},
-		{
-			name:        "empty blocked list",
-			args:        []string{"apply"},
-			blockedCmds: []string{},
-			expected:    false,
-		},
-		{
-			name:        "case sensitive match",
-			args:        []string{"Apply"},
-			blockedCmds: []string{"apply"},
-			expected:    false,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := isCommandProtected(tt.args, tt.blockedCmds)
-			if result != tt.expected {
-				t.Errorf("isCommandProtected(%v, %v) = %v, want %v",
-					tt.args, tt.blockedCmds, result, tt.expected)
-			}
-		})
-	}
-}
-
-func TestIsContextProtected(t *testing.T) {
-	t.Run("context not in state, matches regex", func(t *testing.T) {
-		setupTestXDGDataHome(t)
-
-		sm, err := state.NewManager()
-		if err != nil {
-			t.Fatalf("Failed to create state manager: %v", err)
-		}
-
-		prodRegex := "^prod.*"
-		cfg := config.Config{
-			Protection: config.Protection{
-				Regex: &prodRegex,
-			},
-		}
-
-		// Test context that matches regex
-		protected, err := isContextProtected(sm, "prod-cluster", cfg)
-		if err != nil {
-			t.Errorf("Unexpected error: %v", err)
-		}
-		if !protected {
-			t.Error("Expected prod-cluster to be protected by regex")
-		}
-
-		// Test context that doesn't match regex
-		protected, err = isContextProtected(sm, "dev-cluster", cfg)
-		if err != nil {
-			t.Errorf("Unexpected error: %v", err)
-		}
-		if protected {
-			t.Error("Expected dev-cluster to not be protected")
-		}
-	})
-
-	t.Run("invalid regex", func(t *testing.T) {
-		setupTestXDGDataHome(t)
-
-		sm, err := state.NewManager()
-		if err != nil {
-			t.Fatalf("Failed to create state manager: %v", err)
-		}
-
-		invalidRegex := "["
-		cfg := config.Config{
-			Protection: config.Protection{
-				Regex: &invalidRegex,
-			},
-		}
-
-		_, err = isContextProtected(sm, "any-context", cfg)
-		if err == nil {
-			t.Error("Expected error for invalid regex, got nil")
-		}
-		if !strings.Contains(err.Error(), "failed to compile regex") {
-			t.Errorf("Unexpected error message: %v", err)
-		}
-	})
-}
-
 func TestKubectlOptions_Complete(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -218,7 +111,7 @@ func TestKubectlOptions_Run_Unprotected(t *testing.T) {
 			// Mock successful execution
 			return nil
 		},
-		Prompter: func() bool {
+		Prompter: func(prompt.Mode, string) bool {
 			t.Error("Prompter should not be called for unprotected context")
 			return false
 		},
@@ -280,7 +173,454 @@ func TestKubectlOptions_Run_ClientConfigError(t *testing.T) {
 	}
 }
 
-func TestPromptUserConfirmation(t *testing.T) {
-	// Skip for now. This function reads from stdin, can't easily test it without mocking stdin.
-	t.Skip("promptUserConfirmation requires stdin mocking")
+func TestKubectlOptions_Run_ProtectedWithLeadingFlags(t *testing.T) {
+	var buf bytes.Buffer
+	prodRegex := "^prod"
+
+	o := &KubectlOptions{
+		Out:    &buf,
+		ErrOut: &buf,
+		Args:   []string{"-n", "payments", "delete", "pod", "x"},
+		Config: config.Config{
+			Protection: config.Protection{
+				Regex:    &prodRegex,
+				Commands: []string{"delete"},
+				Prompt:   false,
+			},
+		},
+		StateManager: func() (*state.Manager, error) {
+			setupTestXDGDataHome(t)
+			return state.NewManager()
+		},
+		ClientConfigLoader: func() (*api.Config, error) {
+			return &api.Config{CurrentContext: "prod-cluster"}, nil
+		},
+		CommandRunner: func(args []string) error {
+			t.Error("CommandRunner should not be called for a protected command")
+			return nil
+		},
+	}
+
+	if err := o.Run(); err != nil {
+		t.Errorf("Run() returned unexpected error: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), `protected kubectl command "delete"`) {
+		t.Errorf("Expected protection message naming the verb, got: %s", buf.String())
+	}
+}
+
+func TestKubectlOptions_ResolveTarget(t *testing.T) {
+	shellConfig := &api.Config{
+		CurrentContext: "dev-cluster",
+		Contexts:       map[string]*api.Context{"dev-cluster": {Namespace: "team-a"}},
+	}
+	otherConfig := &api.Config{CurrentContext: "prod-cluster"}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected commandTarget
+		wantErr  bool
+	}{
+		{
+			name:     "current context of shell kubeconfig",
+			args:     []string{"delete", "pod", "x"},
+			expected: commandTarget{Context: "dev-cluster", Namespace: "team-a"},
+		},
+		{
+			name:     "context flag",
+			args:     []string{"--context", "prod-cluster", "delete", "pod", "x"},
+			expected: commandTarget{Context: "prod-cluster", Namespace: "default"},
+		},
+		{
+			name:     "context flag after verb",
+			args:     []string{"delete", "pod", "x", "--context=prod-cluster"},
+			expected: commandTarget{Context: "prod-cluster", Namespace: "default"},
+		},
+		{
+			name:     "kubeconfig flag",
+			args:     []string{"--kubeconfig", "/other/config", "apply", "-f", "."},
+			expected: commandTarget{Context: "prod-cluster", Namespace: "default"},
+		},
+		{
+			name:     "kubeconfig and context flags",
+			args:     []string{"--kubeconfig", "/other/config", "--context", "staging", "apply", "-f", "."},
+			expected: commandTarget{Context: "staging", Namespace: "default"},
+		},
+		{
+			name:     "namespace flag",
+			args:     []string{"-n", "payments", "delete", "pod", "x"},
+			expected: commandTarget{Context: "dev-cluster", Namespace: "payments"},
+		},
+		{
+			name:     "all namespaces",
+			args:     []string{"delete", "pods", "-A", "--all"},
+			expected: commandTarget{Context: "dev-cluster", Namespace: "team-a", AllNamespaces: true},
+		},
+		{
+			name:    "unreadable kubeconfig flag",
+			args:    []string{"--kubeconfig", "/missing/config", "apply", "-f", "."},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &KubectlOptions{
+				ClientConfigLoader: func() (*api.Config, error) {
+					return shellConfig, nil
+				},
+				KubeconfigFileLoader: func(path string) (*api.Config, error) {
+					if path == "/other/config" {
+						return otherConfig, nil
+					}
+					return nil, errors.New("no such file")
+				},
+			}
+
+			got, err := o.resolveTarget(kubectl.Parse(tt.args))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveTarget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("resolveTarget() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestKubectlOptions_Run_ContextFlagTargetsProtectedContext(t *testing.T) {
+	var buf bytes.Buffer
+	prodRegex := "^prod"
+
+	o := &KubectlOptions{
+		Out:    &buf,
+		ErrOut: &buf,
+		Args:   []string{"--context", "prod-cluster", "delete", "pod", "x"},
+		Config: config.Config{
+			Protection: config.Protection{
+				Regex:    &prodRegex,
+				Commands: []string{"delete"},
+				Prompt:   false,
+			},
+		},
+		StateManager: func() (*state.Manager, error) {
+			setupTestXDGDataHome(t)
+			return state.NewManager()
+		},
+		ClientConfigLoader: func() (*api.Config, error) {
+			return &api.Config{CurrentContext: "dev-cluster"}, nil
+		},
+		CommandRunner: func(args []string) error {
+			t.Error("CommandRunner should not be called when --context targets a protected context")
+			return nil
+		},
+	}
+
+	if err := o.Run(); err != nil {
+		t.Errorf("Run() returned unexpected error: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), `protected context "prod-cluster"`) {
+		t.Errorf("Expected protection message naming the target context, got: %s", buf.String())
+	}
+}
+
+func TestKubectlOptions_Run_RuleAllowsCommand(t *testing.T) {
+	var buf bytes.Buffer
+	prodRegex := "^prod"
+	ran := false
+
+	o := &KubectlOptions{
+		Out:    &buf,
+		ErrOut: &buf,
+		Args:   []string{"delete", "pod", "web-0"},
+		Config: config.Config{
+			Protection: config.Protection{
+				Regex:    &prodRegex,
+				Commands: []string{"delete"},
+				Prompt:   true,
+				Rules: []config.ProtectionRule{
+					{Name: "no-namespace-delete", Verbs: []string{"delete"}, Resources: []string{"namespace"}, Action: "deny"},
+					{Name: "pod-delete", Verbs: []string{"delete"}, Resources: []string{"pod"}, Action: "allow"},
+				},
+			},
+		},
+		StateManager: func() (*state.Manager, error) {
+			setupTestXDGDataHome(t)
+			return state.NewManager()
+		},
+		ClientConfigLoader: func() (*api.Config, error) {
+			return &api.Config{CurrentContext: "prod-cluster"}, nil
+		},
+		CommandRunner: func(args []string) error {
+			ran = true
+			return nil
+		},
+		Prompter: func(prompt.Mode, string) bool {
+			t.Error("Prompter should not be called for a command allowed by a rule")
+			return false
+		},
+	}
+
+	if err := o.Run(); err != nil {
+		t.Errorf("Run() returned unexpected error: %v", err)
+	}
+	if !ran {
+		t.Error("Expected command allowed by rule to run")
+	}
+
+	o.Args = []string{"delete", "ns", "payments"}
+	ran = false
+	buf.Reset()
+	if err := o.Run(); err != nil {
+		t.Errorf("Run() returned unexpected error: %v", err)
+	}
+	if ran {
+		t.Error("Expected command denied by rule not to run")
+	}
+	if !strings.Contains(buf.String(), `protection rule "no-namespace-delete"`) {
+		t.Errorf("Expected output to name the deciding rule, got: %s", buf.String())
+	}
+}
+
+func TestKubectlOptions_Run_RecordsAudit(t *testing.T) {
+	prodRegex := "^prod"
+	tests := []struct {
+		name     string
+		context  string
+		args     []string
+		prompt   bool
+		answer   bool
+		expected audit.Decision
+		recorded bool
+	}{
+		{name: "denied", context: "prod", args: []string{"delete", "pod", "x"}, expected: audit.DecisionDenied, recorded: true},
+		{name: "prompted yes", context: "prod", args: []string{"delete", "pod", "x"}, prompt: true, answer: true, expected: audit.DecisionPromptedYes, recorded: true},
+		{name: "prompted no", context: "prod", args: []string{"delete", "pod", "x"}, prompt: true, expected: audit.DecisionPromptedNo, recorded: true},
+		{name: "allowed in protected context", context: "prod", args: []string{"get", "pods"}, expected: audit.DecisionAllowed, recorded: true},
+		{name: "unprotected context", context: "dev", args: []string{"delete", "pod", "x"}, recorded: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			var entries []audit.Entry
+			o := &KubectlOptions{
+				Out:    &buf,
+				ErrOut: &buf,
+				Args:   tt.args,
+				Config: config.Config{
+					Protection: config.Protection{Regex: &prodRegex, Commands: []string{"delete"}, Prompt: tt.prompt},
+				},
+				StateManager: func() (*state.Manager, error) {
+					return &state.Manager{}, nil
+				},
+				ClientConfigLoader: func() (*api.Config, error) {
+					return &api.Config{CurrentContext: tt.context}, nil
+				},
+				CommandRunner: func([]string) error { return nil },
+				Prompter:      func(prompt.Mode, string) bool { return tt.answer },
+				AuditRecorder: func(e audit.Entry) error {
+					entries = append(entries, e)
+					return nil
+				},
+			}
+
+			if err := o.Run(); err != nil {
+				t.Fatalf("Run() returned unexpected error: %v", err)
+			}
+
+			if !tt.recorded {
+				if len(entries) != 0 {
+					t.Errorf("Expected no audit entries, got %+v", entries)
+				}
+				return
+			}
+			if len(entries) != 1 {
+				t.Fatalf("Expected 1 audit entry, got %d", len(entries))
+			}
+			e := entries[0]
+			if e.Decision != tt.expected || e.Context != tt.context || e.Namespace != "default" || e.Command != "kubectl" {
+				t.Errorf("audit entry = %+v, want decision %s", e, tt.expected)
+			}
+			if strings.Join(e.Args, " ") != strings.Join(tt.args, " ") {
+				t.Errorf("audit entry args = %v, want %v", e.Args, tt.args)
+			}
+		})
+	}
+}
+
+func TestKubectlOptions_Run_AuditFailureDoesNotBlock(t *testing.T) {
+	var buf bytes.Buffer
+	prodRegex := "^prod"
+	ran := false
+
+	o := &KubectlOptions{
+		Out:    &buf,
+		ErrOut: &buf,
+		Args:   []string{"get", "pods"},
+		Config: config.Config{Protection: config.Protection{Regex: &prodRegex}},
+		StateManager: func() (*state.Manager, error) {
+			return &state.Manager{}, nil
+		},
+		ClientConfigLoader: func() (*api.Config, error) {
+			return &api.Config{CurrentContext: "prod"}, nil
+		},
+		CommandRunner: func([]string) error {
+			ran = true
+			return nil
+		},
+		AuditRecorder: func(audit.Entry) error { return errors.New("disk full") },
+	}
+
+	if err := o.Run(); err != nil {
+		t.Fatalf("Run() returned unexpected error: %v", err)
+	}
+	if !ran {
+		t.Error("Expected command to run when the audit log cannot be written")
+	}
+	if !strings.Contains(buf.String(), "Failed to write audit log") {
+		t.Errorf("Expected audit failure to be reported, got: %s", buf.String())
+	}
+}
+
+func TestKubectlOptions_Run_Preview(t *testing.T) {
+	prodRegex := "^prod"
+	tests := []struct {
+		name     string
+		args     []string
+		answer   bool
+		previews int
+		expected [][]string
+	}{
+		{
+			name:     "diff before confirmed apply",
+			args:     []string{"apply", "-f", "deploy.yaml"},
+			answer:   true,
+			previews: 1,
+			expected: [][]string{
+				{"diff", "--filename=deploy.yaml"},
+				{"apply", "-f", "deploy.yaml"},
+			},
+		},
+		{
+			name:     "dry run before declined patch",
+			args:     []string{"patch", "deploy", "web", "-p", "{}"},
+			previews: 1,
+			expected: [][]string{{"patch", "deploy", "web", "-p", "{}", "--dry-run=server", "--output=yaml"}},
+		},
+		{
+			name:     "no preview available",
+			args:     []string{"apply", "-f", "-"},
+			answer:   true,
+			expected: [][]string{{"apply", "-f", "-"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			var runs [][]string
+			promptedAfter := -1
+			o := &KubectlOptions{
+				Out:    &buf,
+				ErrOut: &buf,
+				Args:   tt.args,
+				Config: config.Config{
+					Protection: config.Protection{
+						Regex:    &prodRegex,
+						Commands: []string{"apply", "patch"},
+						Prompt:   true,
+						Rules: []config.ProtectionRule{
+							{Name: "preview-changes", Verbs: []string{"apply", "patch"}, Action: "preview"},
+						},
+					},
+				},
+				StateManager: func() (*state.Manager, error) {
+					return &state.Manager{}, nil
+				},
+				ClientConfigLoader: func() (*api.Config, error) {
+					return &api.Config{CurrentContext: "prod"}, nil
+				},
+				CommandRunner: func(args []string) error {
+					runs = append(runs, args)
+					return nil
+				},
+				Prompter: func(prompt.Mode, string) bool {
+					promptedAfter = len(runs)
+					return tt.answer
+				},
+			}
+
+			if err := o.Run(); err != nil {
+				t.Fatalf("Run() returned unexpected error: %v", err)
+			}
+
+			if promptedAfter != tt.previews {
+				t.Errorf("Prompted after %d commands, want after %d previews", promptedAfter, tt.previews)
+			}
+			if len(runs) != len(tt.expected) {
+				t.Fatalf("CommandRunner called with %v, want %v", runs, tt.expected)
+			}
+			for i := range runs {
+				if strings.Join(runs[i], " ") != strings.Join(tt.expected[i], " ") {
+					t.Errorf("CommandRunner call %d = %v, want %v", i, runs[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestKubectlOptions_Run_ProtectedNamespace(t *testing.T) {
+	nsRegex := "^payments$"
+	tests := []struct {
+		name     string
+		args     []string
+		executed bool
+		message  string
+	}{
+		{name: "protected namespace", args: []string{"delete", "pod", "x", "-n", "payments"}, message: `namespace "payments" of context "dev"`},
+		{name: "all namespaces", args: []string{"delete", "pods", "-l", "app=x", "-A"}, message: `namespaces of context "dev"`},
+		{name: "kubeconfig namespace", args: []string{"delete", "pod", "x"}, executed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			executed := false
+			o := &KubectlOptions{
+				Out:    &buf,
+				ErrOut: &buf,
+				Args:   tt.args,
+				Config: config.Config{
+					Protection: config.Protection{NamespaceRegex: &nsRegex, Commands: []string{"delete"}},
+				},
+				StateManager: func() (*state.Manager, error) {
+					return &state.Manager{}, nil
+				},
+				ClientConfigLoader: func() (*api.Config, error) {
+					return &api.Config{
+						CurrentContext: "dev",
+						Contexts:       map[string]*api.Context{"dev": {Namespace: "scratch"}},
+					}, nil
+				},
+				CommandRunner: func([]string) error {
+					executed = true
+					return nil
+				},
+			}
+
+			if err := o.Run(); err != nil {
+				t.Fatalf("Run() returned unexpected error: %v", err)
+			}
+			if executed != tt.executed {
+				t.Errorf("executed = %v, want %v\n%s", executed, tt.executed, buf.String())
+			}
+			if tt.message != "" && !strings.Contains(buf.String(), "in the protected "+tt.message) {
+				t.Errorf("Expected output to name the %s, got: %s", tt.message, buf.String())
+			}
+		})
+	}
 }
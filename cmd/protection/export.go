@@ -0,0 +1,68 @@
+package protection
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v4"
+
+	"github.com/idebeijer/kubert/internal/config"
+	"github.com/idebeijer/kubert/internal/protection"
+	"github.com/idebeijer/kubert/internal/state"
+)
+
+func NewExportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export explicit protection overrides",
+		Long: `Export the explicit protection overrides of all contexts: protected and unprotected contexts,
+assigned profiles and namespace overrides. Lifts are not exported.
+
+The output can be committed to a repository and applied by others with "kubert protection import".
+Profiles themselves are defined in the config, make sure the profiles used are defined there too.`,
+		Example: `  # Export the overrides to a file
+  kubert protection export > protection.yaml`,
+		Args: cobra.NoArgs,
+		RunE: runExport,
+	}
+
+	cmd.Flags().StringP("output", "o", "yaml", "Output format (yaml or json)")
+	return cmd
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+	if output != "yaml" && output != "json" {
+		return fmt.Errorf("invalid output format: %s", output)
+	}
+
+	sm, err := state.NewManager()
+	if err != nil {
+		return err
+	}
+
+	engine, err := protection.NewEngine(config.Cfg, sm)
+	if err != nil {
+		return err
+	}
+
+	overrides := engine.ExportOverrides()
+
+	out := cmd.OutOrStdout()
+	if output == "json" {
+		data, err := json.MarshalIndent(overrides, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(data))
+		return nil
+	}
+
+	data, err := yaml.Marshal(overrides)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+	return err
+}
@@ -0,0 +1,95 @@
+package protection
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v4"
+
+	"github.com/idebeijer/kubert/internal/config"
+	"github.com/idebeijer/kubert/internal/protection"
+	"github.com/idebeijer/kubert/internal/state"
+)
+
+func NewImportCommand() *cobra.Command {
+	var replace bool
+
+	cmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Import explicit protection overrides",
+		Long: `Import explicit protection overrides written by "kubert protection export". Use "-" to read
+from stdin.
+
+By default the overrides are merged into the existing ones: contexts and namespaces in the file
+get the settings from the file, everything else is left as is. With --replace, all existing
+explicit overrides are removed first, so only the ones from the file apply. Active lifts are
+kept either way. Profiles used in the file have to be defined in the config.
+
+A merge cannot remove a profile: an empty or missing profile keeps the profile a context
+already has. Use --replace, or "kubert protection protect --profile ''", to remove it.`,
+		Example: `  # Apply the overrides shared by the team
+  kubert protection import protection.yaml
+
+  # Make the local overrides exactly those of the team
+  kubert protection import protection.yaml --replace`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImport(cmd, args[0], replace)
+		},
+	}
+
+	cmd.Flags().BoolVar(&replace, "replace", false, "Remove all existing explicit overrides before importing")
+	return cmd
+}
+
+func runImport(cmd *cobra.Command, path string, replace bool) error {
+	overrides, err := readOverrides(cmd.InOrStdin(), path)
+	if err != nil {
+		return err
+	}
+
+	sm, err := state.NewManager()
+	if err != nil {
+		return err
+	}
+
+	engine, err := protection.NewEngine(config.Cfg, sm)
+	if err != nil {
+		return err
+	}
+
+	if err := engine.ImportOverrides(overrides, replace); err != nil {
+		return err
+	}
+
+	mode := "merged"
+	if replace {
+		mode = "replaced existing overrides"
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Imported protection overrides for %d contexts (%s)\n", len(overrides.Contexts), mode)
+	return nil
+}
+
+// readOverrides reads overrides from a YAML or JSON file, or from stdin if path is "-".
+func readOverrides(stdin io.Reader, path string) (protection.Overrides, error) {
+	r := stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return protection.Overrides{}, fmt.Errorf("failed to open overrides: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var overrides protection.Overrides
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&overrides); err != nil && !errors.Is(err, io.EOF) {
+		return protection.Overrides{}, fmt.Errorf("failed to parse overrides from %s: %w", path, err)
+	}
+	return overrides, nil
+}
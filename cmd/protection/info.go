@@ -2,55 +2,107 @@ package protection
 
 import (
 	"fmt"
-	"regexp"
+	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 
 	"github.com/idebeijer/kubert/internal/config"
+	"github.com/idebeijer/kubert/internal/kubectl"
 	"github.com/idebeijer/kubert/internal/kubert"
+	"github.com/idebeijer/kubert/internal/protection"
 	"github.com/idebeijer/kubert/internal/state"
 	"github.com/idebeijer/kubert/internal/util"
 )
 
 func NewInfoCommand() *cobra.Command {
+	var target contextTarget
+
 	cmd := &cobra.Command{
-		Use:   "info",
+		Use:   "info [-- kubectl-args...]",
 		Short: "Show protection status for current context",
-		Long:  `Show the protection status for the current context, including explicit overrides and lift status.`,
-		PreRunE: func(cmd *cobra.Command, args []string) error {
-			return kubert.ShellPreFlightCheck()
+		Long: `Show the protection status for the current context, including explicit overrides and lift status.
+
+Pass a kubectl command after "--" to see what protection would do with it.
+Use --context to show other contexts, this does not need an active kubert shell.`,
+		Example: `  # Show protection status
+  kubert protection info
+
+  # Show what would happen to a command
+  kubert protection info -- delete namespace payments
+
+  # Show protection status of all production contexts
+  kubert protection info --context "prod-*"`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runInfo(cmd, args, &target)
 		},
-		RunE: runInfo,
 	}
 
 	cmd.Flags().StringP("output", "o", "", "Output format (short)")
+	addContextFlags(cmd, &target)
 	return cmd
 }
 
-func runInfo(cmd *cobra.Command, args []string) error {
+func runInfo(cmd *cobra.Command, args []string, target *contextTarget) error {
 	output, _ := cmd.Flags().GetString("output")
 	if output != "" && output != "short" {
 		return fmt.Errorf("invalid output format: %s", output)
 	}
 
-	cfg := config.Cfg
+	contexts, err := target.resolve()
+	if err != nil {
+		return err
+	}
+
 	sm, err := state.NewManager()
 	if err != nil {
 		return err
 	}
 
-	clientConfig, err := util.KubeClientConfig()
+	engine, err := protection.NewEngine(config.Cfg, sm)
 	if err != nil {
 		return err
 	}
 
-	return protectionStatus(sm, clientConfig.CurrentContext, cfg, output)
+	for i, ctx := range contexts {
+		if i > 0 && output != "short" {
+			fmt.Println()
+		}
+		if output == "short" && len(contexts) > 1 {
+			// Same format as "kubert protection list -o short".
+			fmt.Printf("%s ", ctx.name)
+		}
+
+		if err := protectionStatus(engine, ctx.name, output); err != nil {
+			return err
+		}
+		if len(target.patterns) == 0 && output != "short" {
+			printReadonly()
+		}
+
+		if len(args) == 0 || output == "short" {
+			continue
+		}
+
+		namespace := ctx.namespace
+		if namespace == "" {
+			namespace = "default"
+		}
+		if err := commandDecision(engine, ctx.name, namespace, args); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func protectionStatus(sm *state.Manager, context string, cfg config.Config, output string) error {
-	contextInfo, _ := sm.ContextInfo(context)
+func protectionStatus(engine *protection.Engine, context string, output string) error {
+	status, err := engine.Status(context)
+	if err != nil {
+		return err
+	}
 	isShort := output == "short"
 
 	if !isShort {
@@ -58,37 +110,151 @@ func protectionStatus(sm *state.Manager, context string, cfg config.Config, outp
 		fmt.Printf("Context: %s\n\n", cyan(context))
 	}
 
-	if hasActiveLift(contextInfo) {
-		printLiftedStatus(contextInfo, isShort)
-		return nil
+	switch status.Source {
+	case protection.SourceLift:
+		printLiftedStatus(*status.LiftedUntil, isShort)
+		printLiftDetails(status.Lift, isShort)
+	case protection.SourceFreeze:
+		printStatus(false, "change freeze", isShort)
+	case protection.SourceExplicit:
+		printExplicitOverride(status.Protected, isShort)
+	case protection.SourceProfile:
+		printStatus(false, "matches context profile regex", isShort)
+		if !isShort {
+			fmt.Printf("   Regex: %s\n", status.Regex)
+		}
+	case protection.SourceRegex:
+		printRegexStatus(status, isShort)
+	default:
+		printStatus(true, "no protection configured", isShort)
 	}
 
-	if contextInfo.Protected != nil {
-		printExplicitOverride(*contextInfo.Protected, isShort)
+	if isShort {
 		return nil
 	}
+	if f := status.Freeze; f != nil {
+		fmt.Printf("   Freeze: protected because of freeze '%s' until %s\n", f.Name, f.Until.Format(time.RFC3339))
+		if f.Deny {
+			fmt.Println("   Protected commands are denied during the freeze, lifts do not apply")
+		}
+	}
+	if status.Source != protection.SourceLift && status.LiftedUntil != nil {
+		yellow := color.New(color.FgHiYellow).SprintFunc()
+		fmt.Printf("%s Lifted: %s until %s\n", yellow("⏳"), strings.Join(status.Lift.Commands, ", "), status.LiftedUntil.Format(time.RFC3339))
+		printLiftDetails(status.Lift, isShort)
+	}
+	if status.Profile != "" {
+		fmt.Printf("   Profile: %s\n", status.Profile)
+	}
+	if rules := engine.Rules(status.Profile); len(rules) > 0 && status.Protected {
+		fmt.Printf("   Rules: %s\n", strings.Join(rules, ", "))
+	}
+	printNamespaces(engine, context)
+	return nil
+}
 
-	if cfg.Protection.Regex != nil {
-		return printRegexStatus(context, *cfg.Protection.Regex, isShort)
+// printReadonly prints the impersonated identity when the current shell is read-only.
+func printReadonly() {
+	if os.Getenv(kubert.ShellReadonlyEnvVar) != "1" {
+		return
+	}
+	identity := "unknown identity"
+	if clientConfig, err := util.KubeClientConfig(); err == nil {
+		if ctx, ok := clientConfig.Contexts[clientConfig.CurrentContext]; ok {
+			if authInfo, ok := clientConfig.AuthInfos[ctx.AuthInfo]; ok && authInfo.Impersonate != "" {
+				identity = "user " + authInfo.Impersonate
+				if len(authInfo.ImpersonateGroups) > 0 {
+					identity += ", groups " + strings.Join(authInfo.ImpersonateGroups, ", ")
+				}
+			}
+		}
 	}
+	fmt.Printf("   Readonly: shell impersonates %s\n", identity)
+}
 
-	printStatus(true, "no protection configured", isShort)
-	return nil
+// printNamespaces prints the namespace overrides of the context and the namespace regex.
+func printNamespaces(engine *protection.Engine, context string) {
+	overrides := engine.NamespaceOverrides(context)
+	if len(overrides) > 0 {
+		namespaces := make([]string, 0, len(overrides))
+		for namespace := range overrides {
+			namespaces = append(namespaces, namespace)
+		}
+		sort.Strings(namespaces)
+
+		var details []string
+		for _, namespace := range namespaces {
+			status := "unprotected"
+			if overrides[namespace] {
+				status = "protected"
+			}
+			details = append(details, fmt.Sprintf("%s (%s)", namespace, status))
+		}
+		fmt.Printf("   Namespaces: %s\n", strings.Join(details, ", "))
+	}
+	if regex := engine.NamespaceRegex(); regex != "" {
+		fmt.Printf("   Namespace regex: %s\n", regex)
+	}
 }
 
-func hasActiveLift(info state.ContextInfo) bool {
-	return info.ProtectedUntil != nil && time.Now().Before(*info.ProtectedUntil)
+func commandDecision(engine *protection.Engine, context, namespace string, args []string) error {
+	command := kubectl.Parse(args)
+	if ns, ok := command.Flag("namespace"); ok && ns != "" {
+		namespace = ns
+	}
+
+	decision, err := engine.Evaluate(protection.Request{
+		Context:       context,
+		Namespace:     namespace,
+		AllNamespaces: command.BoolFlag("all-namespaces"),
+		Command:       command,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nCommand: kubectl %s\n", strings.Join(args, " "))
+	fmt.Printf("   Action: %s (%s)\n", decision.Action, decision.Reason)
+	switch decision.Status.Namespace {
+	case "":
+	case protection.AllNamespaces:
+		fmt.Println("   Namespace: targets all namespaces, including protected namespaces")
+	default:
+		fmt.Printf("   Namespace: %s is protected\n", decision.Status.Namespace)
+	}
+	if decision.Rule != "" {
+		fmt.Printf("   Rule: %s\n", decision.Rule)
+	}
+	if decision.Status.Profile != "" {
+		fmt.Printf("   Profile: %s\n", decision.Status.Profile)
+	}
+	return nil
 }
 
-func printLiftedStatus(info state.ContextInfo, short bool) {
+func printLiftedStatus(until time.Time, short bool) {
 	if short {
 		fmt.Println("lifted")
 		return
 	}
 
 	yellow := color.New(color.FgHiYellow).SprintFunc()
-	fmt.Printf("%s Status: %s until %s\n", yellow("⏳"), yellow("LIFTED"), info.ProtectedUntil.Format(time.RFC3339))
-	fmt.Printf("   Remaining: %s\n", time.Until(*info.ProtectedUntil).Round(time.Second))
+	fmt.Printf("%s Status: %s until %s\n", yellow("⏳"), yellow("LIFTED"), until.Format(time.RFC3339))
+	fmt.Printf("   Remaining: %s\n", time.Until(until).Round(time.Second))
+}
+
+// printLiftDetails prints the reason, scope and author of a lift. Lifts made before these
+// were recorded have no details.
+func printLiftDetails(lift *state.LiftInfo, short bool) {
+	if short || lift == nil {
+		return
+	}
+	if lift.Reason != "" {
+		fmt.Printf("   Reason: %s\n", lift.Reason)
+	}
+	fmt.Printf("   Scope: %s\n", liftScope(lift))
+	if lift.By != "" {
+		fmt.Printf("   Lifted by: %s at %s\n", lift.By, lift.At.Format(time.RFC3339))
+	}
 }
 
 func printExplicitOverride(protected bool, short bool) {
@@ -98,21 +264,15 @@ func printExplicitOverride(protected bool, short bool) {
 	}
 }
 
-func printRegexStatus(context, pattern string, short bool) error {
-	regex, err := regexp.Compile(pattern)
-	if err != nil {
-		return fmt.Errorf("failed to compile regex: %w", err)
-	}
-
-	if regex.MatchString(context) {
+func printRegexStatus(status protection.Status, short bool) {
+	if status.Protected {
 		printStatus(false, "matches default regex", short)
 		if !short {
-			fmt.Printf("   Regex: %s\n", pattern)
+			fmt.Printf("   Regex: %s\n", status.Regex)
 		}
 	} else {
 		printStatus(true, "does not match default regex", short)
 	}
-	return nil
 }
 
 func printStatus(unprotected bool, reason string, short bool) {
@@ -2,6 +2,8 @@ package protection
 
 import (
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -12,6 +14,13 @@ import (
 )
 
 func NewLiftCommand() *cobra.Command {
+	var (
+		reason   string
+		commands []string
+		session  bool
+		target   contextTarget
+	)
+
 	cmd := &cobra.Command{
 		Use:   "lift <duration>",
 		Short: "Temporarily lift protection for a duration",
@@ -20,13 +29,18 @@ func NewLiftCommand() *cobra.Command {
 The duration argument is required and specifies how long protection should be lifted.
 Examples: 5m (5 minutes), 1h (1 hour), 30s (30 seconds)
 
+A reason is required and is shown by "kubert protection info" together with who lifted
+protection. Use --command to lift protection only for some kubectl commands, and --session
+to lift it only for the current kubert shell. Use --context to lift protection of other
+contexts, this does not need an active kubert shell unless --session is used.
+
 After the duration expires, protection will automatically be restored.`,
 		Example: `  # Lift protection for 5 minutes
-  kubert protection lift 5m`,
+  kubert protection lift 5m --reason "rotate certificates"
+
+  # Only allow delete, and only in this shell
+  kubert protection lift 10m --reason "clean up stuck pods" --command delete --session`,
 		Args: cobra.ExactArgs(1),
-		PreRunE: func(cmd *cobra.Command, args []string) error {
-			return kubert.ShellPreFlightCheck()
-		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			duration, err := time.ParseDuration(args[0])
 			if err != nil {
@@ -37,25 +51,66 @@ After the duration expires, protection will automatically be restored.`,
 				return fmt.Errorf("duration must be positive, got %s", duration)
 			}
 
-			sm, err := state.NewManager()
-			if err != nil {
-				return err
+			if strings.TrimSpace(reason) == "" {
+				return fmt.Errorf("a reason is required")
+			}
+
+			lift := state.LiftInfo{
+				Reason:   reason,
+				Commands: commands,
+				By:       util.CurrentUser(),
+				At:       time.Now(),
+			}
+			if session {
+				if err := kubert.ShellPreFlightCheck(); err != nil {
+					return fmt.Errorf("--session requires an active kubert shell: %w", err)
+				}
+				lift.Session = os.Getenv(kubert.ShellKubeconfigEnvVar)
 			}
 
-			clientConfig, err := util.KubeClientConfig()
+			contexts, err := target.resolve()
 			if err != nil {
 				return err
 			}
 
-			until := time.Now().Add(duration)
-			if err := sm.LiftContextProtection(clientConfig.CurrentContext, until); err != nil {
+			sm, err := state.NewManager()
+			if err != nil {
 				return err
 			}
 
-			fmt.Printf("Protection lifted for context %q until %s\n", clientConfig.CurrentContext, until.Format(time.RFC3339))
+			until := lift.At.Add(duration)
+			for _, ctx := range contexts {
+				if err := sm.EnsureContextExists(ctx.name); err != nil {
+					return err
+				}
+
+				if err := sm.LiftContextProtection(ctx.name, until, lift); err != nil {
+					return err
+				}
+
+				fmt.Printf("Protection lifted for context %q until %s (%s)\n", ctx.name, until.Format(time.RFC3339), liftScope(&lift))
+			}
 			return nil
 		},
 	}
 
+	addContextFlags(cmd, &target)
+	cmd.Flags().StringVar(&reason, "reason", "", "Why protection is lifted (required)")
+	cmd.Flags().StringSliceVar(&commands, "command", nil, "Only lift protection for these kubectl commands (e.g. delete or \"rollout restart\")")
+	cmd.Flags().BoolVar(&session, "session", false, "Only lift protection for the current kubert shell")
+	_ = cmd.MarkFlagRequired("reason")
+
 	return cmd
 }
+
+// liftScope describes which commands and shells a lift applies to.
+func liftScope(lift *state.LiftInfo) string {
+	scope := "all commands"
+	if len(lift.Commands) > 0 {
+		scope = "commands " + strings.Join(lift.Commands, ", ")
+	}
+	if lift.Session != "" {
+		return scope + " in one kubert shell"
+	}
+	return scope + " in all shells"
+}
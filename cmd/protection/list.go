@@ -0,0 +1,170 @@
+package protection
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v4"
+
+	"github.com/idebeijer/kubert/internal/config"
+	"github.com/idebeijer/kubert/internal/kubeconfig"
+	"github.com/idebeijer/kubert/internal/protection"
+	"github.com/idebeijer/kubert/internal/state"
+)
+
+type listEntry struct {
+	Context      string     `json:"context" yaml:"context"`
+	Status       string     `json:"status" yaml:"status"`
+	Source       string     `json:"source" yaml:"source"`
+	Profile      string     `json:"profile,omitempty" yaml:"profile,omitempty"`
+	LiftedUntil  *time.Time `json:"liftedUntil,omitempty" yaml:"liftedUntil,omitempty"`
+	LiftReason   string     `json:"liftReason,omitempty" yaml:"liftReason,omitempty"`
+	LiftCommands []string   `json:"liftCommands,omitempty" yaml:"liftCommands,omitempty"`
+	Error        string     `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+func NewListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "Show protection status for all contexts",
+		Long: `Show the protection status of every context in the configured kubeconfig files, combining
+the default regex, context profiles, explicit overrides and lifts. Contexts whose status cannot
+be determined, e.g. because they use a removed profile, are shown with the status "error".
+
+Unlike "info", this does not need an active kubert shell.`,
+		Example: `  # Show protection status for all contexts
+  kubert protection list
+
+  # List protected contexts by name
+  kubert protection list -o short | awk '$2 == "protected" { print $1 }'`,
+		Aliases: []string{"ls"},
+		Args:    cobra.NoArgs,
+		RunE:    runList,
+	}
+
+	cmd.Flags().StringP("output", "o", "", "Output format (json, yaml or short)")
+	return cmd
+}
+
+func runList(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+	switch output {
+	case "", "json", "yaml", "short":
+	default:
+		return fmt.Errorf("invalid output format: %s", output)
+	}
+
+	contexts, err := loadContexts()
+	if err != nil {
+		return err
+	}
+
+	sm, err := state.NewManager()
+	if err != nil {
+		return err
+	}
+
+	engine, err := protection.NewEngine(config.Cfg, sm)
+	if err != nil {
+		return err
+	}
+
+	entries := listEntries(engine, contexts)
+
+	out := cmd.OutOrStdout()
+	switch output {
+	case "json":
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(data))
+		return nil
+	case "yaml":
+		data, err := yaml.Marshal(entries)
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(data)
+		return err
+	case "short":
+		for _, e := range entries {
+			fmt.Fprintf(out, "%s %s\n", e.Context, e.Status)
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTEXT\tSTATUS\tSOURCE\tPROFILE\tLIFTED UNTIL")
+	for _, e := range entries {
+		lifted := ""
+		if e.LiftedUntil != nil {
+			lifted = e.LiftedUntil.Local().Format(time.RFC3339)
+			if len(e.LiftCommands) > 0 {
+				lifted += " (" + strings.Join(e.LiftCommands, ", ") + ")"
+			}
+		}
+		source := e.Source
+		if e.Error != "" {
+			source = "error: " + e.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.Context, e.Status, source, e.Profile, lifted)
+	}
+	return w.Flush()
+}
+
+// listEntries returns the protection status of each context, sorted by name. Contexts that
+// appear in more than one kubeconfig are listed once. Contexts whose status cannot be
+// determined, e.g. because their profile was removed, are listed with the status "error".
+func listEntries(engine *protection.Engine, contexts []kubeconfig.Context) []listEntry {
+	seen := make(map[string]bool, len(contexts))
+	entries := make([]listEntry, 0, len(contexts))
+	for _, ctx := range contexts {
+		if seen[ctx.Name] {
+			continue
+		}
+		seen[ctx.Name] = true
+
+		status, err := engine.Status(ctx.Name)
+		if err != nil {
+			// An unknown profile should not hide the context, nor the other contexts.
+			entries = append(entries, listEntry{Context: ctx.Name, Status: "error", Error: err.Error()})
+			continue
+		}
+
+		entry := listEntry{
+			Context:     ctx.Name,
+			Status:      statusName(status),
+			Source:      string(status.Source),
+			Profile:     status.Profile,
+			LiftedUntil: status.LiftedUntil,
+		}
+		if status.Lift != nil {
+			entry.LiftReason = status.Lift.Reason
+			entry.LiftCommands = status.Lift.Commands
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Context < entries[j].Context
+	})
+	return entries
+}
+
+// statusName returns the status as printed by "info -o short".
+func statusName(status protection.Status) string {
+	switch {
+	case status.Source == protection.SourceLift:
+		return "lifted"
+	case status.Protected:
+		return "protected"
+	default:
+		return "unprotected"
+	}
+}
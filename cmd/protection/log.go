@@ -0,0 +1,116 @@
+package protection
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/idebeijer/kubert/internal/audit"
+)
+
+func NewLogCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "log",
+		Short: "Show the audit log of commands in protected contexts",
+		Long: `Show the audit log of commands run through "kubert kubectl" and "kubert exec" in protected contexts.
+
+Every command that hits a protected context is recorded with the user, context, namespace,
+arguments, matched rule, decision and any active lift. Decisions are allowed, prompted-yes,
+prompted-no and denied.`,
+		Example: `  # Show everything
+  kubert protection log
+
+  # Show denied commands in production contexts during the last day
+  kubert protection log --context "prod*" --since 24h --decision denied
+
+  # Show commands since a date as JSON lines
+  kubert protection log --since 2026-03-01 -o json`,
+		Args: cobra.NoArgs,
+		RunE: runLog,
+	}
+
+	cmd.Flags().String("context", "", "Only show entries for contexts matching this glob pattern")
+	cmd.Flags().String("since", "", "Only show entries since a duration ago (24h), a date (2006-01-02) or an RFC 3339 timestamp")
+	cmd.Flags().String("decision", "", "Only show entries with this decision (allowed, prompted-yes, prompted-no, denied)")
+	cmd.Flags().StringP("output", "o", "", "Output format (json)")
+
+	_ = cmd.RegisterFlagCompletionFunc("decision", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{
+			string(audit.DecisionAllowed),
+			string(audit.DecisionPromptedYes),
+			string(audit.DecisionPromptedNo),
+			string(audit.DecisionDenied),
+		}, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	return cmd
+}
+
+func runLog(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+	if output != "" && output != "json" {
+		return fmt.Errorf("invalid output format: %s", output)
+	}
+
+	var filter audit.Filter
+	filter.Context, _ = cmd.Flags().GetString("context")
+
+	if since, _ := cmd.Flags().GetString("since"); since != "" {
+		t, err := audit.ParseSince(since, time.Now())
+		if err != nil {
+			return err
+		}
+		filter.Since = t
+	}
+
+	if decision, _ := cmd.Flags().GetString("decision"); decision != "" {
+		d, err := audit.ParseDecision(decision)
+		if err != nil {
+			return err
+		}
+		filter.Decision = d
+	}
+
+	log, err := audit.NewLog()
+	if err != nil {
+		return err
+	}
+
+	entries, err := log.Read(filter)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if output == "json" {
+		enc := json.NewEncoder(out)
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(entries) == 0 {
+		fmt.Fprintln(out, "No audit log entries found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tUSER\tCONTEXT\tNAMESPACE\tDECISION\tRULE\tLIFTED\tCOMMAND")
+	for _, e := range entries {
+		lifted := ""
+		if e.LiftedUntil != nil {
+			lifted = "until " + e.LiftedUntil.Local().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s %s\n",
+			e.Time.Local().Format(time.RFC3339), e.User, e.Context, e.Namespace, e.Decision,
+			e.Rule, lifted, e.Command, strings.Join(e.Args, " "))
+	}
+	return w.Flush()
+}
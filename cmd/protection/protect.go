@@ -1,26 +1,58 @@
 package protection
 
 import (
-	"github.com/spf13/cobra"
+	"fmt"
 
-	"github.com/idebeijer/kubert/internal/kubert"
+	"github.com/spf13/cobra"
 )
 
 func NewProtectCommand() *cobra.Command {
+	var (
+		profile    string
+		namespaces []string
+		target     contextTarget
+	)
+
 	cmd := &cobra.Command{
 		Use:   "protect",
 		Short: "Explicitly protect current context",
 		Long: `Explicitly protect the current context.
 
 This sets an explicit protection override for the current context.
+Use --profile to protect the context with one of the protection profiles from the config.
+Use --context to protect other contexts, this does not need an active kubert shell.
+Use --namespace to protect namespaces of the context instead of the whole context.
 To revert to the default regex-based protection, use "kubert protection remove".`,
-		PreRunE: func(cmd *cobra.Command, args []string) error {
-			return kubert.ShellPreFlightCheck()
-		},
+		Example: `  # Protect the current context
+  kubert protection protect
+
+  # Protect the current context with the "strict" profile
+  kubert protection protect --profile strict
+
+  # Protect all production contexts
+  kubert protection protect --context "prod-*"
+
+  # Protect the payments namespace of the shared cluster
+  kubert protection protect --context shared --namespace payments`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runSetProtection(true)
+			if len(namespaces) > 0 {
+				if profile != "" {
+					return fmt.Errorf("--profile cannot be used with --namespace")
+				}
+				protect := true
+				return runSetNamespaceProtection(&target, namespaces, &protect)
+			}
+			if !cmd.Flags().Changed("profile") {
+				return runSetProtection(&target, true, nil)
+			}
+			return runSetProtection(&target, true, &profile)
 		},
 	}
 
+	addContextFlags(cmd, &target)
+	cmd.Flags().StringSliceVarP(&namespaces, "namespace", "n", nil, "Protect these namespaces of the context instead of the whole context")
+	cmd.Flags().StringVar(&profile, "profile", "", "protection profile to apply to the context, an empty profile removes it")
+	_ = cmd.RegisterFlagCompletionFunc("profile", completeProfiles)
+
 	return cmd
 }
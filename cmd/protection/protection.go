@@ -2,11 +2,13 @@ package protection
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/spf13/cobra"
 
+	"github.com/idebeijer/kubert/internal/config"
+	"github.com/idebeijer/kubert/internal/protection"
 	"github.com/idebeijer/kubert/internal/state"
-	"github.com/idebeijer/kubert/internal/util"
 )
 
 func NewCommand() *cobra.Command {
@@ -25,32 +27,114 @@ This only works when using kubectl through "kubert kubectl" (consider aliasing k
 	cmd.AddCommand(NewLiftCommand())
 	cmd.AddCommand(NewRemoveCommand())
 	cmd.AddCommand(NewInfoCommand())
+	cmd.AddCommand(NewListCommand())
+	cmd.AddCommand(NewLogCommand())
+	cmd.AddCommand(NewExportCommand())
+	cmd.AddCommand(NewImportCommand())
 
 	return cmd
 }
 
-func runSetProtection(protect bool) error {
-	sm, err := state.NewManager()
+// runSetProtection sets an explicit protection override for the target contexts. A nil profile
+// keeps the profile of every context.
+func runSetProtection(target *contextTarget, protect bool, profile *string) error {
+	contexts, err := target.resolve()
 	if err != nil {
 		return err
 	}
 
-	clientConfig, err := util.KubeClientConfig()
+	sm, err := state.NewManager()
 	if err != nil {
 		return err
 	}
 
-	if err := sm.SetContextProtection(clientConfig.CurrentContext, protect); err != nil {
-		return err
+	if profile != nil && *profile != "" {
+		engine, err := protection.NewEngine(config.Cfg, sm)
+		if err != nil {
+			return err
+		}
+		if !engine.HasProfile(*profile) {
+			return fmt.Errorf("protection profile %q is not defined in the config", *profile)
+		}
 	}
 
-	// Clear any active lift (best effort, ignore errors since main operation succeeded)
-	_ = sm.ClearProtectedUntil(clientConfig.CurrentContext)
-
 	status := "unprotected"
 	if protect {
 		status = "protected"
 	}
-	fmt.Printf("Context %q is now %s\n", clientConfig.CurrentContext, status)
+	if profile != nil && *profile != "" {
+		status += fmt.Sprintf(" with profile %q", protection.ProfileName(*profile))
+	}
+
+	for _, ctx := range contexts {
+		if err := sm.EnsureContextExists(ctx.name); err != nil {
+			return err
+		}
+
+		if err := sm.SetContextProtection(ctx.name, protect); err != nil {
+			return err
+		}
+
+		// The profile only changes when one is given, an empty one removes it.
+		if profile != nil {
+			if err := sm.SetContextProfile(ctx.name, protection.ProfileName(*profile)); err != nil {
+				return err
+			}
+		}
+
+		// Clear any active lift (best effort, ignore errors since main operation succeeded)
+		_ = sm.ClearProtectedUntil(ctx.name)
+
+		fmt.Printf("Context %q is now %s\n", ctx.name, status)
+	}
 	return nil
 }
+
+// runSetNamespaceProtection sets explicit protection overrides for namespaces of the target
+// contexts. A nil protect removes the overrides.
+func runSetNamespaceProtection(target *contextTarget, namespaces []string, protect *bool) error {
+	contexts, err := target.resolve()
+	if err != nil {
+		return err
+	}
+
+	sm, err := state.NewManager()
+	if err != nil {
+		return err
+	}
+
+	for _, ctx := range contexts {
+		if err := sm.EnsureContextExists(ctx.name); err != nil {
+			return err
+		}
+
+		for _, namespace := range namespaces {
+			if protect == nil {
+				if err := sm.DeleteNamespaceProtection(ctx.name, namespace); err != nil {
+					return err
+				}
+				fmt.Printf("Removed protection override for namespace %q of context %q\n", namespace, ctx.name)
+				continue
+			}
+
+			if err := sm.SetNamespaceProtection(ctx.name, namespace, *protect); err != nil {
+				return err
+			}
+			status := "unprotected"
+			if *protect {
+				status = "protected"
+			}
+			fmt.Printf("Namespace %q of context %q is now %s\n", namespace, ctx.name, status)
+		}
+	}
+	return nil
+}
+
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	profiles := make([]string, 0, len(config.Cfg.Protection.Profiles))
+	for name := range config.Cfg.Protection.Profiles {
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles)
+	return profiles, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,259 @@
+package protection
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/adrg/xdg"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/idebeijer/kubert/internal/config"
+	"github.com/idebeijer/kubert/internal/kubeconfig"
+	"github.com/idebeijer/kubert/internal/protection"
+	"github.com/idebeijer/kubert/internal/state"
+)
+
+// setupTestConfig points the state, the cache and the config to temporary directories, with a
+// kubeconfig holding the given contexts.
+func setupTestConfig(t *testing.T, contexts ...string) {
+	t.Helper()
+	originalData, originalCache, originalCfg := xdg.DataHome, xdg.CacheHome, config.Cfg
+	xdg.DataHome, xdg.CacheHome = t.TempDir(), t.TempDir()
+	t.Cleanup(func() { xdg.DataHome, xdg.CacheHome, config.Cfg = originalData, originalCache, originalCfg })
+
+	path := writeKubeconfig(t, "config.yaml", contexts...)
+	config.Cfg = config.Config{
+		KubeconfigPaths: config.KubeconfigPaths{Include: []string{path}, Duplicates: "error"},
+		Protection: config.Protection{
+			Profiles: map[string]config.ProtectionProfile{"strict": {}, "readonly": {}},
+		},
+	}
+}
+
+// writeKubeconfig writes a kubeconfig holding the given contexts to a temporary file.
+func writeKubeconfig(t *testing.T, name string, contexts ...string) string {
+	t.Helper()
+	kubeconfig := api.NewConfig()
+	for _, name := range contexts {
+		kubeconfig.Clusters[name] = &api.Cluster{Server: "https://" + name + ".example.com"}
+		kubeconfig.AuthInfos[name] = &api.AuthInfo{}
+		kubeconfig.Contexts[name] = &api.Context{Cluster: name, AuthInfo: name}
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := clientcmd.WriteToFile(*kubeconfig, path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func contextProfile(t *testing.T, context string) string {
+	t.Helper()
+	sm, err := state.NewManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _ := sm.ContextInfo(context)
+	return info.Profile
+}
+
+func TestRunSetProtection_Profile(t *testing.T) {
+	setupTestConfig(t, "prod")
+	target := &contextTarget{patterns: []string{"prod"}}
+	profile := func(name string) *string { return &name }
+
+	steps := []struct {
+		name    string
+		protect bool
+		profile *string
+		want    string
+	}{
+		{name: "protect with profile", protect: true, profile: profile("Strict"), want: "strict"},
+		{name: "protect without profile keeps it", protect: true, want: "strict"},
+		{name: "unprotect keeps it", protect: false, want: "strict"},
+		{name: "other profile", protect: true, profile: profile("readonly"), want: "readonly"},
+		{name: "empty profile removes it", protect: true, profile: profile(""), want: ""},
+	}
+
+	for _, step := range steps {
+		if err := runSetProtection(target, step.protect, step.profile); err != nil {
+			t.Fatalf("%s: runSetProtection() error = %v", step.name, err)
+		}
+		if got := contextProfile(t, "prod"); got != step.want {
+			t.Errorf("%s: profile = %q, want %q", step.name, got, step.want)
+		}
+	}
+
+	if err := runSetProtection(target, true, profile("missing")); err == nil {
+		t.Error("runSetProtection() with an unknown profile succeeded")
+	}
+}
+
+func TestListEntries(t *testing.T) {
+	setupTestConfig(t)
+	sm, err := state.NewManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = sm.EnsureContextExists("prod")
+	_ = sm.SetContextProtection("prod", true)
+	_ = sm.SetContextProfile("prod", "strict")
+	_ = sm.EnsureContextExists("broken")
+	_ = sm.SetContextProtection("broken", true)
+	_ = sm.SetContextProfile("broken", "removed")
+
+	engine, err := protection.NewEngine(config.Cfg, sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contexts := []kubeconfig.Context{{Name: "prod"}, {Name: "dev"}, {Name: "broken"}, {Name: "prod"}}
+
+	entries := listEntries(engine, contexts)
+
+	want := []listEntry{
+		{Context: "broken", Status: "error", Error: `context "broken" uses unknown protection profile "removed"`},
+		{Context: "dev", Status: "unprotected", Source: string(protection.SourceNone)},
+		{Context: "prod", Status: "protected", Source: string(protection.SourceExplicit), Profile: "strict"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("listEntries() = %+v, want %+v", entries, want)
+	}
+}
+
+func TestReadOverrides(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "protection.yaml")
+	if err := os.WriteFile(file, []byte("contexts:\n  prod:\n    protected: true\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		stdin        string
+		wantContexts []string
+		wantErr      string
+	}{
+		{name: "file", path: file, wantContexts: []string{"prod"}},
+		{name: "stdin", path: "-", stdin: "contexts:\n  dev:\n    protected: false\n  prod:\n    profile: strict\n", wantContexts: []string{"dev", "prod"}},
+		{name: "json", path: "-", stdin: `{"contexts": {"prod": {"namespaces": {"payments": true}}}}`, wantContexts: []string{"prod"}},
+		{name: "empty input", path: "-", stdin: ""},
+		{name: "unknown field", path: "-", stdin: "contexts:\n  prod:\n    protect: true\n", wantErr: "field protect not found"},
+		{name: "missing file", path: filepath.Join(t.TempDir(), "missing.yaml"), wantErr: "failed to open overrides"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			overrides, err := readOverrides(strings.NewReader(tt.stdin), tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("readOverrides() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readOverrides() error = %v", err)
+			}
+			var contexts []string
+			for context := range overrides.Contexts {
+				contexts = append(contexts, context)
+			}
+			slices.Sort(contexts)
+			if !slices.Equal(contexts, tt.wantContexts) {
+				t.Errorf("readOverrides() contexts = %v, want %v", contexts, tt.wantContexts)
+			}
+		})
+	}
+}
+
+func TestRunImport_EmptyProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		replace bool
+		want    string
+	}{
+		{name: "merge keeps the profile", replace: false, want: "strict"},
+		{name: "replace removes the profile", replace: true, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestConfig(t, "prod")
+			profile := "strict"
+			if err := runSetProtection(&contextTarget{patterns: []string{"prod"}}, true, &profile); err != nil {
+				t.Fatal(err)
+			}
+
+			cmd := &cobra.Command{}
+			cmd.SetIn(strings.NewReader("contexts:\n  prod:\n    protected: true\n    profile: \"\"\n"))
+			cmd.SetOut(io.Discard)
+			if err := runImport(cmd, "-", tt.replace); err != nil {
+				t.Fatalf("runImport() error = %v", err)
+			}
+			if got := contextProfile(t, "prod"); got != tt.want {
+				t.Errorf("profile = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadContexts_RenamedKeepsProtection(t *testing.T) {
+	setupTestConfig(t, "prod")
+	profile := "strict"
+	if err := runSetProtection(&contextTarget{patterns: []string{"prod"}}, true, &profile); err != nil {
+		t.Fatal(err)
+	}
+
+	// A second kubeconfig with the same context renames both to <file name>/prod.
+	config.Cfg.KubeconfigPaths.Include = append(config.Cfg.KubeconfigPaths.Include, writeKubeconfig(t, "other.yaml", "prod"))
+	config.Cfg.KubeconfigPaths.Duplicates = string(kubeconfig.DuplicatePrefixFilename)
+
+	contexts, err := loadContexts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm, err := state.NewManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine, err := protection.NewEngine(config.Cfg, sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, 0, len(contexts))
+	for _, ctx := range contexts {
+		names = append(names, ctx.Name)
+		status, err := engine.Status(ctx.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !status.Protected || status.Profile != "strict" {
+			t.Errorf("Status(%q) = %+v, want it protected with profile strict", ctx.Name, status)
+		}
+	}
+	slices.Sort(names)
+	if want := []string{"config.yaml/prod", "other.yaml/prod"}; !slices.Equal(names, want) {
+		t.Errorf("loadContexts() = %v, want %v", names, want)
+	}
+
+	// Settings made under the new name are not overwritten by the old ones.
+	if err := runSetProtection(&contextTarget{patterns: []string{"other.yaml/prod"}}, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadContexts(); err != nil {
+		t.Fatal(err)
+	}
+	sm, err = state.NewManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if protected, _ := sm.IsContextProtected("other.yaml/prod"); protected {
+		t.Error("other.yaml/prod is protected again after loading the contexts")
+	}
+}
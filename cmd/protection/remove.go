@@ -5,44 +5,58 @@ import (
 
 	"github.com/spf13/cobra"
 
-	"github.com/idebeijer/kubert/internal/kubert"
 	"github.com/idebeijer/kubert/internal/state"
-	"github.com/idebeijer/kubert/internal/util"
 )
 
 func NewRemoveCommand() *cobra.Command {
+	var (
+		namespaces []string
+		target     contextTarget
+	)
+
 	cmd := &cobra.Command{
 		Use:   "remove",
 		Short: "Remove explicit protection override",
 		Long: `Remove any explicit protection override for the current context.
 
 This clears both the explicit protected/unprotected setting and any active lift,
-reverting the context to use the default regex-based protection from config.`,
-		PreRunE: func(cmd *cobra.Command, args []string) error {
-			return kubert.ShellPreFlightCheck()
-		},
+reverting the context to use the default regex-based protection from config.
+Use --context to remove overrides of other contexts, this does not need an active kubert shell.
+Use --namespace to remove the overrides of namespaces instead, leaving the context unchanged.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			sm, err := state.NewManager()
-			if err != nil {
-				return err
+			if len(namespaces) > 0 {
+				return runSetNamespaceProtection(&target, namespaces, nil)
 			}
 
-			clientConfig, err := util.KubeClientConfig()
+			contexts, err := target.resolve()
 			if err != nil {
 				return err
 			}
 
-			if err := sm.DeleteContextProtection(clientConfig.CurrentContext); err != nil {
+			sm, err := state.NewManager()
+			if err != nil {
 				return err
 			}
 
-			// Also clear any active lift (best effort, ignore errors since main operation succeeded)
-			_ = sm.ClearProtectedUntil(clientConfig.CurrentContext)
+			for _, ctx := range contexts {
+				if err := sm.EnsureContextExists(ctx.name); err != nil {
+					return err
+				}
+
+				if err := sm.DeleteContextProtection(ctx.name); err != nil {
+					return err
+				}
 
-			fmt.Printf("Removed protection override for context %q (now using default regex)\n", clientConfig.CurrentContext)
+				// Also clear any active lift (best effort, ignore errors since main operation succeeded)
+				_ = sm.ClearProtectedUntil(ctx.name)
+
+				fmt.Printf("Removed protection override for context %q (now using default regex)\n", ctx.name)
+			}
 			return nil
 		},
 	}
 
+	addContextFlags(cmd, &target)
+	cmd.Flags().StringSliceVarP(&namespaces, "namespace", "n", nil, "Remove the overrides of these namespaces instead of the context override")
 	return cmd
 }
@@ -0,0 +1,162 @@
+package protection
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/idebeijer/kubert/internal/config"
+	"github.com/idebeijer/kubert/internal/kubeconfig"
+	"github.com/idebeijer/kubert/internal/kubert"
+	"github.com/idebeijer/kubert/internal/protection"
+	"github.com/idebeijer/kubert/internal/state"
+	"github.com/idebeijer/kubert/internal/util"
+)
+
+// contextTarget selects the contexts a protection command applies to: the current context of
+// the kubert shell, or the contexts named with --context.
+type contextTarget struct {
+	patterns []string
+	regex    bool
+}
+
+// targetContext is a context selected by a contextTarget, with the namespace configured for it.
+type targetContext struct {
+	name      string
+	namespace string
+}
+
+func addContextFlags(cmd *cobra.Command, t *contextTarget) {
+	cmd.Flags().StringArrayVar(&t.patterns, "context", nil, "Context to use instead of the current context, may be a glob pattern and may be repeated")
+	cmd.Flags().BoolVar(&t.regex, "regex", false, "Treat --context values as regular expressions instead of glob patterns")
+	_ = cmd.RegisterFlagCompletionFunc("context", completeContexts)
+
+	// An active kubert shell is only needed when the current context is used.
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if len(t.patterns) > 0 {
+			return nil
+		}
+		return kubert.ShellPreFlightCheck()
+	}
+}
+
+// resolve returns the selected contexts. Every pattern has to match the name or alias of at
+// least one context in the configured kubeconfig files.
+func (t *contextTarget) resolve() ([]targetContext, error) {
+	if len(t.patterns) == 0 {
+		clientConfig, err := util.KubeClientConfig()
+		if err != nil {
+			return nil, err
+		}
+		current := targetContext{name: clientConfig.CurrentContext}
+		if ctx, ok := clientConfig.Contexts[clientConfig.CurrentContext]; ok {
+			current.namespace = ctx.Namespace
+		}
+		return []targetContext{current}, nil
+	}
+
+	contexts, err := loadContexts()
+	if err != nil {
+		return nil, err
+	}
+
+	aliases, err := kubeconfig.NewAliases(config.Cfg.Aliases)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]targetContext)
+	for _, pattern := range t.patterns {
+		match, err := t.matcher(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		found := false
+		for _, ctx := range contexts {
+			alias := aliases.Alias(ctx.Name)
+			if !match(ctx.Name) && (alias == "" || !match(alias)) {
+				continue
+			}
+			found = true
+			if _, ok := selected[ctx.Name]; ok {
+				continue
+			}
+			selected[ctx.Name] = targetContext{name: ctx.Name, namespace: ctx.Namespace}
+		}
+		if !found {
+			return nil, fmt.Errorf("no context matching %q found in the configured kubeconfig files", pattern)
+		}
+	}
+
+	targets := make([]targetContext, 0, len(selected))
+	for _, target := range selected {
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].name < targets[j].name
+	})
+	return targets, nil
+}
+
+func (t *contextTarget) matcher(pattern string) (func(string) bool, error) {
+	if t.regex {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid context regex %q: %w", pattern, err)
+		}
+		return re.MatchString, nil
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid context pattern %q: %w", pattern, err)
+	}
+	return func(name string) bool {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	}, nil
+}
+
+// loadContexts loads all contexts from the configured kubeconfig files. Contexts renamed for a
+// duplicate name keep the protection settings stored under their old name.
+func loadContexts() ([]kubeconfig.Context, error) {
+	cfg := config.Cfg
+	loader, err := kubeconfig.NewLoaderFromConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error loading contexts: %w", err)
+	}
+	contexts, err := loader.LoadContexts()
+	if err != nil {
+		return nil, fmt.Errorf("error loading contexts: %w", err)
+	}
+	sm, err := state.NewManager()
+	if err != nil {
+		return nil, fmt.Errorf("error creating state manager: %w", err)
+	}
+	if err := protection.InheritRenamed(sm, contexts); err != nil {
+		return nil, fmt.Errorf("error keeping protection of renamed contexts: %w", err)
+	}
+	return contexts, nil
+}
+
+func completeContexts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	contexts, err := loadContexts()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	aliases, _ := kubeconfig.NewAliases(config.Cfg.Aliases)
+	seen := make(map[string]bool, len(contexts))
+	names := make([]string, 0, len(contexts))
+	for _, ctx := range contexts {
+		if !seen[ctx.Name] {
+			seen[ctx.Name] = true
+			names = append(names, aliases.DisplayName(ctx.Name))
+		}
+	}
+	sort.Strings(names)
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
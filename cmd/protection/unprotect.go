@@ -2,25 +2,33 @@ package protection
 
 import (
 	"github.com/spf13/cobra"
-
-	"github.com/idebeijer/kubert/internal/kubert"
 )
 
 func NewUnprotectCommand() *cobra.Command {
+	var (
+		namespaces []string
+		target     contextTarget
+	)
+
 	cmd := &cobra.Command{
 		Use:   "unprotect",
 		Short: "Explicitly unprotect current context",
 		Long: `Explicitly unprotect the current context.
 
 This sets an explicit unprotected override for the current context.
+Use --context to unprotect other contexts, this does not need an active kubert shell.
+Use --namespace to unprotect namespaces that match the namespace regex from the config.
 To revert to the default regex-based protection, use "kubert protection remove".`,
-		PreRunE: func(cmd *cobra.Command, args []string) error {
-			return kubert.ShellPreFlightCheck()
-		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runSetProtection(false)
+			if len(namespaces) > 0 {
+				protect := false
+				return runSetNamespaceProtection(&target, namespaces, &protect)
+			}
+			return runSetProtection(&target, false, nil)
 		},
 	}
 
+	addContextFlags(cmd, &target)
+	cmd.Flags().StringSliceVarP(&namespaces, "namespace", "n", nil, "Unprotect these namespaces of the context instead of the whole context")
 	return cmd
 }
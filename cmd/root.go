@@ -66,6 +66,7 @@ func (c *RootCmd) addCommands() {
 	c.AddCommand(NewContextCommand())
 	c.AddCommand(NewNamespaceCommand())
 	c.AddCommand(NewKubectlCommand())
+	c.AddCommand(NewWrapCommand())
 	c.AddCommand(NewExecCommand())
 	c.AddCommand(which.NewCommand())
 	c.AddCommand(NewVersionCommand())
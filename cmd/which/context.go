@@ -5,15 +5,21 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/idebeijer/kubert/internal/config"
+	"github.com/idebeijer/kubert/internal/kubeconfig"
 	"github.com/idebeijer/kubert/internal/util"
 )
 
 func newContextCommand() *cobra.Command {
-	return &cobra.Command{
+	var alias bool
+
+	cmd := &cobra.Command{
 		Use:     "ctx",
 		Aliases: []string{"context"},
 		Short:   "Display the current Kubernetes context",
-		Long:    `Display the current Kubernetes context.`,
+		Long: `Display the current Kubernetes context.
+
+Use --alias to display the alias of the context from the config instead, or its name if it has no alias.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			clientConfig, err := util.KubeClientConfig()
 			if err != nil {
@@ -24,8 +30,20 @@ func newContextCommand() *cobra.Command {
 				return fmt.Errorf("no current context set")
 			}
 
+			if alias {
+				aliases, err := kubeconfig.NewAliases(config.Cfg.Aliases)
+				if err != nil {
+					return err
+				}
+				fmt.Println(aliases.DisplayName(clientConfig.CurrentContext))
+				return nil
+			}
+
 			fmt.Println(clientConfig.CurrentContext)
 			return nil
 		},
 	}
+
+	cmd.Flags().BoolVar(&alias, "alias", false, "display the alias of the context")
+	return cmd
 }
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/idebeijer/kubert/internal/audit"
+	"github.com/idebeijer/kubert/internal/config"
+	"github.com/idebeijer/kubert/internal/kubert"
+	"github.com/idebeijer/kubert/internal/prompt"
+	"github.com/idebeijer/kubert/internal/protection"
+	"github.com/idebeijer/kubert/internal/state"
+	"github.com/idebeijer/kubert/internal/tool"
+	"github.com/idebeijer/kubert/internal/util"
+)
+
+type WrapOptions struct {
+	Out    io.Writer
+	ErrOut io.Writer
+
+	Tool tool.Tool
+	Args []string
+
+	Config               config.Config
+	StateManager         func() (*state.Manager, error)
+	ClientConfigLoader   func() (*api.Config, error)
+	KubeconfigFileLoader func(path string) (*api.Config, error)
+	CommandRunner        func(binary string, args []string) error
+	Prompter             func(mode prompt.Mode, context string) bool
+	AuditRecorder        func(audit.Entry) error
+}
+
+func NewWrapOptions() *WrapOptions {
+	return &WrapOptions{
+		Out:    os.Stdout,
+		ErrOut: os.Stderr,
+
+		StateManager: state.NewManager,
+		ClientConfigLoader: func() (*api.Config, error) {
+			return util.LoadKubeClientConfig("")
+		},
+		KubeconfigFileLoader: util.LoadKubeClientConfig,
+		CommandRunner: func(binary string, args []string) error {
+			binaryPath, err := kubert.LookPath(binary)
+			if err != nil {
+				return fmt.Errorf("%s not found in PATH", binary)
+			}
+			toolCmd := exec.Command(binaryPath, args...)
+			toolCmd.Stdin = os.Stdin
+			toolCmd.Stdout = os.Stdout
+			toolCmd.Stderr = os.Stderr
+			if err := toolCmd.Run(); err != nil {
+				if _, ok := err.(*exec.ExitError); ok {
+					// Return nil to avoid duplicating the error message given by the tool
+					return nil
+				}
+				return fmt.Errorf("%s error: %w", binary, err)
+			}
+			return nil
+		},
+		Prompter:      prompt.Confirm,
+		AuditRecorder: audit.Record,
+	}
+}
+
+func NewWrapCommand() *cobra.Command {
+	o := NewWrapOptions()
+
+	cmd := &cobra.Command{
+		Use:   "wrap <tool> [args...]",
+		Short: "Wrapper for helm, flux, argocd and other tools",
+		Long: `Wrapper for command line tools other than kubectl, to apply context protection to them.
+
+Built-in tools are helm, flux and argocd. Other tools, or different settings for the built-in
+ones, are declared under protection.tools in the config: which subcommands change the cluster
+and which flags select the context and namespace. Protected subcommands are evaluated like
+kubectl commands that cannot be inspected, using the same protection settings, prompt and
+audit log as "kubert kubectl".`,
+		Example: `  # Upgrade a release, prompting first if the context is protected
+  kubert wrap helm upgrade web ./chart -n payments
+
+  # Use it through an alias
+  alias helm="kubert wrap helm"`,
+		DisableFlagParsing: true,
+		SilenceUsage:       true,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return kubert.ShellPreFlightCheck()
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) == 0 {
+				return tool.Names(config.Cfg), cobra.ShellCompDirectiveNoFileComp
+			}
+			return nil, cobra.ShellCompDirectiveDefault
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+				return cmd.Help()
+			}
+			if err := o.Complete(cmd, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			return o.Run()
+		},
+	}
+
+	return cmd
+}
+
+func (o *WrapOptions) Complete(cmd *cobra.Command, args []string) error {
+	o.Out = cmd.OutOrStdout()
+	o.ErrOut = cmd.ErrOrStderr()
+	o.Config = config.Cfg
+
+	t, err := tool.Lookup(o.Config, args[0])
+	if err != nil {
+		return err
+	}
+	o.Tool = t
+	o.Args = args[1:]
+	return nil
+}
+
+func (o *WrapOptions) Validate() error {
+	if _, err := kubert.LookPath(o.Tool.Binary); err != nil {
+		return fmt.Errorf("%s not found in PATH", o.Tool.Binary)
+	}
+	return nil
+}
+
+func (o *WrapOptions) Run() error {
+	sm, err := o.StateManager()
+	if err != nil {
+		return err
+	}
+
+	inv := o.Tool.Parse(o.Args)
+	target, err := resolveTarget(o.ClientConfigLoader, o.KubeconfigFileLoader, inv.Kubeconfig, inv.Context, inv.Namespace, inv.AllNamespaces)
+	if err != nil {
+		return err
+	}
+
+	engine, err := protection.NewEngine(o.Config, sm)
+	if err != nil {
+		return err
+	}
+
+	req := protection.Request{
+		Context:       target.Context,
+		Namespace:     target.Namespace,
+		AllNamespaces: target.AllNamespaces,
+	}
+	if inv.Command != "" {
+		req.Opaque = true
+		req.ToolCommand = o.Tool.Name + " " + inv.Command
+	}
+	decision, err := engine.Evaluate(req)
+	if err != nil {
+		return err
+	}
+
+	verb := inv.Command
+	if verb == "" && len(o.Args) > 0 {
+		verb = o.Args[0]
+	}
+	guard := guardedCommand{
+		tool:     o.Tool.Name,
+		verb:     verb,
+		args:     o.Args,
+		target:   target,
+		out:      o.Out,
+		errOut:   o.ErrOut,
+		prompter: o.Prompter,
+		recorder: o.AuditRecorder,
+	}
+	if !guard.confirm(decision) {
+		return nil
+	}
+
+	return o.CommandRunner(o.Tool.Binary, o.Args)
+}
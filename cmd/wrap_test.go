@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/idebeijer/kubert/internal/audit"
+	"github.com/idebeijer/kubert/internal/config"
+	"github.com/idebeijer/kubert/internal/prompt"
+	"github.com/idebeijer/kubert/internal/state"
+	"github.com/idebeijer/kubert/internal/tool"
+)
+
+func TestWrapOptions_Run(t *testing.T) {
+	prodRegex := "^prod"
+	helm, err := tool.Lookup(config.Config{}, "helm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		prompt   bool
+		answer   bool
+		prompted bool
+		executed bool
+		decision audit.Decision
+		output   string
+	}{
+		{
+			name:     "read-only command in protected context",
+			args:     []string{"list"},
+			executed: true,
+			decision: audit.DecisionAllowed,
+		},
+		{
+			name:     "protected command is denied",
+			args:     []string{"uninstall", "web"},
+			decision: audit.DecisionDenied,
+			output:   `protected helm command "uninstall" in the protected context "prod"`,
+		},
+		{
+			name:     "protected command is confirmed",
+			args:     []string{"upgrade", "web", "./chart"},
+			prompt:   true,
+			answer:   true,
+			prompted: true,
+			executed: true,
+			decision: audit.DecisionPromptedYes,
+		},
+		{
+			name:     "context flag of the tool",
+			args:     []string{"upgrade", "web", "./chart", "--kube-context", "dev"},
+			executed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			var entries []audit.Entry
+			var executed []string
+			prompted := false
+			o := &WrapOptions{
+				Out:    &buf,
+				ErrOut: &buf,
+				Tool:   helm,
+				Args:   tt.args,
+				Config: config.Config{
+					Protection: config.Protection{Regex: &prodRegex, Prompt: tt.prompt},
+				},
+				StateManager: func() (*state.Manager, error) {
+					return &state.Manager{}, nil
+				},
+				ClientConfigLoader: func() (*api.Config, error) {
+					return &api.Config{CurrentContext: "prod"}, nil
+				},
+				CommandRunner: func(binary string, args []string) error {
+					executed = append([]string{binary}, args...)
+					return nil
+				},
+				Prompter: func(prompt.Mode, string) bool {
+					prompted = true
+					return tt.answer
+				},
+				AuditRecorder: func(e audit.Entry) error {
+					entries = append(entries, e)
+					return nil
+				},
+			}
+
+			if err := o.Run(); err != nil {
+				t.Fatalf("Run() returned unexpected error: %v", err)
+			}
+
+			if prompted != tt.prompted {
+				t.Errorf("prompted = %v, want %v", prompted, tt.prompted)
+			}
+			if (executed != nil) != tt.executed {
+				t.Errorf("executed = %v, want %v\n%s", executed, tt.executed, buf.String())
+			}
+			if executed != nil && strings.Join(executed, " ") != "helm "+strings.Join(tt.args, " ") {
+				t.Errorf("CommandRunner called with %v", executed)
+			}
+			if tt.output != "" && !strings.Contains(buf.String(), tt.output) {
+				t.Errorf("Expected output to contain %q, got: %s", tt.output, buf.String())
+			}
+
+			if tt.decision == "" {
+				if len(entries) != 0 {
+					t.Errorf("Expected no audit entries, got %+v", entries)
+				}
+				return
+			}
+			if len(entries) != 1 || entries[0].Decision != tt.decision || entries[0].Command != "helm" {
+				t.Errorf("audit entries = %+v, want one helm entry with decision %s", entries, tt.decision)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/adrg/xdg"
+	"github.com/gofrs/flock"
+
+	"github.com/idebeijer/kubert/internal/protection"
+	"github.com/idebeijer/kubert/internal/util"
+)
+
+const (
+	appName = "kubert"
+	logFile = "audit.jsonl"
+)
+
+// Decision is what happened to a command in a protected context.
+type Decision string
+
+const (
+	DecisionAllowed     Decision = "allowed"
+	DecisionPromptedYes Decision = "prompted-yes"
+	DecisionPromptedNo  Decision = "prompted-no"
+	DecisionDenied      Decision = "denied"
+)
+
+// ParseDecision validates a decision given on the command line.
+func ParseDecision(s string) (Decision, error) {
+	switch d := Decision(s); d {
+	case DecisionAllowed, DecisionPromptedYes, DecisionPromptedNo, DecisionDenied:
+		return d, nil
+	}
+	return "", fmt.Errorf("invalid decision %q, must be one of allowed, prompted-yes, prompted-no or denied", s)
+}
+
+// Entry is a single line in the audit log.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	User      string    `json:"user"`
+	Command   string    `json:"command"`
+	Context   string    `json:"context"`
+	Namespace string    `json:"namespace,omitempty"`
+	Args      []string  `json:"args"`
+	Rule      string    `json:"rule,omitempty"`
+	Profile   string    `json:"profile,omitempty"`
+	Decision  Decision  `json:"decision"`
+	// LiftedUntil and LiftReason are set when protection of the context was lifted at the time.
+	LiftedUntil *time.Time `json:"lifted_until,omitempty"`
+	LiftReason  string     `json:"lift_reason,omitempty"`
+}
+
+// ShouldRecord reports whether a protection decision belongs in the audit log: commands in
+// protected contexts and commands that only ran because protection was lifted.
+func ShouldRecord(d protection.Decision) bool {
+	return d.Status.Protected || d.Status.LiftedUntil != nil
+}
+
+// NewEntry creates an entry for a command run through "kubert <command>".
+func NewEntry(command string, args []string, namespace string, d protection.Decision, outcome Decision) Entry {
+	e := Entry{
+		Time:        time.Now(),
+		User:        util.CurrentUser(),
+		Command:     command,
+		Context:     d.Status.Context,
+		Namespace:   namespace,
+		Args:        args,
+		Rule:        d.Rule,
+		Profile:     d.Status.Profile,
+		Decision:    outcome,
+		LiftedUntil: d.Status.LiftedUntil,
+	}
+	if d.Status.Lift != nil {
+		e.LiftReason = d.Status.Lift.Reason
+	}
+	return e
+}
+
+// Log is the append-only audit log under the XDG data directory.
+type Log struct {
+	filename string
+	fileLock *flock.Flock
+}
+
+func NewLog() (*Log, error) {
+	dataDir := filepath.Join(xdg.DataHome, appName)
+	if err := os.MkdirAll(dataDir, 0o700); err != nil {
+		return nil, err
+	}
+
+	fullPath := filepath.Join(dataDir, logFile)
+	return &Log{
+		filename: fullPath,
+		fileLock: flock.New(fullPath + ".lock"),
+	}, nil
+}
+
+func FilePath() string {
+	return filepath.Join(xdg.DataHome, appName, logFile)
+}
+
+// Record appends an entry to the audit log.
+func Record(e Entry) error {
+	l, err := NewLog()
+	if err != nil {
+		return err
+	}
+	return l.Append(e)
+}
+
+// Append writes an entry as a single JSON line.
+func (l *Log) Append(e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	if err := l.fileLock.Lock(); err != nil {
+		return fmt.Errorf("failed to acquire file lock: %w", err)
+	}
+	defer func() {
+		if unlockErr := l.fileLock.Unlock(); unlockErr != nil {
+			slog.Warn("failed to release file lock", "error", unlockErr)
+		}
+	}()
+
+	f, err := os.OpenFile(l.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return f.Close()
+}
+
+// Filter selects audit log entries. Zero fields match everything.
+type Filter struct {
+	// Context is a glob pattern for the context name.
+	Context  string
+	Since    time.Time
+	Decision Decision
+}
+
+func (f Filter) Matches(e Entry) bool {
+	if f.Context != "" {
+		if matched, _ := path.Match(f.Context, e.Context); !matched {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if f.Decision != "" && e.Decision != f.Decision {
+		return false
+	}
+	return true
+}
+
+// Read returns the entries matching the filter, oldest first. A missing log has no entries.
+func (l *Log) Read(filter Filter) ([]Entry, error) {
+	if filter.Context != "" {
+		if _, err := path.Match(filter.Context, ""); err != nil {
+			return nil, fmt.Errorf("invalid context pattern %q: %w", filter.Context, err)
+		}
+	}
+
+	f, err := os.Open(l.filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("failed to parse audit log line %d: %w", line, err)
+		}
+		if filter.Matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// ParseSince parses a duration ("24h") relative to now, a date ("2006-01-02") or an RFC 3339
+// timestamp.
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, now.Location()); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, use a duration (24h), a date (2006-01-02) or an RFC 3339 timestamp", s)
+}
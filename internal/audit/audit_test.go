@@ -0,0 +1,174 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adrg/xdg"
+
+	"github.com/idebeijer/kubert/internal/protection"
+)
+
+func setupTestLog(t *testing.T) *Log {
+	t.Helper()
+	original := xdg.DataHome
+	xdg.DataHome = t.TempDir()
+	t.Cleanup(func() { xdg.DataHome = original })
+
+	l, err := NewLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestLog_AppendAndRead(t *testing.T) {
+	l := setupTestLog(t)
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	entries := []Entry{
+		{Time: now.Add(-48 * time.Hour), Context: "prod-eu", Args: []string{"delete", "pod", "x"}, Decision: DecisionDenied},
+		{Time: now.Add(-time.Hour), Context: "prod-us", Args: []string{"apply", "-f", "."}, Decision: DecisionPromptedYes},
+		{Time: now, Context: "staging", Args: []string{"delete", "pod", "y"}, Decision: DecisionAllowed},
+	}
+	for _, e := range entries {
+		if err := l.Append(e); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected []string
+	}{
+		{name: "no filter", filter: Filter{}, expected: []string{"prod-eu", "prod-us", "staging"}},
+		{name: "context glob", filter: Filter{Context: "prod-*"}, expected: []string{"prod-eu", "prod-us"}},
+		{name: "since", filter: Filter{Since: now.Add(-24 * time.Hour)}, expected: []string{"prod-us", "staging"}},
+		{name: "decision", filter: Filter{Decision: DecisionDenied}, expected: []string{"prod-eu"}},
+		{name: "combined", filter: Filter{Context: "prod-*", Since: now.Add(-24 * time.Hour), Decision: DecisionDenied}, expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := l.Read(tt.filter)
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if len(got) != len(tt.expected) {
+				t.Fatalf("Read() returned %d entries, want %d", len(got), len(tt.expected))
+			}
+			for i, e := range got {
+				if e.Context != tt.expected[i] {
+					t.Errorf("entry %d context = %q, want %q", i, e.Context, tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLog_ReadMissingFile(t *testing.T) {
+	l := setupTestLog(t)
+	entries, err := l.Read(Filter{})
+	if err != nil || len(entries) != 0 {
+		t.Errorf("Read() = (%v, %v), want no entries and no error", entries, err)
+	}
+}
+
+func TestLog_ReadInvalidLine(t *testing.T) {
+	l := setupTestLog(t)
+	if err := os.WriteFile(filepath.Join(xdg.DataHome, appName, logFile), []byte("not json\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Read(Filter{}); err == nil {
+		t.Error("Expected error for invalid audit log line")
+	}
+}
+
+func TestLog_FilePermissions(t *testing.T) {
+	l := setupTestLog(t)
+	if err := l.Append(Entry{Context: "prod"}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(FilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("audit log permissions = %o, want 600", info.Mode().Perm())
+	}
+}
+
+func TestShouldRecord(t *testing.T) {
+	until := time.Now().Add(time.Hour)
+	tests := []struct {
+		name     string
+		status   protection.Status
+		expected bool
+	}{
+		{name: "protected", status: protection.Status{Protected: true}, expected: true},
+		{name: "lifted", status: protection.Status{LiftedUntil: &until}, expected: true},
+		{name: "unprotected", status: protection.Status{}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ShouldRecord(protection.Decision{Status: tt.status}); got != tt.expected {
+				t.Errorf("ShouldRecord() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewEntry(t *testing.T) {
+	until := time.Now().Add(time.Hour)
+	d := protection.Decision{
+		Status: protection.Status{Context: "prod", Protected: true, Profile: "strict", LiftedUntil: &until},
+		Rule:   "no-bulk-delete",
+	}
+	e := NewEntry("kubectl", []string{"delete", "pods", "--all"}, "web", d, DecisionDenied)
+	if e.Context != "prod" || e.Namespace != "web" || e.Rule != "no-bulk-delete" || e.Profile != "strict" ||
+		e.Decision != DecisionDenied || e.LiftedUntil != &until || e.Command != "kubectl" {
+		t.Errorf("NewEntry() = %+v", e)
+	}
+	if e.Time.IsZero() {
+		t.Error("Expected entry time to be set")
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		input    string
+		expected time.Time
+		wantErr  bool
+	}{
+		{input: "24h", expected: now.Add(-24 * time.Hour)},
+		{input: "2026-03-01", expected: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{input: "2026-03-01T08:00:00Z", expected: time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)},
+		{input: "yesterday", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSince(tt.input, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSince() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(tt.expected) {
+				t.Errorf("ParseSince() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseDecision(t *testing.T) {
+	if _, err := ParseDecision("denied"); err != nil {
+		t.Errorf("ParseDecision(denied) error = %v", err)
+	}
+	if _, err := ParseDecision("blocked"); err == nil {
+		t.Error("ParseDecision(blocked) should fail")
+	}
+}
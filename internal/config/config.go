@@ -19,6 +19,7 @@ type Config struct {
 	InteractiveShellMode bool       `mapstructure:"interactiveShellMode" yaml:"interactiveShellMode,omitempty"`
 	Interactive          bool       `mapstructure:"interactive" yaml:"interactive"`
 	Nested               bool       `mapstructure:"nested" yaml:"nested"`
+	Aliases              Aliases    `mapstructure:"aliases" yaml:"aliases"`
 	Protection           Protection `mapstructure:"protection" yaml:"protection"`
 	Hooks                Hooks      `mapstructure:"hooks" yaml:"hooks"`
 	Fzf                  Fzf        `mapstructure:"fzf" yaml:"fzf"`
@@ -27,26 +28,274 @@ type Config struct {
 type KubeconfigPaths struct {
 	Include []string `mapstructure:"include" yaml:"include"`
 	Exclude []string `mapstructure:"exclude" yaml:"exclude"`
+
+	// MaxDepth is how many directory levels deep included directories and "**" patterns are scanned.
+	MaxDepth int `mapstructure:"maxDepth" yaml:"maxDepth"`
+
+	// Duplicates is what to do when more than one kubeconfig defines a context with the same
+	// name: "error", "first-wins", "prefix-with-filename" or "prefix-with-provider".
+	Duplicates string `mapstructure:"duplicates" yaml:"duplicates"`
+
+	// AgeIdentityFile is the age identity file that decrypts encrypted kubeconfigs. Empty uses
+	// $SOPS_AGE_KEY_FILE, or sops/age/keys.txt in the user config directory like sops does.
+	AgeIdentityFile string `mapstructure:"ageIdentityFile" yaml:"ageIdentityFile"`
+
+	// Env loads the files in the KUBECONFIG environment variable, outside kubert shells.
+	Env EnvKubeconfigs `mapstructure:"env" yaml:"env"`
+
+	// Providers are other sources of kubeconfigs, loaded after the included files.
+	Providers []KubeconfigProvider `mapstructure:"providers" yaml:"providers"`
 }
 
+// EnvKubeconfigs configures loading the files in the KUBECONFIG environment variable.
+type EnvKubeconfigs struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+
+	// Precedence is "include" to load the included files first, or "env" to load the KUBECONFIG
+	// files first. The files loaded first win with the "first-wins" duplicate strategy, and a
+	// file that is both included and in KUBECONFIG is only loaded once, as the first.
+	Precedence string `mapstructure:"precedence" yaml:"precedence"`
+}
+
+// Aliases give contexts with long generated names, like EKS ARNs, short names to select them by.
+type Aliases struct {
+	// Contexts are aliases for single contexts. They take precedence over Rewrites. This is a
+	// list rather than a map because viper lowercases map keys.
+	Contexts []ContextAlias `mapstructure:"contexts" yaml:"contexts"`
+
+	// Rewrites derive aliases from context names. The first rewrite whose regex matches is used.
+	Rewrites []AliasRewrite `mapstructure:"rewrites" yaml:"rewrites"`
+}
+
+// ContextAlias is the alias of the context with the given name.
+type ContextAlias struct {
+	Name  string `mapstructure:"name" yaml:"name"`
+	Alias string `mapstructure:"alias" yaml:"alias"`
+}
+
+// AliasRewrite replaces the matches of Regex in a context name with Replacement, which can
+// refer to capture groups like "$1", to get its alias.
+type AliasRewrite struct {
+	Regex       string `mapstructure:"regex" yaml:"regex"`
+	Replacement string `mapstructure:"replacement" yaml:"replacement"`
+}
+
+// KubeconfigProvider is a source of kubeconfigs besides the included files.
 type KubeconfigProvider struct {
-	Local LocalKubeconfigProvider `mapstructure:"local" yaml:"local"`
+	// Name identifies the provider. Its contexts are tagged with it, and it is the prefix of
+	// the "prefix-with-provider" duplicate strategy.
+	Name string `mapstructure:"name" yaml:"name"`
+
+	// Exactly one of Exec and Secrets is set.
+	Exec    ExecKubeconfigProvider    `mapstructure:"exec" yaml:"exec,omitempty"`
+	Secrets SecretsKubeconfigProvider `mapstructure:"secrets" yaml:"secrets,omitempty"`
 }
 
-type LocalKubeconfigProvider struct {
-	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+// ExecKubeconfigProvider runs a command that prints one or more kubeconfigs, separated by
+// "---", to stdout.
+type ExecKubeconfigProvider struct {
+	// Command is run with "sh -c".
+	Command string `mapstructure:"command" yaml:"command"`
+
+	// TTL is how long the output of the command is cached, e.g. "1h". Empty caches it for an
+	// hour, "0" runs the command every time the contexts are loaded.
+	TTL string `mapstructure:"ttl" yaml:"ttl,omitempty"`
+}
+
+// SecretsKubeconfigProvider loads kubeconfigs from Secrets in a management cluster, like the
+// "<cluster>-kubeconfig" Secrets of Cluster API.
+type SecretsKubeconfigProvider struct {
+	// Context is the context of the management cluster, by the name or alias kubert shows for
+	// it. It is looked up in the included kubeconfigs, KUBECONFIG and the providers configured
+	// before this one.
+	Context string `mapstructure:"context" yaml:"context"`
+
+	// Namespace of the Secrets. Empty lists the Secrets of all namespaces and puts the namespace
+	// in the context names.
+	Namespace string `mapstructure:"namespace" yaml:"namespace,omitempty"`
+
+	// LabelSelector selects the Secrets. Empty selects the Secrets of Cluster API clusters.
+	LabelSelector string `mapstructure:"labelSelector" yaml:"labelSelector,omitempty"`
+
+	// Key is the key of the kubeconfig in the Secrets. Empty uses "value", like Cluster API.
+	Key string `mapstructure:"key" yaml:"key,omitempty"`
+
+	// TTL is how long the kubeconfigs are cached, e.g. "1h". Empty caches them for an hour, "0"
+	// lists the Secrets every time the contexts are loaded.
+	TTL string `mapstructure:"ttl" yaml:"ttl,omitempty"`
 }
 
 type Protection struct {
 	// Regex is a regular expression that matches contexts that should be protected by default.
 	Regex *string `mapstructure:"regex" yaml:"regex"`
 
+	// NamespaceRegex is a regular expression that matches namespaces that should be protected
+	// by default in every context, also in contexts that are not protected themselves.
+	NamespaceRegex *string `mapstructure:"namespaceRegex" yaml:"namespaceRegex"`
+
 	// Commands is a list of kubectl commands that should be blocked when the context is protected.
 	Commands []string `mapstructure:"commands" yaml:"commands"`
 
 	// Prompt enables the confirmation prompt before running protected commands.
 	// If false, kubert will immediately exit when a protected command is run.
 	Prompt bool `mapstructure:"prompt" yaml:"prompt"`
+
+	// Confirm is how the prompt has to be answered: "yes" (y/yes), "context" (type the
+	// context name) or "challenge" (type a random word).
+	Confirm string `mapstructure:"confirm" yaml:"confirm"`
+
+	// Rules refine what happens to commands in protected contexts. They are evaluated in order and
+	// the first matching rule decides; if no rule matches, Commands and Prompt decide.
+	Rules []ProtectionRule `mapstructure:"rules" yaml:"rules"`
+
+	// Profiles are named sets of protection settings (e.g. "strict", "readonly") that replace
+	// Commands, Prompt and Rules for the contexts they are assigned to.
+	Profiles map[string]ProtectionProfile `mapstructure:"profiles" yaml:"profiles"`
+
+	// ContextProfiles protect contexts matching a regex with a profile. The first match wins.
+	// An explicit profile set with "kubert protection protect --profile" takes precedence.
+	ContextProfiles []ContextProfile `mapstructure:"contextProfiles" yaml:"contextProfiles"`
+
+	// Freezes are change-freeze windows during which matching contexts are protected, or
+	// protected commands are denied instead of prompted.
+	Freezes []ProtectionFreeze `mapstructure:"freezes" yaml:"freezes"`
+
+	// Tools declare command line tools other than kubectl, such as helm or flux, that
+	// "kubert wrap" guards with context protection. A tool replaces the built-in tool with
+	// the same name.
+	Tools map[string]ProtectionTool `mapstructure:"tools" yaml:"tools"`
+
+	// Shims put kubectl, and optionally other tools, in front of PATH in kubert shells so they
+	// are protected without using "kubert kubectl" or "kubert wrap".
+	Shims ProtectionShims `mapstructure:"shims" yaml:"shims"`
+
+	// Readonly are the identities "kubert ctx --readonly" impersonates, so that a shell is
+	// read-only at the API server. The first identity matching the context is used.
+	Readonly []ReadonlyIdentity `mapstructure:"readonly" yaml:"readonly"`
+}
+
+// ReadonlyIdentity is a read-only user, and optionally groups, to impersonate in contexts
+// matching Contexts.
+type ReadonlyIdentity struct {
+	// Contexts is a regex for the contexts the identity applies to, empty applies to all contexts.
+	Contexts string `mapstructure:"contexts" yaml:"contexts,omitempty"`
+
+	// User is the user to impersonate ("as" in a kubeconfig).
+	User string `mapstructure:"user" yaml:"user"`
+
+	// Groups are the groups to impersonate ("as-groups" in a kubeconfig).
+	Groups []string `mapstructure:"groups" yaml:"groups,omitempty"`
+}
+
+// ProtectionShims configures the shim directory of kubert shells.
+type ProtectionShims struct {
+	// Enabled adds a kubectl shim that runs "kubert kubectl".
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+
+	// Tools are tools of "kubert wrap" (e.g. "helm") that get a shim as well.
+	Tools []string `mapstructure:"tools" yaml:"tools"`
+}
+
+// ProtectionTool describes how to find the protected commands, context and namespace of an
+// invocation of a tool.
+type ProtectionTool struct {
+	// Binary is the executable to run, the tool name if empty.
+	Binary string `mapstructure:"binary" yaml:"binary,omitempty"`
+
+	// Commands are the subcommands that change the cluster, nested subcommands separated by
+	// a space (e.g. "upgrade" for helm, "app sync" for argocd).
+	Commands []string `mapstructure:"commands" yaml:"commands"`
+
+	// ContextFlag selects the kubeconfig context, "--context" if empty (e.g. "--kube-context" for helm).
+	ContextFlag string `mapstructure:"contextFlag" yaml:"contextFlag,omitempty"`
+
+	// KubeconfigFlag selects the kubeconfig file, "--kubeconfig" if empty.
+	KubeconfigFlag string `mapstructure:"kubeconfigFlag" yaml:"kubeconfigFlag,omitempty"`
+
+	// NamespaceFlags select the namespace, "--namespace" and "-n" if empty.
+	NamespaceFlags []string `mapstructure:"namespaceFlags" yaml:"namespaceFlags,omitempty"`
+
+	// AllNamespacesFlags select all namespaces, "--all-namespaces" and "-A" if empty.
+	AllNamespacesFlags []string `mapstructure:"allNamespacesFlags" yaml:"allNamespacesFlags,omitempty"`
+
+	// ValueFlags are other flags that take a value, so the value is not mistaken for a subcommand.
+	ValueFlags []string `mapstructure:"valueFlags" yaml:"valueFlags,omitempty"`
+}
+
+// ProtectionFreeze is a change freeze. It is active during any of its weekly windows or date
+// ranges.
+type ProtectionFreeze struct {
+	// Name identifies the freeze in "kubert protection info".
+	Name string `mapstructure:"name" yaml:"name"`
+
+	// Contexts is a regex for the contexts the freeze applies to, empty applies to all contexts.
+	Contexts string `mapstructure:"contexts" yaml:"contexts,omitempty"`
+
+	// Timezone is the IANA timezone (e.g. "Europe/Amsterdam") of the windows and dates,
+	// empty uses the local timezone.
+	Timezone string `mapstructure:"timezone" yaml:"timezone,omitempty"`
+
+	Weekly []FreezeWindow `mapstructure:"weekly" yaml:"weekly,omitempty"`
+	Dates  []FreezeDates  `mapstructure:"dates" yaml:"dates,omitempty"`
+
+	// Action is "protect" to protect matching contexts, or "deny" to also deny protected
+	// commands instead of prompting and to ignore lifts.
+	Action string `mapstructure:"action" yaml:"action"`
+}
+
+// FreezeWindow is a weekly time range, e.g. days [fri] from "16:00" to "24:00".
+type FreezeWindow struct {
+	Days  []string `mapstructure:"days" yaml:"days"`
+	Start string   `mapstructure:"start" yaml:"start"`
+	End   string   `mapstructure:"end" yaml:"end"`
+}
+
+// FreezeDates is a date range. Start and End are dates ("2026-12-20"), which include the whole
+// day, or times ("2026-12-20T18:00").
+type FreezeDates struct {
+	Start string `mapstructure:"start" yaml:"start"`
+	End   string `mapstructure:"end" yaml:"end"`
+}
+
+// ProtectionProfile overrides protection settings for the contexts it is assigned to.
+// Unset fields fall back to the top-level protection settings.
+type ProtectionProfile struct {
+	Commands []string         `mapstructure:"commands" yaml:"commands,omitempty"`
+	Prompt   *bool            `mapstructure:"prompt" yaml:"prompt,omitempty"`
+	Confirm  string           `mapstructure:"confirm" yaml:"confirm,omitempty"`
+	Rules    []ProtectionRule `mapstructure:"rules" yaml:"rules,omitempty"`
+}
+
+// ContextProfile assigns a profile to the contexts matching Regex.
+type ContextProfile struct {
+	Regex   string `mapstructure:"regex" yaml:"regex"`
+	Profile string `mapstructure:"profile" yaml:"profile"`
+}
+
+// ProtectionRule matches kubectl commands in protected contexts. Empty fields match anything,
+// all non-empty fields must match for the rule to apply.
+type ProtectionRule struct {
+	// Name identifies the rule in prompts and "kubert protection info".
+	Name string `mapstructure:"name" yaml:"name,omitempty"`
+
+	// Verbs are kubectl commands, optionally with their subcommand (e.g. "delete", "rollout restart").
+	Verbs []string `mapstructure:"verbs" yaml:"verbs,omitempty"`
+
+	// Resources are resource types (e.g. "pod", "namespace"). Plural and short names are accepted.
+	Resources []string `mapstructure:"resources" yaml:"resources,omitempty"`
+
+	// Namespaces are glob patterns matched against the namespace the command targets.
+	Namespaces []string `mapstructure:"namespaces" yaml:"namespaces,omitempty"`
+
+	// Names are glob patterns matched against the resource names in the command.
+	Names []string `mapstructure:"names" yaml:"names,omitempty"`
+
+	// Flags match if any of them is set, e.g. "--all", "--force", "--grace-period=0" or "-A".
+	Flags []string `mapstructure:"flags" yaml:"flags,omitempty"`
+
+	// Action is one of "allow", "prompt", "preview" or "deny". "preview" prompts after showing
+	// what an apply, patch, replace or set would change.
+	Action string `mapstructure:"action" yaml:"action"`
 }
 
 type Hooks struct {
@@ -69,9 +318,18 @@ func setDefaults() {
 		"~/.kube/*.yaml",
 	})
 	viper.SetDefault("kubeconfigs.exclude", []string{})
+	viper.SetDefault("kubeconfigs.maxDepth", 5)
+	viper.SetDefault("kubeconfigs.duplicates", "error")
+	viper.SetDefault("kubeconfigs.ageIdentityFile", "")
+	viper.SetDefault("kubeconfigs.env.enabled", false)
+	viper.SetDefault("kubeconfigs.env.precedence", "include")
+	viper.SetDefault("kubeconfigs.providers", []KubeconfigProvider{})
 	viper.SetDefault("interactive", true)
 	viper.SetDefault("nested", false)
+	viper.SetDefault("aliases.contexts", []ContextAlias{})
+	viper.SetDefault("aliases.rewrites", []AliasRewrite{})
 	viper.SetDefault("protection.regex", nil)
+	viper.SetDefault("protection.namespaceRegex", nil)
 	viper.SetDefault("protection.commands", []string{
 		"delete",
 		"edit",
@@ -85,6 +343,15 @@ func setDefaults() {
 		"set",
 	})
 	viper.SetDefault("protection.prompt", true)
+	viper.SetDefault("protection.confirm", "yes")
+	viper.SetDefault("protection.rules", []ProtectionRule{})
+	viper.SetDefault("protection.profiles", map[string]ProtectionProfile{})
+	viper.SetDefault("protection.contextProfiles", []ContextProfile{})
+	viper.SetDefault("protection.freezes", []ProtectionFreeze{})
+	viper.SetDefault("protection.tools", map[string]ProtectionTool{})
+	viper.SetDefault("protection.shims.enabled", false)
+	viper.SetDefault("protection.shims.tools", []string{})
+	viper.SetDefault("protection.readonly", []ReadonlyIdentity{})
 	viper.SetDefault("hooks.preShell", "")
 	viper.SetDefault("hooks.postShell", "")
 	viper.SetDefault("fzf.opts", "")
@@ -32,6 +32,37 @@ func TestSetDefaults(t *testing.T) {
 		}
 	})
 
+	t.Run("kubeconfig max depth defaults to 5", func(t *testing.T) {
+		if DefaultCfg.KubeconfigPaths.MaxDepth != 5 {
+			t.Errorf("expected max depth 5, got %d", DefaultCfg.KubeconfigPaths.MaxDepth)
+		}
+	})
+
+	t.Run("age identity file defaults to empty", func(t *testing.T) {
+		if DefaultCfg.KubeconfigPaths.AgeIdentityFile != "" {
+			t.Errorf("expected no age identity file, got %q", DefaultCfg.KubeconfigPaths.AgeIdentityFile)
+		}
+	})
+
+	t.Run("kubeconfig duplicates defaults to error", func(t *testing.T) {
+		if DefaultCfg.KubeconfigPaths.Duplicates != "error" {
+			t.Errorf("expected duplicates strategy %q, got %q", "error", DefaultCfg.KubeconfigPaths.Duplicates)
+		}
+	})
+
+	t.Run("kubeconfig env defaults to disabled after include", func(t *testing.T) {
+		env := DefaultCfg.KubeconfigPaths.Env
+		if env.Enabled || env.Precedence != "include" {
+			t.Errorf("expected env disabled with precedence include, got %+v", env)
+		}
+	})
+
+	t.Run("kubeconfig providers defaults empty", func(t *testing.T) {
+		if len(DefaultCfg.KubeconfigPaths.Providers) != 0 {
+			t.Errorf("expected no kubeconfig providers, got %v", DefaultCfg.KubeconfigPaths.Providers)
+		}
+	})
+
 	t.Run("interactive defaults to true", func(t *testing.T) {
 		if !DefaultCfg.Interactive {
 			t.Error("expected Interactive to default to true")
@@ -68,6 +99,54 @@ func TestSetDefaults(t *testing.T) {
 		}
 	})
 
+	t.Run("protection confirm defaults to yes", func(t *testing.T) {
+		if DefaultCfg.Protection.Confirm != "yes" {
+			t.Errorf("expected Protection.Confirm to default to yes, got %q", DefaultCfg.Protection.Confirm)
+		}
+	})
+
+	t.Run("protection rules default to empty", func(t *testing.T) {
+		if len(DefaultCfg.Protection.Rules) != 0 {
+			t.Errorf("expected no default protection rules, got %v", DefaultCfg.Protection.Rules)
+		}
+	})
+
+	t.Run("protection namespace regex defaults to nil", func(t *testing.T) {
+		if DefaultCfg.Protection.NamespaceRegex != nil {
+			t.Errorf("expected Protection.NamespaceRegex to be nil, got %q", *DefaultCfg.Protection.NamespaceRegex)
+		}
+	})
+
+	t.Run("protection freezes default to empty", func(t *testing.T) {
+		if len(DefaultCfg.Protection.Freezes) != 0 {
+			t.Errorf("expected no default protection freezes, got %v", DefaultCfg.Protection.Freezes)
+		}
+	})
+
+	t.Run("protection tools default to empty", func(t *testing.T) {
+		if len(DefaultCfg.Protection.Tools) != 0 {
+			t.Errorf("expected no default protection tools, got %v", DefaultCfg.Protection.Tools)
+		}
+	})
+
+	t.Run("protection shims default to disabled", func(t *testing.T) {
+		if DefaultCfg.Protection.Shims.Enabled || len(DefaultCfg.Protection.Shims.Tools) != 0 {
+			t.Errorf("expected shims to be disabled, got %+v", DefaultCfg.Protection.Shims)
+		}
+	})
+
+	t.Run("protection readonly defaults to empty", func(t *testing.T) {
+		if DefaultCfg.Protection.Readonly == nil || len(DefaultCfg.Protection.Readonly) != 0 {
+			t.Errorf("expected Protection.Readonly to be an empty list, got %v", DefaultCfg.Protection.Readonly)
+		}
+	})
+
+	t.Run("aliases default to empty", func(t *testing.T) {
+		if len(DefaultCfg.Aliases.Contexts) != 0 || len(DefaultCfg.Aliases.Rewrites) != 0 {
+			t.Errorf("expected no aliases, got %+v", DefaultCfg.Aliases)
+		}
+	})
+
 	t.Run("hooks default to empty", func(t *testing.T) {
 		if DefaultCfg.Hooks.PreShell != "" {
 			t.Errorf("expected Hooks.PreShell to be empty, got %q", DefaultCfg.Hooks.PreShell)
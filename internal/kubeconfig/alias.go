@@ -0,0 +1,97 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/idebeijer/kubert/internal/config"
+)
+
+// Aliases maps context names to their aliases. A nil *Aliases has no aliases.
+type Aliases struct {
+	explicit map[string]string
+	rewrites []aliasRewrite
+}
+
+type aliasRewrite struct {
+	regex       *regexp.Regexp
+	replacement string
+}
+
+// NewAliases compiles the aliases from the config.
+func NewAliases(cfg config.Aliases) (*Aliases, error) {
+	a := &Aliases{explicit: make(map[string]string, len(cfg.Contexts))}
+	for _, ca := range cfg.Contexts {
+		if ca.Name == "" || ca.Alias == "" {
+			return nil, fmt.Errorf("context alias needs both a name and an alias, got name %q and alias %q", ca.Name, ca.Alias)
+		}
+		a.explicit[ca.Name] = ca.Alias
+	}
+	for _, rw := range cfg.Rewrites {
+		regex, err := regexp.Compile(rw.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile alias rewrite regex: %w", err)
+		}
+		a.rewrites = append(a.rewrites, aliasRewrite{regex: regex, replacement: rw.Replacement})
+	}
+	return a, nil
+}
+
+// Alias returns the alias of the context, empty if it has none.
+func (a *Aliases) Alias(context string) string {
+	if a == nil {
+		return ""
+	}
+	if alias, ok := a.explicit[context]; ok {
+		return alias
+	}
+	for _, rw := range a.rewrites {
+		if rw.regex.MatchString(context) {
+			if alias := rw.regex.ReplaceAllString(context, rw.replacement); alias != context {
+				return alias
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// DisplayName returns the alias of the context, or its name if it has no alias.
+func (a *Aliases) DisplayName(context string) string {
+	if alias := a.Alias(context); alias != "" {
+		return alias
+	}
+	return context
+}
+
+// Find returns the context with the given name or alias. A context name takes precedence over
+// an alias, so a context can always be selected by its full name.
+func (a *Aliases) Find(contexts []Context, name string) (Context, error) {
+	for _, ctx := range contexts {
+		if ctx.Name == name {
+			return ctx, nil
+		}
+	}
+
+	var matches []Context
+	for _, ctx := range contexts {
+		if a.Alias(ctx.Name) == name {
+			matches = append(matches, ctx)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Context{}, fmt.Errorf("context %s not found", name)
+	case 1:
+		return matches[0], nil
+	}
+
+	names := make([]string, 0, len(matches))
+	for _, ctx := range matches {
+		names = append(names, ctx.Name)
+	}
+	sort.Strings(names)
+	return Context{}, fmt.Errorf("alias %s is used by several contexts: %s", name, strings.Join(names, ", "))
+}
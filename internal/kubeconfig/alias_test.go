@@ -0,0 +1,117 @@
+package kubeconfig
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/idebeijer/kubert/internal/config"
+)
+
+func TestAliases_Alias(t *testing.T) {
+	aliases, err := NewAliases(config.Aliases{
+		Contexts: []config.ContextAlias{{Name: "arn:aws:eks:eu-west-1:1234:cluster/prod-a", Alias: "prod"}},
+		Rewrites: []config.AliasRewrite{
+			{Regex: `^arn:aws:eks:[^:]+:\d+:cluster/(.+)$`, Replacement: "$1"},
+			{Regex: `^gke_[^_]+_[^_]+_(.+)$`, Replacement: "gke-$1"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		context  string
+		expected string
+	}{
+		{context: "arn:aws:eks:eu-west-1:1234:cluster/prod-a", expected: "prod"},
+		{context: "arn:aws:eks:eu-west-1:1234:cluster/staging", expected: "staging"},
+		{context: "gke_project_europe-west4_web", expected: "gke-web"},
+		{context: "kind-dev", expected: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.context, func(t *testing.T) {
+			if got := aliases.Alias(tt.context); got != tt.expected {
+				t.Errorf("Alias(%q) = %q, want %q", tt.context, got, tt.expected)
+			}
+		})
+	}
+
+	var none *Aliases
+	if got := none.DisplayName("kind-dev"); got != "kind-dev" {
+		t.Errorf("DisplayName() without aliases = %q, want the context name", got)
+	}
+}
+
+func TestNewAliases_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.Aliases
+		contains string
+	}{
+		{
+			name:     "missing alias",
+			cfg:      config.Aliases{Contexts: []config.ContextAlias{{Name: "kind-dev"}}},
+			contains: "needs both a name and an alias",
+		},
+		{
+			name:     "invalid rewrite regex",
+			cfg:      config.Aliases{Rewrites: []config.AliasRewrite{{Regex: "["}}},
+			contains: "failed to compile alias rewrite regex",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewAliases(tt.cfg); err == nil || !strings.Contains(err.Error(), tt.contains) {
+				t.Errorf("NewAliases() error = %v, want error containing %q", err, tt.contains)
+			}
+		})
+	}
+}
+
+func TestAliases_Find(t *testing.T) {
+	aliases, err := NewAliases(config.Aliases{Contexts: []config.ContextAlias{
+		{Name: "arn:aws:eks:eu-west-1:1234:cluster/prod-a", Alias: "prod-a"},
+		{Name: "kind-dev", Alias: "staging"},
+		{Name: "kind-test", Alias: "dev"},
+		{Name: "kind-other", Alias: "dev"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contexts := []Context{
+		{Name: "arn:aws:eks:eu-west-1:1234:cluster/prod-a", WithPath: WithPath{FilePath: "/tmp/aws"}},
+		{Name: "kind-dev"},
+		{Name: "kind-test"},
+		{Name: "kind-other"},
+		{Name: "staging"},
+	}
+
+	tests := []struct {
+		name     string
+		expected string
+		errMsg   string
+	}{
+		{name: "prod-a", expected: "arn:aws:eks:eu-west-1:1234:cluster/prod-a"},
+		{name: "arn:aws:eks:eu-west-1:1234:cluster/prod-a", expected: "arn:aws:eks:eu-west-1:1234:cluster/prod-a"},
+		{name: "staging", expected: "staging"},
+		{name: "dev", errMsg: "alias dev is used by several contexts: kind-other, kind-test"},
+		{name: "prod-b", errMsg: "context prod-b not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, err := aliases.Find(contexts, tt.name)
+			if tt.errMsg != "" {
+				if err == nil || err.Error() != tt.errMsg {
+					t.Errorf("Find() error = %v, want %q", err, tt.errMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Find() error = %v", err)
+			}
+			if ctx.Name != tt.expected {
+				t.Errorf("Find() = %q, want %q", ctx.Name, tt.expected)
+			}
+		})
+	}
+}
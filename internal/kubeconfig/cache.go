@@ -0,0 +1,122 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// cacheKeyFile holds the key of the cached kubeconfigs, e.g. the command that printed them, so
+// the cache is refreshed when it is changed in the config.
+const cacheKeyFile = ".key"
+
+// providerCache caches the kubeconfigs of a provider in a directory for ttl, both to keep
+// loading fast and because a kubert shell reads the kubeconfig of its context from its file.
+type providerCache struct {
+	name string
+	dir  string
+	key  string
+	ttl  time.Duration
+}
+
+// load returns the kubeconfigs of the provider from the cache, see files. With a ttl of 0 they
+// are fetched every time and kept in memory instead, without writing them to the cache.
+func (c providerCache) load(fetch func() ([][]byte, error)) ([]WithPath, error) {
+	if c.ttl == 0 {
+		documents, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		var kubeconfigs []WithPath
+		for _, document := range documents {
+			kubeconfig, err := clientcmd.Load(document)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load kubeconfig of provider %q: %w", c.name, err)
+			}
+			kubeconfigs = append(kubeconfigs, WithPath{Config: kubeconfig})
+		}
+		return kubeconfigs, nil
+	}
+
+	files, err := c.files(fetch)
+	if err != nil {
+		return nil, err
+	}
+	var kubeconfigs []WithPath
+	for _, file := range files {
+		kubeconfig, err := LoadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load cached kubeconfig of provider %q: %w", c.name, err)
+		}
+		kubeconfigs = append(kubeconfigs, kubeconfig)
+	}
+	return kubeconfigs, nil
+}
+
+// files returns the cached kubeconfig files, calling fetch to replace them first if the cache
+// has expired. If fetch fails, the expired kubeconfigs are used if there are any.
+func (c providerCache) files(fetch func() ([][]byte, error)) ([]string, error) {
+	if !c.valid() {
+		documents, err := fetch()
+		if err == nil {
+			err = c.replace(documents)
+		}
+		if err != nil {
+			files, globErr := c.cachedFiles()
+			if globErr != nil || len(files) == 0 {
+				return nil, err
+			}
+			slog.Warn("using expired kubeconfigs", "provider", c.name, "error", err)
+			return files, nil
+		}
+	}
+	return c.cachedFiles()
+}
+
+func (c providerCache) valid() bool {
+	info, err := os.Stat(c.dir)
+	if err != nil || time.Since(info.ModTime()) >= c.ttl {
+		return false
+	}
+	key, err := os.ReadFile(filepath.Join(c.dir, cacheKeyFile))
+	return err == nil && string(key) == c.key
+}
+
+// replace replaces the cached kubeconfigs with documents.
+func (c providerCache) replace(documents [][]byte) error {
+	if err := os.MkdirAll(filepath.Dir(c.dir), 0o700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	tmpDir, err := os.MkdirTemp(filepath.Dir(c.dir), c.name+".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	// The kubeconfigs contain credentials, so they are only readable by the user.
+	for i, document := range documents {
+		path := filepath.Join(tmpDir, fmt.Sprintf("%s-%d.yaml", c.name, i+1))
+		if err := os.WriteFile(path, document, 0o600); err != nil {
+			return fmt.Errorf("failed to write kubeconfig to cache: %w", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, cacheKeyFile), []byte(c.key), 0o600); err != nil {
+		return fmt.Errorf("failed to write kubeconfig to cache: %w", err)
+	}
+
+	if err := os.RemoveAll(c.dir); err != nil {
+		return fmt.Errorf("failed to remove expired cache: %w", err)
+	}
+	if err := os.Rename(tmpDir, c.dir); err != nil {
+		return fmt.Errorf("failed to update cache: %w", err)
+	}
+	return nil
+}
+
+func (c providerCache) cachedFiles() ([]string, error) {
+	return filepath.Glob(filepath.Join(c.dir, "*.yaml"))
+}
@@ -0,0 +1,365 @@
+package kubeconfig
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"go.yaml.in/yaml/v4"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/idebeijer/kubert/internal/config"
+	"github.com/idebeijer/kubert/internal/util"
+)
+
+const (
+	ageArmorHeader  = "-----BEGIN AGE ENCRYPTED FILE-----"
+	ageBinaryHeader = "age-encryption.org/v1\n"
+)
+
+// sopsValueRegex matches the values of sops files, which are encrypted one by one.
+var sopsValueRegex = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.*),tag:(.*),type:(.*)\]$`)
+
+// LoadFile loads the kubeconfig at path. Encrypted kubeconfigs, age files or sops files with
+// age recipients, are decrypted in memory with the identities of AgeIdentityFile. If an
+// encrypted kubeconfig cannot be decrypted, the returned WithPath still reports it as encrypted.
+func LoadFile(path string) (WithPath, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return WithPath{}, err
+	}
+	plaintext, encrypted, err := decryptData(data)
+	if err != nil {
+		return WithPath{FilePath: path, Encrypted: encrypted}, err
+	}
+
+	var kubeconfig *api.Config
+	if encrypted {
+		kubeconfig, err = clientcmd.Load(plaintext)
+	} else {
+		// LoadFromFile also records the file every cluster, user and context comes from.
+		kubeconfig, err = clientcmd.LoadFromFile(path)
+	}
+	if err != nil {
+		return WithPath{}, err
+	}
+	return WithPath{Config: kubeconfig, FilePath: path, Encrypted: encrypted}, nil
+}
+
+// decryptData returns the plaintext of data, and whether it was encrypted.
+func decryptData(data []byte) ([]byte, bool, error) {
+	switch {
+	case isAgeEncrypted(data):
+		plaintext, err := decryptAge(data)
+		return plaintext, true, err
+	case isSopsEncrypted(data):
+		plaintext, err := decryptSops(data)
+		return plaintext, true, err
+	default:
+		return data, false, nil
+	}
+}
+
+func isAgeEncrypted(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte(ageArmorHeader)) || bytes.HasPrefix(data, []byte(ageBinaryHeader))
+}
+
+// isSopsEncrypted reports whether data is a YAML or JSON file encrypted by sops, which has its
+// metadata in a top-level "sops" key.
+func isSopsEncrypted(data []byte) bool {
+	var fields struct {
+		Sops map[string]any `yaml:"sops"`
+	}
+	if err := yaml.Unmarshal(data, &fields); err != nil {
+		return false
+	}
+	_, ok := fields.Sops["mac"]
+	return ok
+}
+
+var identities struct {
+	sync.Mutex
+	file string
+	ids  []age.Identity
+}
+
+// ageIdentities returns the identities of the age identity file, reading it only once.
+func ageIdentities() ([]age.Identity, error) {
+	file, err := ageIdentityFile()
+	if err != nil {
+		return nil, err
+	}
+
+	identities.Lock()
+	defer identities.Unlock()
+	if identities.file == file && identities.ids != nil {
+		return identities.ids, nil
+	}
+
+	f, err := os.Open(filepath.Clean(file))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open age identity file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	ids, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read age identity file %s: %w", file, err)
+	}
+	identities.file, identities.ids = file, ids
+	return ids, nil
+}
+
+func ageIdentityFile() (string, error) {
+	if file := config.Cfg.KubeconfigPaths.AgeIdentityFile; file != "" {
+		return util.ExpandPath(file)
+	}
+	if file := os.Getenv("SOPS_AGE_KEY_FILE"); file != "" {
+		return util.ExpandPath(file)
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the age identity file: %w", err)
+	}
+	return filepath.Join(dir, "sops", "age", "keys.txt"), nil
+}
+
+func decryptAge(data []byte) ([]byte, error) {
+	ids, err := ageIdentities()
+	if err != nil {
+		return nil, err
+	}
+
+	var src io.Reader = bytes.NewReader(data)
+	if !bytes.HasPrefix(data, []byte(ageBinaryHeader)) {
+		src = armor.NewReader(bytes.NewReader(bytes.TrimSpace(data)))
+	}
+	r, err := age.Decrypt(src, ids...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt age file: %w", err)
+	}
+	return io.ReadAll(r)
+}
+
+// decryptSops decrypts a sops file with age recipients, and verifies its MAC, which covers all
+// values, so values cannot be removed, reordered or swapped between files. Only YAML and JSON
+// files with a single mapping document are supported, without encrypted comments or key groups.
+func decryptSops(data []byte) ([]byte, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	var doc yaml.Node
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if err := decoder.Decode(&yaml.Node{}); !errors.Is(err, io.EOF) {
+		return nil, errors.New("sops files with more than one YAML document are not supported")
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("sops files that are not a mapping are not supported")
+	}
+	if hasEncryptedComment(&doc) {
+		return nil, errors.New("sops files with encrypted comments are not supported, remove the comments before encrypting the file")
+	}
+	root := doc.Content[0]
+
+	var metadata struct {
+		Mac              string `yaml:"mac"`
+		LastModified     string `yaml:"lastmodified"`
+		MacOnlyEncrypted bool   `yaml:"mac_only_encrypted"`
+		KeyGroups        []any  `yaml:"key_groups"`
+		Age              []struct {
+			Enc string `yaml:"enc"`
+		} `yaml:"age"`
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "sops" {
+			if err := root.Content[i+1].Decode(&metadata); err != nil {
+				return nil, fmt.Errorf("invalid sops metadata: %w", err)
+			}
+			root.Content = append(root.Content[:i], root.Content[i+2:]...)
+			break
+		}
+	}
+	if len(metadata.KeyGroups) > 0 {
+		return nil, errors.New("sops files with key groups are not supported, encrypt for age recipients without key groups")
+	}
+	if len(metadata.Age) == 0 {
+		return nil, errors.New("sops file has no age recipients")
+	}
+
+	key, err := sopsDataKey(metadata.Age)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := sha512.New()
+	if err := decryptSopsNode(root, nil, key, mac, metadata.MacOnlyEncrypted); err != nil {
+		return nil, err
+	}
+	fileMac, _, err := decryptSopsValue(metadata.Mac, key, metadata.LastModified)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt sops MAC: %w", err)
+	}
+	if fileMac != fmt.Sprintf("%X", mac.Sum(nil)) {
+		return nil, errors.New("sops MAC mismatch, the file was modified")
+	}
+
+	return yaml.Marshal(&doc)
+}
+
+// sopsDataKey decrypts the data key of a sops file with the first age recipient that one of
+// the identities can decrypt.
+func sopsDataKey(recipients []struct {
+	Enc string `yaml:"enc"`
+}) ([]byte, error) {
+	ids, err := ageIdentities()
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, recipient := range recipients {
+		r, err := age.Decrypt(armor.NewReader(strings.NewReader(strings.TrimSpace(recipient.Enc))), ids...)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		key, err := io.ReadAll(r)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("failed to decrypt sops data key: %w", errors.Join(errs...))
+}
+
+// hasEncryptedComment reports whether a comment below node was encrypted by sops.
+func hasEncryptedComment(node *yaml.Node) bool {
+	for _, comment := range []string{node.HeadComment, node.LineComment, node.FootComment} {
+		if strings.Contains(comment, "ENC[") {
+			return true
+		}
+	}
+	for _, child := range node.Content {
+		if hasEncryptedComment(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// decryptSopsNode decrypts the values below node in place and adds them to the MAC. Like sops,
+// the values are authenticated with the keys of the mappings they are in, not list indexes.
+func decryptSopsNode(node *yaml.Node, path []string, key []byte, mac io.Writer, macOnlyEncrypted bool) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := decryptSopsNode(node.Content[i+1], append(path, node.Content[i].Value), key, mac, macOnlyEncrypted); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := decryptSopsNode(child, path, key, mac, macOnlyEncrypted); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		value, tag := node.Value, node.ShortTag()
+		encrypted := sopsValueRegex.MatchString(value)
+		if encrypted {
+			var err error
+			if value, tag, err = decryptSopsValue(node.Value, key, strings.Join(path, ":")+":"); err != nil {
+				return fmt.Errorf("failed to decrypt %s: %w", strings.Join(path, "."), err)
+			}
+			node.Value, node.Tag, node.Style = value, tag, 0
+		}
+		if encrypted || !macOnlyEncrypted {
+			_, _ = io.WriteString(mac, sopsMacValue(value, tag))
+		}
+	}
+	return nil
+}
+
+// decryptSopsValue decrypts a sops value, returning it with its YAML tag.
+func decryptSopsValue(value string, key []byte, additionalData string) (string, string, error) {
+	match := sopsValueRegex.FindStringSubmatch(value)
+	if match == nil {
+		return "", "", errors.New("value is not encrypted by sops")
+	}
+	var parts [3][]byte
+	for i, encoded := range match[1:4] {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", "", err
+		}
+		parts[i] = decoded
+	}
+	data, iv, tag := parts[0], parts[1], parts[2]
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", "", err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return "", "", err
+	}
+	plaintext, err := gcm.Open(nil, iv, append(data, tag...), []byte(additionalData))
+	if err != nil {
+		return "", "", err
+	}
+
+	switch match[4] {
+	case "int":
+		return string(plaintext), "!!int", nil
+	case "float":
+		return string(plaintext), "!!float", nil
+	case "bool":
+		b, err := strconv.ParseBool(string(plaintext))
+		if err != nil {
+			return "", "", err
+		}
+		return strconv.FormatBool(b), "!!bool", nil
+	case "str":
+		return string(plaintext), "!!str", nil
+	default:
+		return "", "", fmt.Errorf("sops values of type %q are not supported", match[4])
+	}
+}
+
+// sopsMacValue returns how sops writes a value to the MAC.
+func sopsMacValue(value, tag string) string {
+	switch tag {
+	case "!!bool":
+		if b, err := strconv.ParseBool(value); err == nil {
+			if b {
+				return "True"
+			}
+			return "False"
+		}
+	case "!!int":
+		if i, err := strconv.Atoi(value); err == nil {
+			return strconv.Itoa(i)
+		}
+	case "!!float":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return strconv.FormatFloat(f, 'f', -1, 64)
+		}
+	case "!!null":
+		return ""
+	}
+	return value
+}
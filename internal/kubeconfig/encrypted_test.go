@@ -0,0 +1,418 @@
+package kubeconfig
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"go.yaml.in/yaml/v4"
+
+	"github.com/idebeijer/kubert/internal/config"
+)
+
+// setTestAgeIdentity writes a new age identity to a file and configures it as the identity
+// file for the duration of the test.
+func setTestAgeIdentity(t *testing.T) *age.X25519Identity {
+	t.Helper()
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "keys.txt")
+	if err := os.WriteFile(file, []byte(identity.String()+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	useAgeIdentityFile(t, file)
+	return identity
+}
+
+// useAgeIdentityFile configures file as the age identity file for the duration of the test.
+func useAgeIdentityFile(t *testing.T, file string) {
+	t.Helper()
+	resetIdentities := func() {
+		identities.Lock()
+		identities.file, identities.ids = "", nil
+		identities.Unlock()
+	}
+	old := config.Cfg.KubeconfigPaths.AgeIdentityFile
+	config.Cfg.KubeconfigPaths.AgeIdentityFile = file
+	resetIdentities()
+	t.Cleanup(func() {
+		config.Cfg.KubeconfigPaths.AgeIdentityFile = old
+		resetIdentities()
+	})
+}
+
+func encryptAge(t *testing.T, recipient age.Recipient, plaintext string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	armorWriter := armor.NewWriter(&buf)
+	w, err := age.Encrypt(armorWriter, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(w, plaintext); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := armorWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// encryptSops encrypts plaintext the way sops does with an age recipient. The MAC is computed
+// here rather than with sopsMacValue, so it only supports string values.
+func encryptSops(t *testing.T, recipient age.Recipient, plaintext string) string {
+	t.Helper()
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(plaintext), &doc); err != nil {
+		t.Fatal(err)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+
+	mac := sha512.New()
+	var encrypt func(node *yaml.Node, path []string)
+	encrypt = func(node *yaml.Node, path []string) {
+		switch node.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for _, child := range node.Content {
+				encrypt(child, path)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				encrypt(node.Content[i+1], append(path, node.Content[i].Value))
+			}
+		case yaml.ScalarNode:
+			if node.ShortTag() != "!!str" {
+				t.Fatalf("encryptSops() only supports strings, %s is %s", strings.Join(path, "."), node.ShortTag())
+			}
+			_, _ = io.WriteString(mac, node.Value)
+			node.Value = encryptSopsValue(t, key, node.Value, "str", strings.Join(path, ":")+":")
+			node.Tag, node.Style = "!!str", 0
+		}
+	}
+	encrypt(&doc, nil)
+
+	lastModified := time.Now().UTC().Format(time.RFC3339)
+	metadata := map[string]any{
+		"age":          []map[string]string{{"recipient": fmt.Sprint(recipient), "enc": encryptAge(t, recipient, string(key))}},
+		"lastmodified": lastModified,
+		"mac":          encryptSopsValue(t, key, fmt.Sprintf("%X", mac.Sum(nil)), "str", lastModified),
+		"version":      "3.9.0",
+	}
+	var metadataNode yaml.Node
+	if err := metadataNode.Encode(metadata); err != nil {
+		t.Fatal(err)
+	}
+	root := doc.Content[0]
+	root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "sops"}, &metadataNode)
+
+	data, err := yaml.Marshal(&doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func encryptSopsValue(t *testing.T, key []byte, value, valueType, additionalData string) string {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := make([]byte, 32)
+	if _, err := rand.Read(iv); err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed := gcm.Seal(nil, iv, []byte(value), []byte(additionalData))
+	data, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+	return fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:%s]",
+		base64.StdEncoding.EncodeToString(data), base64.StdEncoding.EncodeToString(iv), base64.StdEncoding.EncodeToString(tag), valueType)
+}
+
+func TestLoadFile_Encrypted(t *testing.T) {
+	identity := setTestAgeIdentity(t)
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sopsFile := encryptSops(t, identity.Recipient(), testKubeconfig("sops"))
+
+	tests := []struct {
+		name          string
+		content       string
+		wantContext   string
+		wantEncrypted bool
+		wantErr       string
+	}{
+		{
+			name:        "plain kubeconfig",
+			content:     testKubeconfig("plain"),
+			wantContext: "plain",
+		},
+		{
+			name:          "age encrypted kubeconfig",
+			content:       encryptAge(t, identity.Recipient(), testKubeconfig("age")),
+			wantContext:   "age",
+			wantEncrypted: true,
+		},
+		{
+			name:          "sops encrypted kubeconfig",
+			content:       sopsFile,
+			wantContext:   "sops",
+			wantEncrypted: true,
+		},
+		{
+			name:          "age file for another identity",
+			content:       encryptAge(t, other.Recipient(), testKubeconfig("other")),
+			wantEncrypted: true,
+			wantErr:       "failed to decrypt age file",
+		},
+		{
+			name:          "sops file with a modified value",
+			content:       strings.Replace(sopsFile, "server: ENC[AES256_GCM,data:", "server: ENC[AES256_GCM,data:AAAA", 1),
+			wantEncrypted: true,
+			wantErr:       "failed to decrypt clusters.cluster.server",
+		},
+		{
+			name:          "sops file with values swapped",
+			content:       swapSopsValues(t, sopsFile, "token: ", "server: "),
+			wantEncrypted: true,
+			wantErr:       "failed to decrypt",
+		},
+		{
+			name:          "sops file with an encrypted comment",
+			content:       "#ENC[AES256_GCM,data:AAAA,iv:AAAA,tag:AAAA,type:comment]\n" + sopsFile,
+			wantEncrypted: true,
+			wantErr:       "sops files with encrypted comments are not supported",
+		},
+		{
+			name:          "sops file with key groups",
+			content:       strings.Replace(sopsFile, "sops:\n", "sops:\n    key_groups:\n        - age: []\n", 1),
+			wantEncrypted: true,
+			wantErr:       "sops files with key groups are not supported",
+		},
+		{
+			name:          "sops file with several documents",
+			content:       sopsFile + "---\nkind: Config\n",
+			wantEncrypted: true,
+			wantErr:       "sops files with more than one YAML document are not supported",
+		},
+		{
+			name:          "sops value of an unsupported type",
+			content:       strings.Replace(sopsFile, ",type:str]", ",type:bytes]", 1),
+			wantEncrypted: true,
+			wantErr:       `sops values of type "bytes" are not supported`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			kubeconfig, err := LoadFile(path)
+			if kubeconfig.Encrypted != tt.wantEncrypted {
+				t.Errorf("LoadFile() encrypted = %v, want %v", kubeconfig.Encrypted, tt.wantEncrypted)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadFile() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadFile() error = %v", err)
+			}
+			if _, ok := kubeconfig.Config.Contexts[tt.wantContext]; !ok {
+				t.Errorf("LoadFile() contexts = %v, want %q", kubeconfig.Config.Contexts, tt.wantContext)
+			}
+			if got := kubeconfig.Config.AuthInfos[tt.wantContext].Token; got != "secret" {
+				t.Errorf("LoadFile() token = %q, want the decrypted token", got)
+			}
+		})
+	}
+}
+
+// TestDecryptSops_SopsCLI decrypts a kubeconfig encrypted by the sops CLI, so the value format,
+// the paths the values are authenticated with and the MAC are checked against sops itself
+// rather than against encryptSops.
+func TestDecryptSops_SopsCLI(t *testing.T) {
+	fixture := filepath.Join("testdata", "sops", "kubeconfig.sops.yaml")
+	encrypted, err := os.ReadFile(fixture)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Skip("the sops fixture has not been generated, run make sops-testdata with the sops CLI installed")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := os.ReadFile(filepath.Join("testdata", "sops", "kubeconfig.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	useAgeIdentityFile(t, filepath.Join("testdata", "sops", "keys.txt"))
+
+	decrypted, err := decryptSops(encrypted)
+	if err != nil {
+		t.Fatalf("decryptSops() error = %v", err)
+	}
+	var got, want map[string]any
+	if err := yaml.Unmarshal(decrypted, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal(plaintext, &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decryptSops() =\n%s\nwant\n%s", decrypted, plaintext)
+	}
+
+	kubeconfig, err := LoadFile(fixture)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	if cluster := kubeconfig.Config.Clusters["sops-fixture"]; cluster == nil || !cluster.InsecureSkipTLSVerify {
+		t.Errorf("LoadFile() clusters = %v, want sops-fixture with insecure-skip-tls-verify", kubeconfig.Config.Clusters)
+	}
+
+	// The MAC is encrypted with lastmodified and covers the order of list entries.
+	tampered := []struct {
+		name    string
+		content string
+	}{
+		{name: "lastmodified changed", content: regexp.MustCompile(`lastmodified: "?[^"\n]+"?`).ReplaceAllString(string(encrypted), `lastmodified: "2000-01-01T00:00:00Z"`)},
+		{name: "list entries swapped", content: swapListEntries(t, string(encrypted))},
+	}
+	for _, tt := range tampered {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decryptSops([]byte(tt.content)); err == nil || !strings.Contains(err.Error(), "MAC") {
+				t.Errorf("decryptSops() error = %v, want a MAC error", err)
+			}
+		})
+	}
+}
+
+func TestSopsMacValue(t *testing.T) {
+	// The values sops writes to the MAC for the YAML values it encrypts.
+	tests := []struct {
+		value string
+		tag   string
+		want  string
+	}{
+		{value: "https://prod.example.com", tag: "!!str", want: "https://prod.example.com"},
+		{value: "true", tag: "!!str", want: "true"},
+		{value: "true", tag: "!!bool", want: "True"},
+		{value: "false", tag: "!!bool", want: "False"},
+		{value: "42", tag: "!!int", want: "42"},
+		{value: "-7", tag: "!!int", want: "-7"},
+		{value: "1.5", tag: "!!float", want: "1.5"},
+		{value: "2.0", tag: "!!float", want: "2"},
+		{value: "1e3", tag: "!!float", want: "1000"},
+		{value: "", tag: "!!null", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := sopsMacValue(tt.value, tt.tag); got != tt.want {
+			t.Errorf("sopsMacValue(%q, %s) = %q, want %q", tt.value, tt.tag, got, tt.want)
+		}
+	}
+}
+
+// swapListEntries swaps the first two encrypted list entries, the first two arguments of the
+// exec plugin of the fixture.
+func swapListEntries(t *testing.T, content string) string {
+	t.Helper()
+	lines := strings.Split(content, "\n")
+	var entries []int
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "- ENC[") {
+			entries = append(entries, i)
+		}
+	}
+	if len(entries) < 2 {
+		t.Fatalf("sops file has no encrypted list:\n%s", content)
+	}
+	lines[entries[0]], lines[entries[1]] = lines[entries[1]], lines[entries[0]]
+	return strings.Join(lines, "\n")
+}
+
+// swapSopsValues swaps the values of the first keys a and b.
+func swapSopsValues(t *testing.T, content, a, b string) string {
+	t.Helper()
+	lines := strings.Split(content, "\n")
+	// split returns the line of key, split after the key.
+	split := func(key string) (int, string, string) {
+		for i, line := range lines {
+			if strings.HasPrefix(strings.TrimSpace(line), key) {
+				end := strings.Index(line, key) + len(key)
+				return i, line[:end], line[end:]
+			}
+		}
+		t.Fatalf("sops file has no key %q:\n%s", key, content)
+		return 0, "", ""
+	}
+	i, keyA, valueA := split(a)
+	j, keyB, valueB := split(b)
+	lines[i], lines[j] = keyA+valueB, keyB+valueA
+	return strings.Join(lines, "\n")
+}
+
+func TestLoader_LoadContexts_EncryptedIndex(t *testing.T) {
+	identity := setTestAgeIdentity(t)
+	dir := t.TempDir()
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	writeTestKubeconfigs(t, dir, "dev")
+	encryptedPath := filepath.Join(dir, "prod.age")
+	if err := os.WriteFile(encryptedPath, []byte(encryptAge(t, identity.Recipient(), testKubeconfig("prod"))), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		if got := contextNames(loadIndexedContexts(t, dir, indexPath)); !slices.Equal(got, []string{"dev", "prod"}) {
+			t.Fatalf("LoadContexts() = %v, want [dev prod]", got)
+		}
+	}
+
+	entry, ok := readIndex(indexPath).Files[encryptedPath]
+	if !ok || !entry.Encrypted {
+		t.Fatalf("index entry of encrypted file = %+v, want it marked as encrypted", entry)
+	}
+	if len(entry.Contexts) != 0 {
+		t.Errorf("index has contexts of an encrypted file: %+v", entry.Contexts)
+	}
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte(`"name":"prod"`)) {
+		t.Error("index contains a context of the encrypted file")
+	}
+}
@@ -0,0 +1,73 @@
+package kubeconfig
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/idebeijer/kubert/internal/kubert"
+)
+
+// EnvProviderName is the name of the EnvProvider.
+const EnvProviderName = "env"
+
+// EnvProvider loads the files listed in the KUBECONFIG environment variable, separated by the
+// path list separator of the OS, like kubectl does. Inside kubert shells, where KUBECONFIG is
+// the kubeconfig of the shell, it loads the KUBECONFIG the shell was started with instead.
+type EnvProvider struct {
+	// Getenv returns the value of an environment variable. It is a field so tests can replace it.
+	Getenv func(key string) string
+}
+
+// NewEnvProvider returns a new EnvProvider
+func NewEnvProvider() *EnvProvider {
+	return &EnvProvider{Getenv: os.Getenv}
+}
+
+// Name returns the name of the provider.
+func (e *EnvProvider) Name() string {
+	return EnvProviderName
+}
+
+func (e *EnvProvider) Load() ([]WithPath, error) {
+	files, err := e.Files()
+	if err != nil {
+		return nil, err
+	}
+
+	var kubeconfigs []WithPath
+	for _, file := range files {
+		kubeconfig, err := LoadFile(file)
+		if err != nil {
+			slog.Warn("skipping unreadable kubeconfig", "file", file, "error", err)
+			continue
+		}
+		kubeconfigs = append(kubeconfigs, kubeconfig)
+	}
+	return kubeconfigs, nil
+}
+
+// Files returns the files in KUBECONFIG that exist.
+func (e *EnvProvider) Files() ([]string, error) {
+	kubeconfigEnv := e.Getenv("KUBECONFIG")
+	if e.Getenv(kubert.ShellActiveEnvVar) == "1" {
+		kubeconfigEnv = e.Getenv(kubert.OriginalKubeconfigEnvVar)
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	for _, file := range filepath.SplitList(kubeconfigEnv) {
+		if file == "" || seen[file] {
+			continue
+		}
+		seen[file] = true
+
+		// Like kubectl, files in KUBECONFIG that do not exist are ignored.
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			slog.Debug("skipping missing kubeconfig from KUBECONFIG", "file", file)
+			continue
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
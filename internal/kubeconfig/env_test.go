@@ -0,0 +1,150 @@
+package kubeconfig
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/idebeijer/kubert/internal/config"
+	"github.com/idebeijer/kubert/internal/kubert"
+)
+
+// writeTestKubeconfigs writes a kubeconfig with a context of the same name for each name to dir.
+func writeTestKubeconfigs(t *testing.T, dir string, names ...string) []string {
+	t.Helper()
+	var paths []string
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(testKubeconfig(name)), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestEnvProvider_Load(t *testing.T) {
+	dir := t.TempDir()
+	paths := writeTestKubeconfigs(t, dir, "work", "home")
+	kubeconfigEnv := strings.Join([]string{paths[0], filepath.Join(dir, "missing"), "", paths[1], paths[0]}, string(os.PathListSeparator))
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		want []string
+	}{
+		{
+			name: "files in KUBECONFIG",
+			env:  map[string]string{"KUBECONFIG": kubeconfigEnv},
+			want: paths,
+		},
+		{
+			name: "KUBECONFIG not set",
+			env:  map[string]string{},
+			want: nil,
+		},
+		{
+			name: "inside a kubert shell",
+			env:  map[string]string{"KUBECONFIG": paths[1], kubert.ShellActiveEnvVar: "1", kubert.OriginalKubeconfigEnvVar: paths[0]},
+			want: paths[:1],
+		},
+		{
+			name: "inside a kubert shell without KUBECONFIG",
+			env:  map[string]string{"KUBECONFIG": kubeconfigEnv, kubert.ShellActiveEnvVar: "1"},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &EnvProvider{Getenv: func(key string) string { return tt.env[key] }}
+			kubeconfigs, err := provider.Load()
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			var got []string
+			for _, k := range kubeconfigs {
+				got = append(got, k.FilePath)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Load() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewLoaderFromConfig_Env(t *testing.T) {
+	dir := t.TempDir()
+	paths := writeTestKubeconfigs(t, dir, "work", "home")
+	t.Setenv(kubert.ShellActiveEnvVar, "")
+	t.Setenv("KUBECONFIG", strings.Join(paths, string(os.PathListSeparator)))
+
+	tests := []struct {
+		name       string
+		env        config.EnvKubeconfigs
+		want       map[string]string // context name -> provider
+		wantErrMsg string
+	}{
+		{
+			name: "disabled",
+			env:  config.EnvKubeconfigs{},
+			want: map[string]string{"work": LocalProviderName},
+		},
+		{
+			name: "include first",
+			env:  config.EnvKubeconfigs{Enabled: true, Precedence: "include"},
+			want: map[string]string{"work": LocalProviderName, "home": EnvProviderName},
+		},
+		{
+			name: "env first",
+			env:  config.EnvKubeconfigs{Enabled: true, Precedence: "env"},
+			want: map[string]string{"work": EnvProviderName, "home": EnvProviderName},
+		},
+		{
+			name:       "invalid precedence",
+			env:        config.EnvKubeconfigs{Enabled: true, Precedence: "kubectl"},
+			wantErrMsg: `invalid kubeconfigs.env.precedence "kubectl"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Config{KubeconfigPaths: config.KubeconfigPaths{
+				Include: []string{paths[0]},
+				Env:     tt.env,
+			}}
+			loader, err := NewLoaderFromConfig(cfg)
+			if tt.wantErrMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrMsg) {
+					t.Fatalf("NewLoaderFromConfig() error = %v, want error containing %q", err, tt.wantErrMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewLoaderFromConfig() error = %v", err)
+			}
+			loader.IndexPath = filepath.Join(t.TempDir(), "index.json")
+
+			// The included file is also in KUBECONFIG, it must be loaded once.
+			contexts, err := loader.LoadContexts()
+			if err != nil {
+				t.Fatalf("LoadContexts() error = %v", err)
+			}
+			got := make(map[string]string)
+			for _, ctx := range contexts {
+				got[ctx.Name] = ctx.Provider
+			}
+			if len(got) != len(tt.want) || len(contexts) != len(tt.want) {
+				t.Errorf("LoadContexts() = %v, want %v", got, tt.want)
+			}
+			for name, provider := range tt.want {
+				if got[name] != provider {
+					t.Errorf("context %q has provider %q, want %q", name, got[name], provider)
+				}
+			}
+		})
+	}
+}
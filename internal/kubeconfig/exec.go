@@ -0,0 +1,194 @@
+package kubeconfig
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/adrg/xdg"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/idebeijer/kubert/internal/config"
+)
+
+var providerNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// DefaultProviderTTL is how long the kubeconfigs of a provider are cached when it has no ttl.
+const DefaultProviderTTL = time.Hour
+
+// ExecProvider loads the kubeconfigs a command prints to stdout, e.g. a script that queries an
+// inventory. The kubeconfigs are cached for the TTL.
+type ExecProvider struct {
+	name     string
+	command  string
+	ttl      time.Duration
+	cacheDir string
+
+	// run runs the command and returns its stdout. It is a field so tests can replace it.
+	run func(command string) ([]byte, error)
+}
+
+// NewExecProvider returns an ExecProvider that caches the output of command in cacheDir for ttl.
+func NewExecProvider(name, command string, ttl time.Duration, cacheDir string) *ExecProvider {
+	return &ExecProvider{
+		name:     name,
+		command:  command,
+		ttl:      ttl,
+		cacheDir: cacheDir,
+		run:      runCommand,
+	}
+}
+
+// ProviderCacheDir returns the directory the kubeconfigs of the provider with the given name
+// are cached in.
+func ProviderCacheDir(name string) string {
+	return filepath.Join(xdg.CacheHome, "kubert", "providers", name)
+}
+
+// newProvidersFromConfig returns the providers configured in kubeconfigs.providers. sources
+// are the providers of the included files and KUBECONFIG, and options configure the loader the
+// management contexts of secrets providers are looked up with.
+func newProvidersFromConfig(cfg config.Config, sources []Provider, options []LoaderOption) ([]Provider, error) {
+	providers := cfg.KubeconfigPaths.Providers
+	// Like completion, a broken alias config falls back to the context names.
+	aliases, _ := NewAliases(cfg.Aliases)
+
+	var result []Provider
+	seen := map[string]bool{LocalProviderName: true, EnvProviderName: true}
+	for i, p := range providers {
+		if !providerNameRegex.MatchString(p.Name) {
+			return nil, fmt.Errorf("kubeconfig provider %d has an invalid name %q, use letters, digits, '.', '_' and '-'", i+1, p.Name)
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("kubeconfig provider name %q is already used", p.Name)
+		}
+		seen[p.Name] = true
+
+		exec, secrets := p.Exec.Command != "", p.Secrets.Context != ""
+		if !exec && !secrets {
+			return nil, fmt.Errorf("kubeconfig provider %q has no exec command or secrets context", p.Name)
+		}
+		if exec && secrets {
+			return nil, fmt.Errorf("kubeconfig provider %q has both an exec command and a secrets context", p.Name)
+		}
+		ttl, err := parseProviderTTL(p)
+		if err != nil {
+			return nil, err
+		}
+
+		var provider NamedProvider
+		if exec {
+			provider = NewExecProvider(p.Name, p.Exec.Command, ttl, ProviderCacheDir(p.Name))
+		} else {
+			// The management context can come from the providers configured before this one.
+			sourceOptions := slices.Clone(options)
+			for _, source := range append(slices.Clone(sources), result...) {
+				sourceOptions = append(sourceOptions, WithProvider(source))
+			}
+			provider = NewSecretsProvider(p.Name, p.Secrets.Context, p.Secrets.Namespace,
+				p.Secrets.LabelSelector, p.Secrets.Key, ttl, ProviderCacheDir(p.Name), NewLoader(sourceOptions...), aliases)
+		}
+		if ttl == 0 {
+			provider = uncachedProvider{provider}
+		}
+		result = append(result, provider)
+	}
+	return result, nil
+}
+
+// parseProviderTTL returns the ttl of a provider, DefaultProviderTTL if it has none.
+func parseProviderTTL(p config.KubeconfigProvider) (time.Duration, error) {
+	value := p.Exec.TTL
+	if p.Secrets.Context != "" {
+		value = p.Secrets.TTL
+	}
+	if value == "" {
+		return DefaultProviderTTL, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ttl of kubeconfig provider %q: %w", p.Name, err)
+	}
+	if ttl < 0 {
+		return 0, fmt.Errorf("invalid ttl of kubeconfig provider %q: %s is negative", p.Name, value)
+	}
+	return ttl, nil
+}
+
+// uncachedProvider hides the Files method of a provider with a ttl of 0, so the loader keeps
+// its kubeconfigs in memory instead of reading them from the cache.
+type uncachedProvider struct {
+	NamedProvider
+}
+
+// Name returns the name of the provider.
+func (p *ExecProvider) Name() string {
+	return p.name
+}
+
+func (p *ExecProvider) Load() ([]WithPath, error) {
+	return p.cache().load(p.fetch)
+}
+
+// Files returns the cached kubeconfig files, running the command first if the cache has
+// expired. If the command fails, the expired kubeconfigs are used if there are any.
+func (p *ExecProvider) Files() ([]string, error) {
+	return p.cache().files(p.fetch)
+}
+
+func (p *ExecProvider) cache() providerCache {
+	return providerCache{name: p.name, dir: p.cacheDir, key: p.command, ttl: p.ttl}
+}
+
+// fetch runs the command and returns the kubeconfigs it printed.
+func (p *ExecProvider) fetch() ([][]byte, error) {
+	output, err := p.run(p.command)
+	if err != nil {
+		return nil, fmt.Errorf("command of kubeconfig provider %q failed: %w", p.name, err)
+	}
+	documents, err := splitDocuments(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output of kubeconfig provider %q: %w", p.name, err)
+	}
+	for i, document := range documents {
+		if _, err := clientcmd.Load(document); err != nil {
+			return nil, fmt.Errorf("kubeconfig %d of provider %q is invalid: %w", i+1, p.name, err)
+		}
+	}
+	return documents, nil
+}
+
+// splitDocuments splits YAML or JSON documents separated by "---" lines, skipping empty ones.
+func splitDocuments(data []byte) ([][]byte, error) {
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	var documents [][]byte
+	for {
+		document, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return documents, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(document)) > 0 {
+			documents = append(documents, document)
+		}
+	}
+}
+
+// runCommand runs command with sh. Its stderr is passed through, so the user sees errors, but
+// it gets no stdin: it also runs during shell completion, where it must not wait for input.
+func runCommand(command string) ([]byte, error) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stderr = os.Stderr
+	return cmd.Output()
+}
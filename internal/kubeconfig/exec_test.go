@@ -0,0 +1,281 @@
+package kubeconfig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/idebeijer/kubert/internal/config"
+)
+
+func testKubeconfig(context string) string {
+	return fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: %[1]s
+  cluster:
+    server: https://%[1]s.example.com
+users:
+- name: %[1]s
+  user:
+    token: secret
+contexts:
+- name: %[1]s
+  context:
+    cluster: %[1]s
+    user: %[1]s
+`, context)
+}
+
+// newTestExecProvider returns an ExecProvider whose command prints output, and a pointer to
+// the number of times the command was run.
+func newTestExecProvider(t *testing.T, ttl time.Duration, output string, err error) (*ExecProvider, *int) {
+	t.Helper()
+	runs := 0
+	provider := NewExecProvider("inventory", "inventory kubeconfigs", ttl, filepath.Join(t.TempDir(), "inventory"))
+	provider.run = func(command string) ([]byte, error) {
+		runs++
+		return []byte(output), err
+	}
+	return provider, &runs
+}
+
+func TestExecProvider_Load(t *testing.T) {
+	provider, _ := newTestExecProvider(t, time.Hour, testKubeconfig("acme-dev")+"---\n"+testKubeconfig("acme-prod"), nil)
+
+	loader := NewLoader(WithProvider(provider))
+	contexts, err := loader.LoadContexts()
+	if err != nil {
+		t.Fatalf("LoadContexts() error = %v", err)
+	}
+
+	if len(contexts) != 2 {
+		t.Fatalf("LoadContexts() returned %d contexts, want 2", len(contexts))
+	}
+	for _, ctx := range contexts {
+		if ctx.Provider != "inventory" {
+			t.Errorf("context %q has provider %q, want inventory", ctx.Name, ctx.Provider)
+		}
+		info, err := os.Stat(ctx.FilePath)
+		if err != nil {
+			t.Fatalf("kubeconfig of %q is not cached: %v", ctx.Name, err)
+		}
+		if info.Mode().Perm() != 0o600 {
+			t.Errorf("cached kubeconfig has mode %v, want 0600", info.Mode().Perm())
+		}
+	}
+}
+
+func TestExecProvider_Load_Cache(t *testing.T) {
+	tests := []struct {
+		name     string
+		ttl      time.Duration
+		change   func(p *ExecProvider)
+		wantRuns int
+	}{
+		{
+			name:     "within ttl",
+			ttl:      time.Hour,
+			wantRuns: 1,
+		},
+		{
+			name:     "expired",
+			ttl:      time.Nanosecond,
+			wantRuns: 2,
+		},
+		{
+			name:     "ttl of 0",
+			ttl:      0,
+			wantRuns: 2,
+		},
+		{
+			name:     "command changed",
+			ttl:      time.Hour,
+			change:   func(p *ExecProvider) { p.command = "inventory kubeconfigs --all" },
+			wantRuns: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, runs := newTestExecProvider(t, tt.ttl, testKubeconfig("acme-dev"), nil)
+			if _, err := provider.Load(); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if tt.change != nil {
+				tt.change(provider)
+			}
+			kubeconfigs, err := provider.Load()
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			if len(kubeconfigs) != 1 {
+				t.Errorf("Load() returned %d kubeconfigs, want 1", len(kubeconfigs))
+			}
+			if *runs != tt.wantRuns {
+				t.Errorf("command ran %d times, want %d", *runs, tt.wantRuns)
+			}
+		})
+	}
+}
+
+func TestExecProvider_Load_Uncached(t *testing.T) {
+	provider, _ := newTestExecProvider(t, 0, testKubeconfig("acme-dev"), nil)
+	loader := NewLoader(WithProvider(uncachedProvider{provider}))
+
+	contexts, err := loader.LoadContexts()
+	if err != nil {
+		t.Fatalf("LoadContexts() error = %v", err)
+	}
+	if len(contexts) != 1 || contexts[0].Config == nil || contexts[0].FilePath != "" {
+		t.Fatalf("LoadContexts() = %+v, want one context kept in memory", contexts)
+	}
+	if _, err := os.Stat(provider.cacheDir); !os.IsNotExist(err) {
+		t.Errorf("cache directory exists with a ttl of 0: %v", err)
+	}
+}
+
+func TestExecProvider_Load_Errors(t *testing.T) {
+	t.Run("command fails without cache", func(t *testing.T) {
+		provider, _ := newTestExecProvider(t, time.Hour, "", errors.New("exit status 1"))
+		_, err := provider.Load()
+		if err == nil || !strings.Contains(err.Error(), `command of kubeconfig provider "inventory" failed`) {
+			t.Errorf("Load() error = %v, want the command to fail", err)
+		}
+	})
+
+	t.Run("command fails with expired cache", func(t *testing.T) {
+		provider, _ := newTestExecProvider(t, time.Nanosecond, testKubeconfig("acme-dev"), nil)
+		if _, err := provider.Load(); err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		provider.run = func(string) ([]byte, error) { return nil, errors.New("exit status 1") }
+
+		kubeconfigs, err := provider.Load()
+		if err != nil {
+			t.Fatalf("Load() error = %v, want the expired kubeconfigs", err)
+		}
+		if len(kubeconfigs) != 1 {
+			t.Errorf("Load() returned %d kubeconfigs, want 1", len(kubeconfigs))
+		}
+	})
+
+	t.Run("invalid kubeconfig", func(t *testing.T) {
+		provider, _ := newTestExecProvider(t, time.Hour, testKubeconfig("acme-dev")+"---\nclusters: {}\n", nil)
+		_, err := provider.Load()
+		if err == nil || !strings.Contains(err.Error(), `kubeconfig 2 of provider "inventory" is invalid`) {
+			t.Errorf("Load() error = %v, want an invalid kubeconfig error", err)
+		}
+	})
+}
+
+func TestNewLoaderFromConfig_Providers(t *testing.T) {
+	exec := config.ExecKubeconfigProvider{Command: "inventory kubeconfigs", TTL: "1h"}
+
+	tests := []struct {
+		name      string
+		providers []config.KubeconfigProvider
+		errMsg    string
+	}{
+		{
+			name:      "valid",
+			providers: []config.KubeconfigProvider{{Name: "inventory", Exec: exec}},
+		},
+		{
+			name:      "missing name",
+			providers: []config.KubeconfigProvider{{Exec: exec}},
+			errMsg:    `kubeconfig provider 1 has an invalid name ""`,
+		},
+		{
+			name:      "name with a slash",
+			providers: []config.KubeconfigProvider{{Name: "acme/inventory", Exec: exec}},
+			errMsg:    `invalid name "acme/inventory"`,
+		},
+		{
+			name:      "name of the local provider",
+			providers: []config.KubeconfigProvider{{Name: "local", Exec: exec}},
+			errMsg:    `kubeconfig provider name "local" is already used`,
+		},
+		{
+			name:      "missing command",
+			providers: []config.KubeconfigProvider{{Name: "inventory"}},
+			errMsg:    `kubeconfig provider "inventory" has no exec command`,
+		},
+		{
+			name:      "secrets",
+			providers: []config.KubeconfigProvider{{Name: "capi", Secrets: config.SecretsKubeconfigProvider{Context: "mgmt", TTL: "10m"}}},
+		},
+		{
+			name:      "exec and secrets",
+			providers: []config.KubeconfigProvider{{Name: "inventory", Exec: exec, Secrets: config.SecretsKubeconfigProvider{Context: "mgmt"}}},
+			errMsg:    `kubeconfig provider "inventory" has both an exec command and a secrets context`,
+		},
+		{
+			name:      "invalid ttl",
+			providers: []config.KubeconfigProvider{{Name: "inventory", Exec: config.ExecKubeconfigProvider{Command: "true", TTL: "1 hour"}}},
+			errMsg:    `invalid ttl of kubeconfig provider "inventory"`,
+		},
+		{
+			name:      "negative ttl",
+			providers: []config.KubeconfigProvider{{Name: "inventory", Exec: config.ExecKubeconfigProvider{Command: "true", TTL: "-1h"}}},
+			errMsg:    `invalid ttl of kubeconfig provider "inventory": -1h is negative`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Config{KubeconfigPaths: config.KubeconfigPaths{Providers: tt.providers}}
+			loader, err := NewLoaderFromConfig(cfg)
+			if tt.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Fatalf("NewLoaderFromConfig() error = %v, want error containing %q", err, tt.errMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewLoaderFromConfig() error = %v", err)
+			}
+			if len(loader.Providers) != 1+len(tt.providers) {
+				t.Errorf("loader has %d providers, want %d", len(loader.Providers), 1+len(tt.providers))
+			}
+		})
+	}
+}
+
+func TestParseProviderTTL(t *testing.T) {
+	tests := []struct {
+		ttl  string
+		want time.Duration
+	}{
+		{ttl: "", want: DefaultProviderTTL},
+		{ttl: "10m", want: 10 * time.Minute},
+		{ttl: "0", want: 0},
+	}
+
+	for _, tt := range tests {
+		p := config.KubeconfigProvider{Name: "inventory", Exec: config.ExecKubeconfigProvider{Command: "true", TTL: tt.ttl}}
+		got, err := parseProviderTTL(p)
+		if err != nil || got != tt.want {
+			t.Errorf("parseProviderTTL(%q) = (%v, %v), want %v", tt.ttl, got, err, tt.want)
+		}
+	}
+
+	secrets := config.KubeconfigProvider{Name: "capi", Secrets: config.SecretsKubeconfigProvider{Context: "mgmt"}}
+	if got, err := parseProviderTTL(secrets); err != nil || got != DefaultProviderTTL {
+		t.Errorf("parseProviderTTL() of a secrets provider = (%v, %v), want %v", got, err, DefaultProviderTTL)
+	}
+
+	cfg := config.Config{KubeconfigPaths: config.KubeconfigPaths{Providers: []config.KubeconfigProvider{{Name: "inventory", Exec: config.ExecKubeconfigProvider{Command: "true", TTL: "0"}}}}}
+	providers, err := newProvidersFromConfig(cfg, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := providers[0].(FileProvider); ok {
+		t.Error("provider with a ttl of 0 is read from the cache")
+	}
+}
@@ -0,0 +1,161 @@
+package kubeconfig
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMaxDepth is how many directory levels are scanned when kubeconfigs.maxDepth is not set.
+const DefaultMaxDepth = 5
+
+// Glob returns the paths matching pattern, like filepath.Glob, but a "**" path segment
+// matches any number of directories, including none. Directories are scanned at most
+// maxDepth levels below the part of the pattern that has no wildcards, or DefaultMaxDepth
+// levels if maxDepth is not positive.
+func Glob(pattern string, maxDepth int) ([]string, error) {
+	if !strings.Contains(pattern, "**") {
+		return filepath.Glob(pattern)
+	}
+
+	var matches []string
+	var matchErr error
+	err := walk(globBase(pattern), maxDepth, func(path string, _ fs.DirEntry) bool {
+		ok, err := matchPattern(pattern, path)
+		if err != nil {
+			matchErr = err
+			return false
+		}
+		if ok {
+			matches = append(matches, path)
+		}
+		return true
+	})
+	if matchErr != nil {
+		return nil, matchErr
+	}
+	return matches, err
+}
+
+// globBase returns the leading directories of pattern that contain no wildcards.
+func globBase(pattern string) string {
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+	var base []string
+	for _, segment := range segments {
+		if strings.ContainsAny(segment, `*?[\`) {
+			break
+		}
+		base = append(base, segment)
+	}
+	if len(base) == 0 {
+		return "."
+	}
+	if len(base) == 1 && base[0] == "" {
+		return string(filepath.Separator)
+	}
+	return filepath.FromSlash(strings.Join(base, "/"))
+}
+
+// skippedDirs are directories that hold caches of kubectl and other tools rather than
+// kubeconfigs, e.g. ~/.kube/cache, which can hold thousands of files.
+var skippedDirs = map[string]bool{"cache": true, "http-cache": true}
+
+// walk calls fn for every file and directory below root, up to maxDepth levels deep, until
+// fn returns false. Unreadable directories, hidden directories and cache directories are
+// skipped.
+func walk(root string, maxDepth int, fn func(path string, d fs.DirEntry) bool) error {
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			slog.Debug("skipping unreadable path", "path", path, "error", err)
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if path == root {
+			return nil
+		}
+		if d.IsDir() && (strings.HasPrefix(d.Name(), ".") || skippedDirs[d.Name()]) {
+			slog.Debug("skipping directory", "path", path)
+			return filepath.SkipDir
+		}
+
+		if !fn(path, d) {
+			return filepath.SkipAll
+		}
+		if d.IsDir() && depth(root, path) >= maxDepth {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan directory %s: %w", root, err)
+	}
+	return nil
+}
+
+// depth returns how many levels path is below root.
+func depth(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+// matchPattern reports whether path matches pattern, where a "**" segment matches any number
+// of path segments and other segments are matched with filepath.Match.
+func matchPattern(pattern, path string) (bool, error) {
+	return matchSegments(
+		strings.Split(filepath.ToSlash(filepath.Clean(pattern)), "/"),
+		strings.Split(filepath.ToSlash(filepath.Clean(path)), "/"),
+	)
+}
+
+func matchSegments(pattern, path []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(path); i++ {
+				if ok, err := matchSegments(pattern[1:], path[i:]); ok || err != nil {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+		if len(path) == 0 {
+			return false, nil
+		}
+		if ok, err := filepath.Match(pattern[0], path[0]); !ok || err != nil {
+			return false, err
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0, nil
+}
+
+// listFiles returns the files in dir, up to maxDepth levels deep.
+func listFiles(dir string, maxDepth int) ([]string, error) {
+	var files []string
+	err := walk(dir, maxDepth, func(path string, d fs.DirEntry) bool {
+		if d.Type().IsRegular() || d.Type()&fs.ModeSymlink != 0 && isRegularFile(path) {
+			files = append(files, path)
+		}
+		return true
+	})
+	return files, err
+}
+
+func isRegularFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,125 @@
+package kubeconfig
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/kube/*.yaml", "/kube/dev.yaml", true},
+		{"/kube/*.yaml", "/kube/clients/dev.yaml", false},
+		{"/kube/**/*.yaml", "/kube/dev.yaml", true},
+		{"/kube/**/*.yaml", "/kube/clients/acme/dev.yaml", true},
+		{"/kube/**/*.yaml", "/kube/clients/acme/README.md", false},
+		{"/kube/clients/**", "/kube/clients/acme/dev.yaml", true},
+		{"/kube/clients/**", "/kube/other/dev.yaml", false},
+		{"/kube/**/cache", "/kube/clients/cache", true},
+		{"/kube/**/acme/*", "/kube/clients/acme/prod.yaml", true},
+		{"/kube/**/acme/*", "/kube/clients/acme", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			got, err := matchPattern(tt.pattern, tt.path)
+			if err != nil {
+				t.Fatalf("matchPattern() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGlob(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"config.yaml",
+		"clients/acme/dev.yaml",
+		"clients/acme/README.md",
+		"clients/globex/eu/prod.yaml",
+		"clients/.git/config.yaml",
+		"cache/discovery/servers.yaml",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		pattern  string
+		maxDepth int
+		want     []string
+	}{
+		{
+			name:     "without doublestar",
+			pattern:  "*.yaml",
+			maxDepth: 5,
+			want:     []string{"config.yaml"},
+		},
+		{
+			name:     "doublestar",
+			pattern:  "**/*.yaml",
+			maxDepth: 5,
+			want:     []string{"clients/acme/dev.yaml", "clients/globex/eu/prod.yaml", "config.yaml"},
+		},
+		{
+			name:     "doublestar below a directory",
+			pattern:  "clients/**/*.yaml",
+			maxDepth: 5,
+			want:     []string{"clients/acme/dev.yaml", "clients/globex/eu/prod.yaml"},
+		},
+		{
+			name:     "max depth",
+			pattern:  "**/*.yaml",
+			maxDepth: 3,
+			want:     []string{"clients/acme/dev.yaml", "config.yaml"},
+		},
+		{
+			name:     "missing directory",
+			pattern:  "missing/**/*.yaml",
+			maxDepth: 5,
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := Glob(filepath.Join(dir, tt.pattern), tt.maxDepth)
+			if err != nil {
+				t.Fatalf("Glob() error = %v", err)
+			}
+			var got []string
+			for _, match := range matches {
+				rel, _ := filepath.Rel(dir, match)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Glob() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGlob_InvalidPattern(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Glob(filepath.Join(dir, "**", "["), 5); err == nil {
+		t.Error("Glob() expected an error for a malformed pattern")
+	}
+}
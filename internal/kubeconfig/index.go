@@ -0,0 +1,243 @@
+package kubeconfig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/adrg/xdg"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// indexVersion is increased when the format of the index changes, which discards older indexes.
+const indexVersion = 1
+
+// maxKubeconfigSize is the size above which a file is not read, since it is not a kubeconfig.
+const maxKubeconfigSize = 16 << 20
+
+// DefaultIndexPath returns the path of the index of kubeconfig files, in the XDG cache directory.
+func DefaultIndexPath() string {
+	return filepath.Join(xdg.CacheHome, "kubert", "index.json")
+}
+
+// index holds the contexts of kubeconfig files, so files that did not change since they were
+// last loaded do not have to be parsed again.
+type index struct {
+	Version int                   `json:"version"`
+	Files   map[string]indexEntry `json:"files"`
+}
+
+type indexEntry struct {
+	ModTime time.Time `json:"modTime"`
+	Size    int64     `json:"size"`
+	Hash    string    `json:"hash"`
+
+	// Kubeconfig is false for files that are not kubeconfigs, like READMEs in included directories.
+	Kubeconfig bool `json:"kubeconfig"`
+
+	// Encrypted is true for encrypted kubeconfigs. Their contexts are not written to the index,
+	// they are decrypted in memory every time.
+	Encrypted bool `json:"encrypted,omitempty"`
+
+	// Error is why the kubeconfig could not be loaded, empty if it could.
+	Error string `json:"error,omitempty"`
+
+	Contexts []indexContext `json:"contexts,omitempty"`
+}
+
+type indexContext struct {
+	Name      string `json:"name"`
+	Cluster   string `json:"cluster,omitempty"`
+	User      string `json:"user,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+func (e indexEntry) equal(o indexEntry) bool {
+	return e.ModTime.Equal(o.ModTime) && e.Size == o.Size && e.Hash == o.Hash &&
+		e.Kubeconfig == o.Kubeconfig && e.Encrypted == o.Encrypted && e.Error == o.Error &&
+		slices.Equal(e.Contexts, o.Contexts)
+}
+
+// readIndex reads the index at path. A missing, unreadable or outdated index is empty.
+func readIndex(path string) *index {
+	empty := &index{Version: indexVersion, Files: make(map[string]indexEntry)}
+	if path == "" {
+		return empty
+	}
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Debug("ignoring unreadable kubeconfig index", "file", path, "error", err)
+		}
+		return empty
+	}
+	var idx index
+	if err := json.Unmarshal(data, &idx); err != nil || idx.Version != indexVersion || idx.Files == nil {
+		slog.Debug("ignoring outdated kubeconfig index", "file", path, "error", err)
+		return empty
+	}
+	return &idx
+}
+
+// write replaces the index at path. It is written to a temporary file first, so concurrent
+// kubert processes never read a partial index.
+func (idx *index) write(path string) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// update returns the entries of files. Files that changed since they were indexed are parsed
+// concurrently. It reports whether the index changed.
+func (idx *index) update(files []string) (map[string]indexEntry, bool) {
+	entries := make(map[string]indexEntry, len(files))
+	var stale []string
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			entries[file] = indexEntry{Kubeconfig: true, Error: err.Error()}
+			continue
+		}
+		if entry, ok := idx.Files[file]; ok && !entry.Encrypted && entry.ModTime.Equal(info.ModTime()) && entry.Size == info.Size() {
+			entries[file] = entry
+			continue
+		}
+		stale = append(stale, file)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	indexed := make(map[string]indexEntry, len(stale))
+	for _, file := range stale {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			entry, err := idx.indexFile(file)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				// Unreadable files are not indexed, so they are read again next time.
+				entries[file] = indexEntry{Kubeconfig: true, Error: err.Error()}
+				return
+			}
+			entries[file] = entry
+			indexed[file] = entry
+		}()
+	}
+	wg.Wait()
+
+	changed := false
+	for file, entry := range indexed {
+		if entry.Encrypted {
+			entry.Contexts, entry.Error = nil, ""
+		}
+		if old, ok := idx.Files[file]; !ok || !old.equal(entry) {
+			idx.Files[file] = entry
+			changed = true
+		}
+	}
+
+	// Forget files that no longer exist. Files that exist are kept even if they were not loaded
+	// this time, since other configs may include them.
+	for file := range idx.Files {
+		if _, ok := entries[file]; ok {
+			continue
+		}
+		if _, err := os.Stat(file); errors.Is(err, fs.ErrNotExist) {
+			delete(idx.Files, file)
+			changed = true
+		}
+	}
+	return entries, changed
+}
+
+// indexFile reads file, and parses it unless its content has the hash it was indexed with.
+// Encrypted files are always decrypted and parsed, and files larger than maxKubeconfigSize are
+// not read at all. idx is only read, so it can be called concurrently.
+func (idx *index) indexFile(file string) (indexEntry, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return indexEntry{}, err
+	}
+	if info.Size() > maxKubeconfigSize {
+		slog.Debug("skipping file that is too large to be a kubeconfig", "file", file, "size", info.Size())
+		return indexEntry{ModTime: info.ModTime(), Size: info.Size()}, nil
+	}
+	data, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return indexEntry{}, err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	entry, ok := idx.Files[file]
+	if !ok || entry.Hash != hash || entry.Encrypted {
+		entry = parseIndexEntry(data)
+		entry.Hash = hash
+	}
+	entry.ModTime = info.ModTime()
+	entry.Size = info.Size()
+	return entry, nil
+}
+
+// parseIndexEntry returns the index entry of a file with the given content.
+func parseIndexEntry(data []byte) indexEntry {
+	plaintext, encrypted, err := decryptData(data)
+	if err != nil {
+		return indexEntry{Kubeconfig: true, Encrypted: true, Error: fmt.Sprintf("failed to decrypt kubeconfig: %v", err)}
+	}
+	if !isKubeconfig(plaintext) {
+		return indexEntry{}
+	}
+	kubeconfig, err := clientcmd.Load(plaintext)
+	if err != nil {
+		return indexEntry{Kubeconfig: true, Encrypted: encrypted, Error: fmt.Sprintf("failed to load kubeconfig: %v", err)}
+	}
+
+	entry := indexEntry{Kubeconfig: true, Encrypted: encrypted}
+	for name, context := range kubeconfig.Contexts {
+		if name == "" || context == nil {
+			continue
+		}
+		entry.Contexts = append(entry.Contexts, indexContext{
+			Name:      name,
+			Cluster:   context.Cluster,
+			User:      context.AuthInfo,
+			Namespace: context.Namespace,
+		})
+	}
+	sort.Slice(entry.Contexts, func(i, j int) bool { return entry.Contexts[i].Name < entry.Contexts[j].Name })
+	return entry
+}
@@ -0,0 +1,134 @@
+package kubeconfig
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// loadIndexedContexts loads the contexts of the files in dir through the index at indexPath.
+func loadIndexedContexts(t *testing.T, dir, indexPath string) []Context {
+	t.Helper()
+	loader := NewLoader(WithProvider(NewFileSystemProvider([]string{dir}, nil)), WithIndex(indexPath))
+	contexts, err := loader.LoadContexts()
+	if err != nil {
+		t.Fatalf("LoadContexts() error = %v", err)
+	}
+	return contexts
+}
+
+func contextNames(contexts []Context) []string {
+	var names []string
+	for _, ctx := range contexts {
+		names = append(names, ctx.Name)
+	}
+	return names
+}
+
+// renameIndexedContext renames the context of file in the index, so tests can tell whether the
+// contexts were read from the index or from the file.
+func renameIndexedContext(t *testing.T, indexPath, file, name string) {
+	t.Helper()
+	idx := readIndex(indexPath)
+	entry, ok := idx.Files[file]
+	if !ok || len(entry.Contexts) != 1 {
+		t.Fatalf("index has no entry with one context for %s: %+v", file, idx.Files)
+	}
+	entry.Contexts[0].Name = name
+	idx.Files[file] = entry
+	if err := idx.write(indexPath); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoader_LoadContexts_Index(t *testing.T) {
+	dir := t.TempDir()
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	paths := writeTestKubeconfigs(t, dir, "dev", "prod")
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Clusters\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.yaml"), []byte("kind: Config\ncontexts: {}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	contexts := loadIndexedContexts(t, dir, indexPath)
+	if got := contextNames(contexts); !slices.Equal(got, []string{"dev", "prod"}) {
+		t.Fatalf("LoadContexts() = %v, want [dev prod]", got)
+	}
+	for _, ctx := range contexts {
+		if ctx.Cluster != ctx.Name || ctx.User != ctx.Name || ctx.Config != nil {
+			t.Errorf("context %q: cluster %q, user %q, config %v; want the names from the index and no config", ctx.Name, ctx.Cluster, ctx.User, ctx.Config)
+		}
+	}
+
+	idx := readIndex(indexPath)
+	if len(idx.Files) != 4 {
+		t.Errorf("index has %d files, want 4", len(idx.Files))
+	}
+	if entry := idx.Files[filepath.Join(dir, "README.md")]; entry.Kubeconfig {
+		t.Error("README.md is indexed as a kubeconfig")
+	}
+	if entry := idx.Files[filepath.Join(dir, "broken.yaml")]; entry.Error == "" {
+		t.Error("broken.yaml is indexed without an error")
+	}
+
+	t.Run("unchanged files are read from the index", func(t *testing.T) {
+		renameIndexedContext(t, indexPath, paths[0], "dev-from-index")
+		if got := contextNames(loadIndexedContexts(t, dir, indexPath)); !slices.Equal(got, []string{"dev-from-index", "prod"}) {
+			t.Errorf("LoadContexts() = %v, want the context from the index", got)
+		}
+	})
+
+	t.Run("touched files with the same content are not parsed", func(t *testing.T) {
+		later := time.Now().Add(time.Hour)
+		if err := os.Chtimes(paths[0], later, later); err != nil {
+			t.Fatal(err)
+		}
+		if got := contextNames(loadIndexedContexts(t, dir, indexPath)); !slices.Equal(got, []string{"dev-from-index", "prod"}) {
+			t.Errorf("LoadContexts() = %v, want the context from the index", got)
+		}
+		if entry := readIndex(indexPath).Files[paths[0]]; !entry.ModTime.Equal(later) {
+			t.Errorf("indexed modification time = %v, want %v", entry.ModTime, later)
+		}
+	})
+
+	t.Run("changed files are parsed", func(t *testing.T) {
+		if err := os.WriteFile(paths[0], []byte(testKubeconfig("staging")), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if got := contextNames(loadIndexedContexts(t, dir, indexPath)); !slices.Equal(got, []string{"staging", "prod"}) {
+			t.Errorf("LoadContexts() = %v, want the new context of the file", got)
+		}
+	})
+
+	t.Run("deleted files are removed from the index", func(t *testing.T) {
+		if err := os.Remove(paths[1]); err != nil {
+			t.Fatal(err)
+		}
+		if got := contextNames(loadIndexedContexts(t, dir, indexPath)); !slices.Equal(got, []string{"staging"}) {
+			t.Errorf("LoadContexts() = %v, want [staging]", got)
+		}
+		if _, ok := readIndex(indexPath).Files[paths[1]]; ok {
+			t.Error("deleted file is still in the index")
+		}
+	})
+}
+
+func TestReadIndex_Outdated(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	data, err := json.Marshal(index{Version: indexVersion + 1, Files: map[string]indexEntry{"/kube/config": {Kubeconfig: true}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(indexPath, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if idx := readIndex(indexPath); len(idx.Files) != 0 || idx.Version != indexVersion {
+		t.Errorf("readIndex() = %+v, want an empty index", idx)
+	}
+}
@@ -1,14 +1,19 @@
 package kubeconfig
 
 import (
+	"cmp"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
-	"k8s.io/client-go/tools/clientcmd"
+	"go.yaml.in/yaml/v4"
 	"k8s.io/client-go/tools/clientcmd/api"
 
+	"github.com/idebeijer/kubert/internal/config"
 	"github.com/idebeijer/kubert/internal/util"
 )
 
@@ -16,22 +21,90 @@ import (
 type WithPath struct {
 	Config   *api.Config
 	FilePath string
+
+	// Encrypted is true if the file is encrypted. Config is decrypted in memory.
+	Encrypted bool
 }
 
 type Context struct {
+	// Name is the unique name kubert uses for the context. It differs from the name in the
+	// kubeconfig file when a duplicate name was qualified, see DuplicateStrategy.
 	Name string
+
+	// OriginalName is the name of the context in its kubeconfig file, empty if it is Name.
+	OriginalName string
+
+	// Provider is the name of the provider the kubeconfig was loaded by.
+	Provider string
+
+	// Cluster, User and Namespace are the cluster, user and default namespace of the context in
+	// its kubeconfig file.
+	Cluster   string
+	User      string
+	Namespace string
+
+	// WithPath is the kubeconfig file of the context. Config is only set for providers that are
+	// not a FileProvider; the kubeconfigs of file providers are read through the index instead
+	// of being parsed, so read FilePath when the whole kubeconfig is needed.
 	WithPath
 }
 
+// KubeconfigName returns the name of the context in its kubeconfig file.
+func (c Context) KubeconfigName() string {
+	if c.OriginalName != "" {
+		return c.OriginalName
+	}
+	return c.Name
+}
+
 // Provider interface for different kubeconfig sources
 type Provider interface {
 	Load() ([]WithPath, error)
 }
 
+// FileProvider is a Provider of kubeconfig files. Loader.LoadContexts reads the contexts of
+// its files through the index, so files that did not change are not parsed again.
+type FileProvider interface {
+	Provider
+	// Files returns the paths of the kubeconfig files.
+	Files() ([]string, error)
+}
+
+// NamedProvider is a Provider with a name, which is used to qualify duplicate context names.
+// Providers without a name are named after their position, e.g. "provider-2".
+type NamedProvider interface {
+	Provider
+	Name() string
+}
+
+// DuplicateStrategy decides what happens when more than one kubeconfig defines a context
+// with the same name.
+type DuplicateStrategy string
+
+const (
+	// DuplicateError fails loading the contexts.
+	DuplicateError DuplicateStrategy = "error"
+	// DuplicateFirstWins keeps the context that was loaded first and drops the others.
+	DuplicateFirstWins DuplicateStrategy = "first-wins"
+	// DuplicatePrefixFilename names each duplicate after its file, e.g. "staging.yaml/admin".
+	// Files with the same name get parent directories until they differ, e.g. "a/prod.yaml/admin".
+	DuplicatePrefixFilename DuplicateStrategy = "prefix-with-filename"
+	// DuplicatePrefixProvider names each duplicate after its provider, e.g. "local/admin".
+	// Duplicates within one provider are also named after their file, e.g. "local/dev.yaml/admin".
+	DuplicatePrefixProvider DuplicateStrategy = "prefix-with-provider"
+)
+
+// LocalProviderName is the name of the FileSystemProvider.
+const LocalProviderName = "local"
+
 // FileSystemProvider struct to load kubeconfigs from filesystem
 type FileSystemProvider struct {
 	IncludePatterns []string
 	ExcludePatterns []string
+
+	// MaxDepth is how many levels deep included directories and "**" patterns are scanned.
+	// Zero means DefaultMaxDepth.
+	MaxDepth int
 }
 
 // NewFileSystemProvider returns a new FileSystemProvider
@@ -42,79 +115,156 @@ func NewFileSystemProvider(IncludePatterns, ExcludePatterns []string) *FileSyste
 	}
 }
 
+// Name returns the name of the provider.
+func (f *FileSystemProvider) Name() string {
+	return LocalProviderName
+}
+
 func (f *FileSystemProvider) Load() ([]WithPath, error) {
-	var kubeconfigs []WithPath
-	files, err := findFiles(f.IncludePatterns)
+	files, err := f.Files()
 	if err != nil {
 		return nil, err
 	}
 
-	filteredFiles, err := filterFiles(files, f.ExcludePatterns)
-	if err != nil {
-		return nil, err
-	}
+	var kubeconfigs []WithPath
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Clean(file))
+		if err != nil {
+			slog.Warn("skipping unreadable kubeconfig", "file", file, "error", err)
+			continue
+		}
+		if !isKubeconfig(data) && !isAgeEncrypted(data) {
+			slog.Debug("skipping file that is not a kubeconfig", "file", file)
+			continue
+		}
 
-	for _, file := range filteredFiles {
-		kubeconfig, err := clientcmd.LoadFromFile(file)
+		kubeconfig, err := LoadFile(file)
 		if err != nil {
 			slog.Warn("skipping unreadable kubeconfig", "file", file, "error", err)
 			continue
 		}
-		kubeconfigs = append(kubeconfigs, WithPath{Config: kubeconfig, FilePath: file})
+		kubeconfigs = append(kubeconfigs, kubeconfig)
 	}
 
 	return kubeconfigs, nil
 }
 
-func findFiles(patterns []string) ([]string, error) {
+// Files returns the included files that are not excluded. They may contain files that are not
+// kubeconfigs, which Load and the index skip.
+func (f *FileSystemProvider) Files() ([]string, error) {
+	files, err := findFiles(f.IncludePatterns, f.MaxDepth)
+	if err != nil {
+		return nil, err
+	}
+	return filterFiles(files, f.ExcludePatterns)
+}
+
+// isKubeconfig reports whether data looks like a kubeconfig, so other files in included
+// directories, like READMEs, are skipped without a warning.
+func isKubeconfig(data []byte) bool {
+	var fields map[string]any
+	if err := yaml.Unmarshal(data, &fields); err != nil {
+		return false
+	}
+	if kind, _ := fields["kind"].(string); kind == "Config" {
+		return true
+	}
+	for _, key := range []string{"clusters", "contexts", "users"} {
+		if _, ok := fields[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// findFiles returns the files matching patterns, in order and without duplicates. Matching
+// directories are replaced by the files in them, up to maxDepth levels deep. Matches that
+// cannot be read are skipped with a warning.
+func findFiles(patterns []string, maxDepth int) ([]string, error) {
 	var files []string
+	seen := make(map[string]bool)
 	for _, pattern := range patterns {
 		expandedPattern, err := util.ExpandPath(pattern)
 		if err != nil {
 			return nil, fmt.Errorf("failed to expand pattern %s: %w", pattern, err)
 		}
-		matches, err := filepath.Glob(expandedPattern)
+		matches, err := Glob(expandedPattern, maxDepth)
 		if err != nil {
 			return nil, fmt.Errorf("failed to glob pattern %s: %w", expandedPattern, err)
 		}
-		files = append(files, matches...)
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				// e.g. a broken symlink, which should not keep the other kubeconfigs from loading.
+				slog.Warn("skipping unreadable kubeconfig path", "path", match, "error", err)
+				continue
+			}
+			found := []string{match}
+			if info.IsDir() {
+				if found, err = listFiles(match, maxDepth); err != nil {
+					return nil, err
+				}
+			}
+			for _, file := range found {
+				if !seen[file] {
+					seen[file] = true
+					files = append(files, file)
+				}
+			}
+		}
 	}
 	return files, nil
 }
 
+// filterFiles removes the files matching one of excludePatterns, or in a directory that does.
 func filterFiles(files []string, excludePatterns []string) ([]string, error) {
-	var filteredFiles []string
-	excludeMap := make(map[string]bool)
+	var expandedPatterns []string
 	for _, pattern := range excludePatterns {
 		expandedPattern, err := util.ExpandPath(pattern)
 		if err != nil {
 			return nil, fmt.Errorf("failed to expand exclude pattern %s: %w", pattern, err)
 		}
-		matches, err := filepath.Glob(expandedPattern)
+		expandedPatterns = append(expandedPatterns, expandedPattern)
+	}
+
+	var filteredFiles []string
+	for _, file := range files {
+		excluded, err := isExcluded(file, expandedPatterns)
 		if err != nil {
-			return nil, fmt.Errorf("failed to glob exclude pattern %s: %w", expandedPattern, err)
+			return nil, err
 		}
-		for _, match := range matches {
-			excludeMap[match] = true
+		if !excluded {
+			filteredFiles = append(filteredFiles, file)
 		}
 	}
-	for _, file := range files {
-		if !excludeMap[file] {
-			info, err := os.Stat(file)
+	return filteredFiles, nil
+}
+
+func isExcluded(file string, patterns []string) (bool, error) {
+	for path := filepath.Clean(file); ; path = filepath.Dir(path) {
+		for _, pattern := range patterns {
+			ok, err := matchPattern(pattern, path)
 			if err != nil {
-				return nil, fmt.Errorf("failed to stat file %s: %w", file, err)
+				return false, fmt.Errorf("failed to glob exclude pattern %s: %w", pattern, err)
 			}
-			if !info.IsDir() {
-				filteredFiles = append(filteredFiles, file)
+			if ok {
+				return true, nil
 			}
 		}
+		if parent := filepath.Dir(path); parent == path {
+			return false, nil
+		}
 	}
-	return filteredFiles, nil
 }
 
 // Loader struct to handle multiple providers
 type Loader struct {
-	Providers []Provider
+	Providers  []Provider
+	Duplicates DuplicateStrategy
+
+	// IndexPath is the file the contexts of the files of FileProviders are cached in. If empty,
+	// the files are parsed every time.
+	IndexPath string
 }
 
 // LoaderOption is a functional option for configuring a Loader
@@ -127,6 +277,53 @@ func WithProvider(provider Provider) LoaderOption {
 	}
 }
 
+// WithDuplicateStrategy sets how the loader handles duplicate context names
+func WithDuplicateStrategy(strategy DuplicateStrategy) LoaderOption {
+	return func(l *Loader) {
+		l.Duplicates = strategy
+	}
+}
+
+// WithIndex sets the file the loader caches the contexts of kubeconfig files in
+func WithIndex(path string) LoaderOption {
+	return func(l *Loader) {
+		l.IndexPath = path
+	}
+}
+
+// NewLoaderFromConfig creates a Loader for the kubeconfigs configured in cfg
+func NewLoaderFromConfig(cfg config.Config) (*Loader, error) {
+	fsProvider := NewFileSystemProvider(cfg.KubeconfigPaths.Include, cfg.KubeconfigPaths.Exclude)
+	fsProvider.MaxDepth = cfg.KubeconfigPaths.MaxDepth
+
+	var sources []Provider
+	env := cfg.KubeconfigPaths.Env
+	switch {
+	case !env.Enabled:
+		sources = []Provider{fsProvider}
+	case env.Precedence == "" || env.Precedence == "include":
+		sources = []Provider{fsProvider, NewEnvProvider()}
+	case env.Precedence == "env":
+		sources = []Provider{NewEnvProvider(), fsProvider}
+	default:
+		return nil, fmt.Errorf("invalid kubeconfigs.env.precedence %q, must be include or env", env.Precedence)
+	}
+
+	options := []LoaderOption{
+		WithDuplicateStrategy(DuplicateStrategy(cfg.KubeconfigPaths.Duplicates)),
+		WithIndex(DefaultIndexPath()),
+	}
+	providers, err := newProvidersFromConfig(cfg, sources, options)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, provider := range append(sources, providers...) {
+		options = append(options, WithProvider(provider))
+	}
+	return NewLoader(options...), nil
+}
+
 // NewLoader creates a new Loader with the given options
 func NewLoader(options ...LoaderOption) *Loader {
 	loader := &Loader{
@@ -142,51 +339,289 @@ func NewLoader(options ...LoaderOption) *Loader {
 
 // LoadAll method to load all kubeconfigs from all providers
 func (l *Loader) LoadAll() ([]WithPath, error) {
+	allKubeconfigs, _, err := l.loadKubeconfigs()
+	return allKubeconfigs, err
+}
+
+// loadKubeconfigs loads the kubeconfigs of all providers, and returns the name of the provider
+// of each. A file that is loaded by more than one provider is only kept for the first.
+func (l *Loader) loadKubeconfigs() ([]WithPath, []string, error) {
 	var allKubeconfigs []WithPath
-	for _, provider := range l.Providers {
+	var providerNames []string
+	seen := make(map[string]bool)
+
+	for i, provider := range l.Providers {
+		providerName := l.providerName(i)
 		kubeconfigs, err := provider.Load()
 		if err != nil {
-			return nil, fmt.Errorf("provider failed to load kubeconfigs: %w", err)
+			return nil, nil, fmt.Errorf("provider failed to load kubeconfigs: %w", err)
+		}
+
+		for _, kubeconfig := range kubeconfigs {
+			if !firstLoad(seen, kubeconfig.FilePath, providerName) {
+				continue
+			}
+			allKubeconfigs = append(allKubeconfigs, kubeconfig)
+			providerNames = append(providerNames, providerName)
 		}
-		allKubeconfigs = append(allKubeconfigs, kubeconfigs...)
 	}
 
-	return allKubeconfigs, nil
+	return allKubeconfigs, providerNames, nil
 }
 
-func (l *Loader) LoadContexts() ([]Context, error) {
-	allKubeconfigs, err := l.LoadAll()
+func (l *Loader) providerName(i int) string {
+	if named, ok := l.Providers[i].(NamedProvider); ok {
+		return named.Name()
+	}
+	return fmt.Sprintf("provider-%d", i+1)
+}
+
+// firstLoad reports whether file was not loaded before, and marks it as loaded.
+func firstLoad(seen map[string]bool, file, providerName string) bool {
+	if file == "" {
+		return true
+	}
+	path, err := filepath.Abs(file)
 	if err != nil {
-		return nil, err
+		path = filepath.Clean(file)
+	}
+	if seen[path] {
+		slog.Debug("skipping kubeconfig that was already loaded", "file", file, "provider", providerName)
+		return false
+	}
+	seen[path] = true
+	return true
+}
+
+// LoadContexts loads the contexts of all kubeconfigs. Context names that are defined more
+// than once are handled according to the duplicate strategy of the loader.
+func (l *Loader) LoadContexts() ([]Context, error) {
+	switch l.Duplicates {
+	case "", DuplicateError, DuplicateFirstWins, DuplicatePrefixFilename, DuplicatePrefixProvider:
+	default:
+		return nil, fmt.Errorf("invalid duplicate strategy %q, must be %s, %s, %s or %s",
+			l.Duplicates, DuplicateError, DuplicateFirstWins, DuplicatePrefixFilename, DuplicatePrefixProvider)
+	}
+
+	// A source is a kubeconfig of a provider: a parsed kubeconfig, or a file of a FileProvider
+	// that is read through the index.
+	type source struct {
+		provider   string
+		kubeconfig WithPath
+		indexed    bool
+	}
+	var sources []source
+	var files []string
+	seen := make(map[string]bool)
+
+	for i, provider := range l.Providers {
+		providerName := l.providerName(i)
+		if fileProvider, ok := provider.(FileProvider); ok {
+			providerFiles, err := fileProvider.Files()
+			if err != nil {
+				return nil, fmt.Errorf("provider failed to load kubeconfigs: %w", err)
+			}
+			for _, file := range providerFiles {
+				if firstLoad(seen, file, providerName) {
+					sources = append(sources, source{provider: providerName, kubeconfig: WithPath{FilePath: file}, indexed: true})
+					files = append(files, file)
+				}
+			}
+			continue
+		}
+
+		kubeconfigs, err := provider.Load()
+		if err != nil {
+			return nil, fmt.Errorf("provider failed to load kubeconfigs: %w", err)
+		}
+		for _, kubeconfig := range kubeconfigs {
+			if firstLoad(seen, kubeconfig.FilePath, providerName) {
+				sources = append(sources, source{provider: providerName, kubeconfig: kubeconfig})
+			}
+		}
+	}
+
+	idx := readIndex(l.IndexPath)
+	entries, changed := idx.update(files)
+	if changed && l.IndexPath != "" {
+		if err := idx.write(l.IndexPath); err != nil {
+			slog.Debug("failed to write kubeconfig index", "file", l.IndexPath, "error", err)
+		}
 	}
 
 	var contexts []Context
-	contextSources := make(map[string]string)
+	names := make(map[string][]int)
+	for _, src := range sources {
+		for _, c := range sourceContexts(src.kubeconfig, src.indexed, entries) {
+			c.Provider = src.provider
+			c.WithPath = src.kubeconfig
+			names[c.Name] = append(names[c.Name], len(contexts))
+			contexts = append(contexts, c)
+		}
+	}
+
+	return l.resolveDuplicates(contexts, names)
+}
+
+// sourceContexts returns the contexts of a kubeconfig sorted by name, from the index entry of
+// its file if it is indexed.
+func sourceContexts(kubeconfig WithPath, indexed bool, entries map[string]indexEntry) []Context {
+	var contexts []Context
+	if indexed {
+		entry := entries[kubeconfig.FilePath]
+		if !entry.Kubeconfig {
+			slog.Debug("skipping file that is not a kubeconfig", "file", kubeconfig.FilePath)
+			return nil
+		}
+		if entry.Error != "" {
+			slog.Warn("skipping unreadable kubeconfig", "file", kubeconfig.FilePath, "error", entry.Error)
+			return nil
+		}
+		for _, c := range entry.Contexts {
+			contexts = append(contexts, Context{Name: c.Name, Cluster: c.Cluster, User: c.User, Namespace: c.Namespace})
+		}
+		return contexts
+	}
 
-	for _, kubeconfig := range allKubeconfigs {
-		if kubeconfig.Config.Contexts == nil {
+	if kubeconfig.Config == nil {
+		return nil
+	}
+	for name, c := range kubeconfig.Config.Contexts {
+		if name == "" {
 			continue
 		}
-		for contextName := range kubeconfig.Config.Contexts {
-			if contextName == "" {
-				continue
+		context := Context{Name: name}
+		if c != nil {
+			context.Cluster, context.User, context.Namespace = c.Cluster, c.AuthInfo, c.Namespace
+		}
+		contexts = append(contexts, context)
+	}
+	sort.Slice(contexts, func(i, j int) bool { return contexts[i].Name < contexts[j].Name })
+	return contexts
+}
+
+// resolveDuplicates applies the duplicate strategy to the contexts. sources holds the indexes
+// of the contexts with each name, in load order.
+func (l *Loader) resolveDuplicates(contexts []Context, sources map[string][]int) ([]Context, error) {
+	drop := make(map[int]bool)
+	for name, indexes := range sources {
+		if len(indexes) < 2 {
+			continue
+		}
+
+		switch l.Duplicates {
+		case "", DuplicateError:
+			return nil, fmt.Errorf(
+				"duplicate context name %q found:\n  - %s\n  - %s\n\n"+
+					"Kubert requires unique context names across all kubeconfig files.\n"+
+					"Please rename one of these contexts to avoid conflicts, or set kubeconfigs.duplicates in the config",
+				name, contexts[indexes[0]].FilePath, contexts[indexes[1]].FilePath)
+		case DuplicateFirstWins:
+			for _, i := range indexes[1:] {
+				slog.Debug("Skipping duplicate context", "context", name, "file", contexts[i].FilePath, "kept", contexts[indexes[0]].FilePath)
+				drop[i] = true
+			}
+		case DuplicatePrefixFilename:
+			paths := make([]string, len(indexes))
+			for j, i := range indexes {
+				// Kubeconfigs that were only loaded in memory are named after their provider.
+				paths[j] = cmp.Or(contexts[i].FilePath, contexts[i].Provider)
 			}
+			for j, qualifier := range pathQualifiers(paths) {
+				contexts[indexes[j]].OriginalName = name
+				contexts[indexes[j]].Name = qualifier + "/" + name
+			}
+		case DuplicatePrefixProvider:
+			byProvider := make(map[string][]int)
+			for _, i := range indexes {
+				byProvider[contexts[i].Provider] = append(byProvider[contexts[i].Provider], i)
+			}
+			for provider, same := range byProvider {
+				qualifiers := make([]string, len(same))
+				if len(same) > 1 {
+					paths := make([]string, len(same))
+					for j, i := range same {
+						paths[j] = contexts[i].FilePath
+					}
+					qualifiers = pathQualifiers(paths)
+				}
+				for j, i := range same {
+					prefix := provider
+					if qualifiers[j] != "" {
+						prefix += "/" + qualifiers[j]
+					}
+					contexts[i].OriginalName = name
+					contexts[i].Name = prefix + "/" + name
+				}
+			}
+		}
+	}
+
+	result := make([]Context, 0, len(contexts))
+	seen := make(map[string]string, len(contexts))
+	for i, ctx := range contexts {
+		if drop[i] {
+			continue
+		}
+		if existingSource, exists := seen[ctx.Name]; exists {
+			return nil, fmt.Errorf("duplicate context name %q found after applying the %s strategy:\n  - %s\n  - %s",
+				ctx.Name, l.Duplicates, existingSource, ctx.FilePath)
+		}
+		seen[ctx.Name] = ctx.FilePath
+		result = append(result, ctx)
+	}
+	return result, nil
+}
 
-			if existingSource, exists := contextSources[contextName]; exists {
-				return nil, fmt.Errorf(
-					"duplicate context name %q found:\n  - %s\n  - %s\n\n"+
-						"Kubert requires unique context names across all kubeconfig files.\n"+
-						"Please rename one of these contexts to avoid conflicts",
-					contextName, existingSource, kubeconfig.FilePath)
+// pathQualifiers returns the shortest trailing part of each path that tells it apart from the
+// other paths, starting with the file name and adding parent directories until it is unique, so
+// "clients/a/prod.yaml" and "clients/b/prod.yaml" become "a/prod.yaml" and "b/prod.yaml".
+// Paths that cannot be told apart, like kubeconfigs that were only loaded in memory, get their
+// 1-based position appended.
+func pathQualifiers(paths []string) []string {
+	parts := make([][]string, len(paths))
+	depth := 0
+	for i, path := range paths {
+		for part := range strings.SplitSeq(filepath.ToSlash(path), "/") {
+			if part != "" {
+				parts[i] = append(parts[i], part)
 			}
+		}
+		depth = max(depth, len(parts[i]))
+	}
 
-			contextSources[contextName] = kubeconfig.FilePath
-			contexts = append(contexts, Context{
-				Name:     contextName,
-				WithPath: kubeconfig,
-			})
+	suffix := func(i, d int) string {
+		return strings.Join(parts[i][max(len(parts[i])-d, 0):], "/")
+	}
+	qualifiers := make([]string, len(paths))
+	for i := range paths {
+	depths:
+		for d := 1; d <= max(depth, 1); d++ {
+			qualifiers[i] = suffix(i, d)
+			for j := range paths {
+				if j != i && suffix(j, d) == qualifiers[i] {
+					continue depths
+				}
+			}
+			break
 		}
 	}
 
-	return contexts, nil
+	counts := make(map[string]int, len(paths))
+	for _, qualifier := range qualifiers {
+		counts[qualifier]++
+	}
+	positions := make(map[string]int, len(paths))
+	for i, qualifier := range qualifiers {
+		if counts[qualifier] < 2 {
+			continue
+		}
+		positions[qualifier]++
+		if qualifier == "" {
+			qualifiers[i] = strconv.Itoa(positions[qualifier])
+		} else {
+			qualifiers[i] = fmt.Sprintf("%s-%d", qualifier, positions[qualifier])
+		}
+	}
+	return qualifiers
 }
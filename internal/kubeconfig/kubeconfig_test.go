@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
@@ -47,6 +48,88 @@ func TestFileSystemProvider_Load(t *testing.T) {
 	}
 }
 
+func TestFileSystemProvider_Load_Directories(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"clients/acme/dev.yaml":       "apiVersion: v1\nkind: Config\ncontexts: []\n",
+		"clients/acme/README.md":      "# Acme\n\nThe dev cluster of acme.\n",
+		"clients/acme/notes.yaml":     "owner: ops\n",
+		"clients/globex/eu/prod.json": `{"apiVersion": "v1", "kind": "Config", "contexts": []}`,
+		"clients/globex/cache/x.yaml": "apiVersion: v1\nkind: Config\n",
+		"clients/.git/config.yaml":    "apiVersion: v1\nkind: Config\n",
+		"config":                      "apiVersion: v1\nkind: Config\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(dir, "missing.yaml"), filepath.Join(dir, "clients", "broken.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		maxDepth int
+		want     []string
+	}{
+		{
+			name:    "directory",
+			include: []string{filepath.Join(dir, "clients")},
+			exclude: []string{filepath.Join(dir, "**", "cache")},
+			want:    []string{"clients/acme/dev.yaml", "clients/globex/eu/prod.json"},
+		},
+		{
+			name:    "directory skips hidden and cache directories",
+			include: []string{filepath.Join(dir, "clients")},
+			want:    []string{"clients/acme/dev.yaml", "clients/globex/eu/prod.json"},
+		},
+		{
+			name:    "doublestar skips broken symlinks",
+			include: []string{filepath.Join(dir, "clients", "**", "*.yaml")},
+			want:    []string{"clients/acme/dev.yaml"},
+		},
+		{
+			name:     "directory with max depth",
+			include:  []string{filepath.Join(dir, "clients")},
+			maxDepth: 2,
+			want:     []string{"clients/acme/dev.yaml"},
+		},
+		{
+			name:    "doublestar and overlapping patterns",
+			include: []string{filepath.Join(dir, "config"), filepath.Join(dir, "**", "*.yaml"), dir},
+			exclude: []string{filepath.Join(dir, "clients", "globex")},
+			want:    []string{"config", "clients/acme/dev.yaml"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewFileSystemProvider(tt.include, tt.exclude)
+			provider.MaxDepth = tt.maxDepth
+			kubeconfigs, err := provider.Load()
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			var got []string
+			for _, k := range kubeconfigs {
+				rel, _ := filepath.Rel(dir, k.FilePath)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Load() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestLoader_LoadAll(t *testing.T) {
 	kubeconfig := &api.Config{}
 	mockProvider := &MockProvider{kubeconfigs: []WithPath{{Config: kubeconfig, FilePath: "config"}}}
@@ -105,6 +188,7 @@ func TestFileSystemProvider_Load_TildeExpansion(t *testing.T) {
 	// This test ensures that using "~" as a pattern does not panic.
 	// We don't assert on the result because it depends on the user's home directory content,
 	// but we want to make sure the function returns (no panic).
+	t.Setenv("HOME", t.TempDir())
 	provider := NewFileSystemProvider([]string{"~"}, nil)
 	_, err := provider.Load()
 	if err != nil {
@@ -155,6 +239,124 @@ func TestLoader_LoadContexts_DuplicateDetection(t *testing.T) {
 	}
 }
 
+func TestLoader_LoadContexts_DuplicateStrategies(t *testing.T) {
+	newProvider := func(paths ...string) *MockProvider {
+		p := &MockProvider{}
+		for _, path := range paths {
+			p.kubeconfigs = append(p.kubeconfigs, WithPath{
+				Config:   &api.Config{Contexts: map[string]*api.Context{"kind-kind": {Cluster: path}, path + "-only": {}}},
+				FilePath: "/home/me/.kube/" + path,
+			})
+		}
+		return p
+	}
+
+	tests := []struct {
+		name      string
+		strategy  DuplicateStrategy
+		providers []Provider
+		expected  map[string]string // context name -> file it comes from
+		errMsg    string
+	}{
+		{
+			name:      "first wins",
+			strategy:  DuplicateFirstWins,
+			providers: []Provider{newProvider("dev.yaml", "staging.yaml")},
+			expected: map[string]string{
+				"kind-kind":         "dev.yaml",
+				"dev.yaml-only":     "dev.yaml",
+				"staging.yaml-only": "staging.yaml",
+			},
+		},
+		{
+			name:      "prefix with filename",
+			strategy:  DuplicatePrefixFilename,
+			providers: []Provider{newProvider("dev.yaml", "staging.yaml")},
+			expected: map[string]string{
+				"dev.yaml/kind-kind":     "dev.yaml",
+				"staging.yaml/kind-kind": "staging.yaml",
+				"dev.yaml-only":          "dev.yaml",
+				"staging.yaml-only":      "staging.yaml",
+			},
+		},
+		{
+			name:      "prefix with provider",
+			strategy:  DuplicatePrefixProvider,
+			providers: []Provider{newProvider("dev.yaml"), newProvider("staging.yaml")},
+			expected: map[string]string{
+				"provider-1/kind-kind": "dev.yaml",
+				"provider-2/kind-kind": "staging.yaml",
+				"dev.yaml-only":        "dev.yaml",
+				"staging.yaml-only":    "staging.yaml",
+			},
+		},
+		{
+			name:      "prefix with filename in sibling directories",
+			strategy:  DuplicatePrefixFilename,
+			providers: []Provider{newProvider("clients/a/prod.yaml", "clients/b/prod.yaml", "dev.yaml")},
+			expected: map[string]string{
+				"a/prod.yaml/kind-kind":    "clients/a/prod.yaml",
+				"b/prod.yaml/kind-kind":    "clients/b/prod.yaml",
+				"dev.yaml/kind-kind":       "dev.yaml",
+				"clients/a/prod.yaml-only": "clients/a/prod.yaml",
+				"clients/b/prod.yaml-only": "clients/b/prod.yaml",
+				"dev.yaml-only":            "dev.yaml",
+			},
+		},
+		{
+			name:      "prefix with provider within one provider",
+			strategy:  DuplicatePrefixProvider,
+			providers: []Provider{newProvider("dev.yaml", "staging.yaml"), newProvider("prod.yaml")},
+			expected: map[string]string{
+				"provider-1/dev.yaml/kind-kind":     "dev.yaml",
+				"provider-1/staging.yaml/kind-kind": "staging.yaml",
+				"provider-2/kind-kind":              "prod.yaml",
+				"dev.yaml-only":                     "dev.yaml",
+				"staging.yaml-only":                 "staging.yaml",
+				"prod.yaml-only":                    "prod.yaml",
+			},
+		},
+		{
+			name:      "invalid strategy",
+			strategy:  "rename",
+			providers: []Provider{newProvider("dev.yaml")},
+			errMsg:    `invalid duplicate strategy "rename"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := &Loader{Providers: tt.providers, Duplicates: tt.strategy}
+			contexts, err := loader.LoadContexts()
+			if tt.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Fatalf("LoadContexts() error = %v, want error containing %q", err, tt.errMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadContexts() unexpected error: %v", err)
+			}
+
+			got := make(map[string]string, len(contexts))
+			for _, ctx := range contexts {
+				got[ctx.Name] = strings.TrimPrefix(ctx.FilePath, "/home/me/.kube/")
+				if _, ok := ctx.Config.Contexts[ctx.KubeconfigName()]; !ok {
+					t.Errorf("context %q: KubeconfigName() = %q is not in its kubeconfig", ctx.Name, ctx.KubeconfigName())
+				}
+			}
+			if len(got) != len(tt.expected) {
+				t.Errorf("LoadContexts() = %v, want %v", got, tt.expected)
+			}
+			for name, file := range tt.expected {
+				if got[name] != file {
+					t.Errorf("context %q comes from %q, want %q", name, got[name], file)
+				}
+			}
+		})
+	}
+}
+
 func TestLoader_LoadContexts_NoDuplicates(t *testing.T) {
 	kubeconfig1 := &api.Config{
 		Contexts: map[string]*api.Context{
@@ -205,3 +407,25 @@ func TestLoader_LoadContexts_NoDuplicates(t *testing.T) {
 		}
 	}
 }
+
+func TestPathQualifiers(t *testing.T) {
+	tests := []struct {
+		name     string
+		paths    []string
+		expected []string
+	}{
+		{name: "file names", paths: []string{"/kube/dev.yaml", "/kube/prod.yaml"}, expected: []string{"dev.yaml", "prod.yaml"}},
+		{name: "sibling directories", paths: []string{"/kube/clients/a/prod.yaml", "/kube/clients/b/prod.yaml"}, expected: []string{"a/prod.yaml", "b/prod.yaml"}},
+		{name: "different depths", paths: []string{"/kube/prod.yaml", "/kube/clients/prod.yaml"}, expected: []string{"kube/prod.yaml", "clients/prod.yaml"}},
+		{name: "same names", paths: []string{"exec", "exec", "gcp"}, expected: []string{"exec-1", "exec-2", "gcp"}},
+		{name: "no paths", paths: []string{"", ""}, expected: []string{"1", "2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pathQualifiers(tt.paths); !slices.Equal(got, tt.expected) {
+				t.Errorf("pathQualifiers(%v) = %v, want %v", tt.paths, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package kubeconfig
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// clusterNameLabel is the label Cluster API puts on the Secrets of a cluster.
+	clusterNameLabel = "cluster.x-k8s.io/cluster-name"
+
+	// DefaultSecretKey is the key of the kubeconfig in the Secrets of Cluster API.
+	DefaultSecretKey = "value"
+
+	secretsTimeout = 30 * time.Second
+)
+
+// SecretsProvider loads the kubeconfigs stored in Secrets in a management cluster, e.g. the
+// "<cluster>-kubeconfig" Secrets of Cluster API. The context of each kubeconfig is renamed to
+// "<management context>/<cluster>", with the namespace of the Secret in between when the Secrets
+// of all namespaces are listed. The kubeconfigs are cached for the TTL.
+type SecretsProvider struct {
+	name          string
+	context       string
+	namespace     string
+	labelSelector string
+	key           string
+	ttl           time.Duration
+	cacheDir      string
+
+	// sources loads the contexts the management context is looked up in, named like the loader
+	// of kubert names them, so it can be a qualified name or an alias.
+	sources *Loader
+	aliases *Aliases
+
+	// client returns the client of the management cluster. It is a field so tests can replace it.
+	client func() (kubernetes.Interface, error)
+}
+
+// NewSecretsProvider returns a SecretsProvider that lists the Secrets matching labelSelector in
+// namespace, all namespaces if empty, of the management context, the context of sources with
+// that name or alias. The kubeconfigs are read from key of the Secrets and cached in cacheDir
+// for ttl.
+func NewSecretsProvider(name, mgmtContext, namespace, labelSelector, key string, ttl time.Duration, cacheDir string, sources *Loader, aliases *Aliases) *SecretsProvider {
+	if labelSelector == "" {
+		labelSelector = clusterNameLabel
+	}
+	if key == "" {
+		key = DefaultSecretKey
+	}
+	p := &SecretsProvider{
+		name:          name,
+		context:       mgmtContext,
+		namespace:     namespace,
+		labelSelector: labelSelector,
+		key:           key,
+		ttl:           ttl,
+		cacheDir:      cacheDir,
+		sources:       sources,
+		aliases:       aliases,
+	}
+	p.client = p.newClient
+	return p
+}
+
+// Name returns the name of the provider.
+func (p *SecretsProvider) Name() string {
+	return p.name
+}
+
+func (p *SecretsProvider) Load() ([]WithPath, error) {
+	return p.cache().load(p.fetch)
+}
+
+// Files returns the cached kubeconfig files, listing the Secrets first if the cache has
+// expired. If the management cluster cannot be reached, the expired kubeconfigs are used if
+// there are any.
+func (p *SecretsProvider) Files() ([]string, error) {
+	return p.cache().files(p.fetch)
+}
+
+func (p *SecretsProvider) cache() providerCache {
+	return providerCache{
+		name: p.name,
+		dir:  p.cacheDir,
+		key:  strings.Join([]string{p.context, p.namespace, p.labelSelector, p.key}, "\n"),
+		ttl:  p.ttl,
+	}
+}
+
+// fetch lists the Secrets and returns their kubeconfigs, with the contexts renamed. Secrets
+// without a valid kubeconfig are skipped.
+func (p *SecretsProvider) fetch() ([][]byte, error) {
+	client, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), secretsTimeout)
+	defer cancel()
+	secrets, err := client.CoreV1().Secrets(p.namespace).List(ctx, metav1.ListOptions{LabelSelector: p.labelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Secrets of kubeconfig provider %q in context %q: %w", p.name, p.context, err)
+	}
+
+	items := secrets.Items
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Namespace != items[j].Namespace {
+			return items[i].Namespace < items[j].Namespace
+		}
+		return items[i].Name < items[j].Name
+	})
+
+	var documents [][]byte
+	for _, secret := range items {
+		data, ok := secret.Data[p.key]
+		if !ok {
+			// Cluster API labels the CA and other Secrets of a cluster as well.
+			slog.Debug("skipping Secret without kubeconfig", "provider", p.name, "namespace", secret.Namespace, "secret", secret.Name)
+			continue
+		}
+		kubeconfig, err := clientcmd.Load(data)
+		if err == nil {
+			renameContexts(kubeconfig, p.contextName(secret.Namespace, secretClusterName(secret.Name, secret.Labels)))
+			data, err = clientcmd.Write(*kubeconfig)
+		}
+		if err != nil {
+			slog.Warn("skipping Secret with invalid kubeconfig", "provider", p.name, "namespace", secret.Namespace, "secret", secret.Name, "error", err)
+			continue
+		}
+		documents = append(documents, data)
+	}
+	return documents, nil
+}
+
+// newClient returns a client of the management context. Only the kubeconfig of the management
+// context is loaded, the others are read through the index.
+func (p *SecretsProvider) newClient() (kubernetes.Interface, error) {
+	contexts, err := p.sources.LoadContexts()
+	if err != nil {
+		return nil, err
+	}
+	ctx, err := p.aliases.Find(contexts, p.context)
+	if err != nil {
+		return nil, fmt.Errorf("management context %q of kubeconfig provider %q not found: %w", p.context, p.name, err)
+	}
+
+	kubeconfig := ctx.Config
+	if kubeconfig == nil {
+		loaded, err := LoadFile(ctx.FilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load management context %q of kubeconfig provider %q: %w", p.context, p.name, err)
+		}
+		kubeconfig = loaded.Config
+	}
+	clientConfig := clientcmd.NewNonInteractiveClientConfig(*kubeconfig, ctx.KubeconfigName(), &clientcmd.ConfigOverrides{}, nil)
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid management context %q of kubeconfig provider %q: %w", p.context, p.name, err)
+	}
+	return kubernetes.NewForConfig(restConfig)
+}
+
+// contextName returns the name of the context of a cluster: "<management context>/<cluster>",
+// or "<management context>/<namespace>/<cluster>" when the Secrets of all namespaces are
+// listed, since Cluster API cluster names are only unique within their namespace.
+func (p *SecretsProvider) contextName(namespace, cluster string) string {
+	if p.namespace == "" {
+		return p.context + "/" + namespace + "/" + cluster
+	}
+	return p.context + "/" + cluster
+}
+
+// secretClusterName returns the name of the cluster of a kubeconfig Secret: its Cluster API
+// label, or its name without the "-kubeconfig" suffix.
+func secretClusterName(name string, labels map[string]string) string {
+	if cluster := labels[clusterNameLabel]; cluster != "" {
+		return cluster
+	}
+	return strings.TrimSuffix(name, "-kubeconfig")
+}
+
+// renameContexts renames the context of kubeconfig to name. If it has several contexts, they
+// are named "<name>/<context>".
+func renameContexts(kubeconfig *api.Config, name string) {
+	renamed := make(map[string]*api.Context, len(kubeconfig.Contexts))
+	currentContext := ""
+	for contextName, context := range kubeconfig.Contexts {
+		newName := name
+		if len(kubeconfig.Contexts) > 1 {
+			newName = name + "/" + contextName
+		}
+		renamed[newName] = context
+		if kubeconfig.CurrentContext == contextName {
+			currentContext = newName
+		}
+	}
+	kubeconfig.Contexts, kubeconfig.CurrentContext = renamed, currentContext
+}
@@ -0,0 +1,306 @@
+package kubeconfig
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/idebeijer/kubert/internal/config"
+)
+
+func testSecret(namespace, name string, labels map[string]string, data map[string]string) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels},
+		Data:       make(map[string][]byte),
+	}
+	for key, value := range data {
+		secret.Data[key] = []byte(value)
+	}
+	return secret
+}
+
+// newTestSecretsProvider returns a SecretsProvider whose management cluster has the given
+// objects, and a pointer to the number of times a client was created.
+func newTestSecretsProvider(t *testing.T, ttl time.Duration, objects ...runtime.Object) (*SecretsProvider, *int) {
+	t.Helper()
+	clients := 0
+	provider := NewSecretsProvider("capi", "mgmt", "", "", "", ttl, filepath.Join(t.TempDir(), "capi"), NewLoader(), nil)
+	client := fake.NewClientset(objects...)
+	provider.client = func() (kubernetes.Interface, error) {
+		clients++
+		return client, nil
+	}
+	return provider, &clients
+}
+
+func TestSecretsProvider_Load(t *testing.T) {
+	capiLabels := func(cluster string) map[string]string {
+		return map[string]string{clusterNameLabel: cluster}
+	}
+	provider, _ := newTestSecretsProvider(t, time.Hour,
+		testSecret("tenant-a", "acme-kubeconfig", capiLabels("acme"), map[string]string{"value": testKubeconfig("acme-admin@acme")}),
+		testSecret("tenant-a", "acme-ca", capiLabels("acme"), map[string]string{"tls.crt": "certificate"}),
+		testSecret("tenant-b", "globex-kubeconfig", capiLabels("globex"), map[string]string{"value": testKubeconfig("globex-admin@globex")}),
+		testSecret("tenant-b", "broken-kubeconfig", capiLabels("broken"), map[string]string{"value": "clusters: {}\n"}),
+		testSecret("tenant-b", "unrelated", nil, map[string]string{"value": testKubeconfig("unrelated")}),
+	)
+
+	loader := NewLoader(WithProvider(provider))
+	contexts, err := loader.LoadContexts()
+	if err != nil {
+		t.Fatalf("LoadContexts() error = %v", err)
+	}
+
+	got := contextNames(contexts)
+	if want := []string{"mgmt/tenant-a/acme", "mgmt/tenant-b/globex"}; !slices.Equal(got, want) {
+		t.Fatalf("LoadContexts() = %v, want %v", got, want)
+	}
+	for _, ctx := range contexts {
+		if ctx.Provider != "capi" {
+			t.Errorf("context %q has provider %q, want capi", ctx.Name, ctx.Provider)
+		}
+		kubeconfig, err := LoadFile(ctx.FilePath)
+		if err != nil {
+			t.Fatalf("kubeconfig of %q is not cached: %v", ctx.Name, err)
+		}
+		if _, ok := kubeconfig.Config.Contexts[ctx.Name]; !ok {
+			t.Errorf("cached kubeconfig of %q has contexts %v", ctx.Name, kubeconfig.Config.Contexts)
+		}
+		info, err := os.Stat(ctx.FilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0o600 {
+			t.Errorf("cached kubeconfig has mode %v, want 0600", info.Mode().Perm())
+		}
+	}
+}
+
+func TestSecretsProvider_Load_Namespaces(t *testing.T) {
+	objects := []runtime.Object{
+		testSecret("tenant-a", "prod-kubeconfig", map[string]string{clusterNameLabel: "prod"}, map[string]string{"value": testKubeconfig("prod-admin@prod")}),
+		testSecret("tenant-b", "prod-kubeconfig", map[string]string{clusterNameLabel: "prod"}, map[string]string{"value": testKubeconfig("prod-admin@prod")}),
+	}
+
+	tests := []struct {
+		name      string
+		namespace string
+		want      []string
+	}{
+		{name: "all namespaces", want: []string{"mgmt/tenant-a/prod", "mgmt/tenant-b/prod"}},
+		{name: "one namespace", namespace: "tenant-b", want: []string{"mgmt/prod"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, _ := newTestSecretsProvider(t, time.Hour, objects...)
+			provider.namespace = tt.namespace
+			contexts, err := NewLoader(WithProvider(provider)).LoadContexts()
+			if err != nil {
+				t.Fatalf("LoadContexts() error = %v", err)
+			}
+			if got := contextNames(contexts); !slices.Equal(got, tt.want) {
+				t.Errorf("LoadContexts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretsProvider_Load_Cache(t *testing.T) {
+	secret := testSecret("default", "acme-kubeconfig", map[string]string{clusterNameLabel: "acme"}, map[string]string{"value": testKubeconfig("acme")})
+
+	tests := []struct {
+		name        string
+		ttl         time.Duration
+		change      func(p *SecretsProvider)
+		wantClients int
+	}{
+		{
+			name:        "within ttl",
+			ttl:         time.Hour,
+			wantClients: 1,
+		},
+		{
+			name:        "expired",
+			ttl:         time.Nanosecond,
+			wantClients: 2,
+		},
+		{
+			name:        "namespace changed",
+			ttl:         time.Hour,
+			change:      func(p *SecretsProvider) { p.namespace = "default" },
+			wantClients: 2,
+		},
+		{
+			name: "management cluster unreachable with expired cache",
+			ttl:  time.Nanosecond,
+			change: func(p *SecretsProvider) {
+				p.client = func() (kubernetes.Interface, error) { return nil, errors.New("connection refused") }
+			},
+			wantClients: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, clients := newTestSecretsProvider(t, tt.ttl, secret)
+			if _, err := provider.Load(); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if tt.change != nil {
+				tt.change(provider)
+			}
+			kubeconfigs, err := provider.Load()
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			if len(kubeconfigs) != 1 {
+				t.Errorf("Load() returned %d kubeconfigs, want 1", len(kubeconfigs))
+			}
+			if *clients != tt.wantClients {
+				t.Errorf("Secrets were listed %d times, want %d", *clients, tt.wantClients)
+			}
+		})
+	}
+}
+
+func TestSecretsProvider_NewClient(t *testing.T) {
+	dir, otherDir := t.TempDir(), t.TempDir()
+	writeTestKubeconfigs(t, dir, "dev", "mgmt")
+	other := strings.ReplaceAll(testKubeconfig("mgmt"), "https://mgmt.example.com", "https://other.example.com")
+	if err := os.WriteFile(filepath.Join(otherDir, "other.yaml"), []byte(other), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	local := NewFileSystemProvider([]string{dir}, nil)
+	inventory, _ := newTestExecProvider(t, 0, testKubeconfig("mgmt"), nil)
+	aliases, err := NewAliases(config.Aliases{Contexts: []config.ContextAlias{{Name: "mgmt", Alias: "management"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		context  string
+		sources  []LoaderOption
+		aliases  *Aliases
+		wantHost string
+		wantErr  string
+	}{
+		{
+			name:     "by name",
+			context:  "mgmt",
+			sources:  []LoaderOption{WithProvider(local)},
+			wantHost: "mgmt.example.com",
+		},
+		{
+			name:     "by alias",
+			context:  "management",
+			sources:  []LoaderOption{WithProvider(local)},
+			aliases:  aliases,
+			wantHost: "mgmt.example.com",
+		},
+		{
+			name:     "by qualified duplicate name",
+			context:  "other.yaml/mgmt",
+			sources:  []LoaderOption{WithProvider(local), WithProvider(NewFileSystemProvider([]string{otherDir}, nil)), WithDuplicateStrategy(DuplicatePrefixFilename)},
+			wantHost: "other.example.com",
+		},
+		{
+			name:     "from a provider that is not cached",
+			context:  "mgmt",
+			sources:  []LoaderOption{WithProvider(uncachedProvider{inventory})},
+			wantHost: "mgmt.example.com",
+		},
+		{
+			name:    "not found",
+			context: "prod",
+			sources: []LoaderOption{WithProvider(local)},
+			wantErr: `management context "prod" of kubeconfig provider "capi" not found`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sources := NewLoader(append(tt.sources, WithIndex(filepath.Join(t.TempDir(), "index.json")))...)
+			provider := NewSecretsProvider("capi", tt.context, "", "", "", time.Hour, t.TempDir(), sources, tt.aliases)
+			client, err := provider.newClient()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("newClient() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newClient() error = %v", err)
+			}
+			if host := client.CoreV1().RESTClient().Get().URL().Host; host != tt.wantHost {
+				t.Errorf("newClient() host = %q, want %q", host, tt.wantHost)
+			}
+		})
+	}
+}
+
+func TestRenameContexts(t *testing.T) {
+	tests := []struct {
+		name        string
+		contexts    []string
+		current     string
+		want        []string
+		wantCurrent string
+	}{
+		{
+			name:        "one context",
+			contexts:    []string{"acme-admin@acme"},
+			current:     "acme-admin@acme",
+			want:        []string{"mgmt/acme"},
+			wantCurrent: "mgmt/acme",
+		},
+		{
+			name:        "several contexts",
+			contexts:    []string{"admin", "viewer"},
+			current:     "viewer",
+			want:        []string{"mgmt/acme/admin", "mgmt/acme/viewer"},
+			wantCurrent: "mgmt/acme/viewer",
+		},
+		{
+			name:     "no current context",
+			contexts: []string{"admin"},
+			want:     []string{"mgmt/acme"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kubeconfig := &api.Config{Contexts: make(map[string]*api.Context), CurrentContext: tt.current}
+			for _, name := range tt.contexts {
+				kubeconfig.Contexts[name] = &api.Context{Cluster: name}
+			}
+
+			renameContexts(kubeconfig, "mgmt/acme")
+
+			var got []string
+			for name := range kubeconfig.Contexts {
+				got = append(got, name)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("renameContexts() contexts = %v, want %v", got, tt.want)
+			}
+			if kubeconfig.CurrentContext != tt.wantCurrent {
+				t.Errorf("renameContexts() current context = %q, want %q", kubeconfig.CurrentContext, tt.wantCurrent)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package kubectl
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Command is a kubectl invocation broken down into the parts that matter for protection.
+type Command struct {
+	// Args is the original argument list, without the kubectl binary itself.
+	Args []string
+
+	// Verb is the kubectl (sub)command, e.g. "delete" or "rollout". For plugin invocations
+	// it is the first word of the plugin name.
+	Verb string
+
+	// SubVerb is the second command level for verbs that have one, e.g. "restart" for "rollout restart".
+	SubVerb string
+
+	// Plugin is true when the verb is not a built-in kubectl command, meaning kubectl
+	// will try to dispatch to a kubectl-<verb> plugin binary.
+	Plugin bool
+
+	// Resources holds the resource types referenced, e.g. "pod" for "delete pod x" or "deploy/y".
+	Resources []string
+
+	// Names holds the resource names referenced, e.g. "x" for "delete pod x" or "y" for "deploy/y".
+	Names []string
+
+	// Positionals are all non-flag arguments after the verb and subverb.
+	Positionals []string
+
+	// Flags maps flag names (without leading dashes) to the values they were given.
+	// Boolean flags given without a value are recorded as "true". Well-known shorthands
+	// are recorded under their long name, e.g. "-n" as "namespace".
+	Flags map[string][]string
+
+	// Passthrough holds everything after a "--" separator that follows the verb,
+	// e.g. the command of "kubectl exec pod -- rm -rf /".
+	Passthrough []string
+}
+
+// verbs are the built-in kubectl commands. Anything else is dispatched to a plugin.
+var verbs = []string{
+	"alpha", "annotate", "api-resources", "api-versions", "apply", "attach", "auth", "autoscale",
+	"certificate", "cluster-info", "completion", "config", "cordon", "cp", "create", "debug",
+	"delete", "describe", "diff", "drain", "edit", "events", "exec", "explain", "expose", "get",
+	"help", "kustomize", "label", "logs", "options", "patch", "plugin", "port-forward", "proxy",
+	"replace", "rollout", "run", "scale", "set", "taint", "top", "uncordon", "version", "wait",
+}
+
+// subVerbs are the built-in kubectl commands that take a subcommand.
+var subVerbs = []string{
+	"alpha", "apply", "auth", "certificate", "config", "create", "plugin", "rollout", "set", "top",
+}
+
+// implicitResources are the resource types of verbs whose positional arguments are plain
+// names, e.g. "kubectl exec my-pod" or "kubectl drain my-node".
+var implicitResources = map[string]string{
+	"attach":       "pod",
+	"cordon":       "node",
+	"debug":        "pod",
+	"drain":        "node",
+	"exec":         "pod",
+	"logs":         "pod",
+	"port-forward": "pod",
+	"uncordon":     "node",
+}
+
+// longFlags maps unambiguous shorthand flags to their long name, so "-A" and
+// "--all-namespaces" are recorded under the same name.
+var longFlags = map[string]string{
+	"A": "all-namespaces",
+	"R": "recursive",
+	"f": "filename",
+	"k": "kustomize",
+	"l": "selector",
+	"n": "namespace",
+	"o": "output",
+}
+
+// boolFlags are the kubectl global flags that do not take a value. Before the verb every
+// other flag given without "=" consumes the next argument, mirroring how cobra finds the
+// subcommand to run.
+var boolFlags = []string{
+	"disable-compression", "help", "h", "insecure-skip-tls-verify", "match-server-version",
+	"warnings-as-errors", "add-dir-header", "alsologtostderr", "logtostderr", "one-output",
+	"skip-headers", "skip-log-headers",
+}
+
+// globalFlags are the kubectl global flags that take a value.
+var globalFlags = []string{
+	"as", "as-group", "as-uid", "cache-dir", "certificate-authority", "client-certificate",
+	"client-key", "cluster", "context", "kubeconfig", "log-backtrace-at", "log-dir", "log-file",
+	"log-file-max-size", "log-flush-frequency", "n", "namespace", "password", "profile",
+	"profile-output", "request-timeout", "s", "server", "stderrthreshold", "tls-server-name",
+	"token", "user", "username", "v", "vmodule",
+}
+
+// valueFlags are flags that take a value when they appear after the verb. Unknown flags
+// without "=" are assumed to be boolean.
+var valueFlags = slices.Concat(globalFlags, []string{
+	// common command flags
+	"c", "cascade", "chunk-size", "cluster-ip", "container", "cpu-percent", "e", "env",
+	"external-ip", "f", "field-manager", "field-selector", "filename", "for", "from",
+	"from-env-file", "from-file", "from-literal", "grace-period", "image", "k", "kustomize", "l",
+	"L", "label-columns", "labels", "limit-bytes", "limits", "max", "max-log-requests", "min",
+	"name", "o", "output", "overrides", "p", "patch", "patch-file", "pod-running-timeout", "port",
+	"protocol", "prune-allowlist", "raw", "replicas", "requests", "resource-version", "restart",
+	"revision", "schedule", "selector", "serviceaccount", "since", "since-time",
+	"skip-wait-for-delete-timeout", "sort-by", "subresource", "tail", "target", "target-port",
+	"template", "timeout", "to-revision", "type",
+	// flags of the verbs in implicitResources
+	"address", "copy-to", "custom", "image-pull-policy", "pod-selector", "set-image",
+})
+
+// verbBoolFlags are the flags in valueFlags that are boolean for some verbs, e.g. "-p" is
+// "--previous" for "kubectl logs" but "--patch" for "kubectl patch".
+var verbBoolFlags = map[string][]string{
+	"logs": {"p"},
+}
+
+// MatchesVerb reports whether the command runs the given verb, either on its own ("rollout")
+// or together with its subverb ("rollout restart").
+func (c Command) MatchesVerb(verb string) bool {
+	if c.Verb == "" {
+		return false
+	}
+	return verb == c.Verb || (c.SubVerb != "" && verb == c.Verb+" "+c.SubVerb)
+}
+
+// Parse breaks a kubectl argument list (without the kubectl binary) down into a Command.
+// Global flags before the verb are skipped the same way kubectl does, so
+// "kubectl -n prod delete pod x" yields the verb "delete".
+func Parse(args []string) Command {
+	c := Command{
+		Args:  args,
+		Flags: make(map[string][]string),
+	}
+
+	i := 0
+	for i < len(args) && c.Verb == "" {
+		arg := args[i]
+		i++
+		switch {
+		case arg == "--":
+			// kubectl stops looking for flags here; whatever follows is still the command.
+			continue
+		case isFlag(arg):
+			i = c.parseFlag(args, i, arg, func(name string) bool { return !slices.Contains(boolFlags, name) })
+		default:
+			c.Verb = arg
+			c.Plugin = !slices.Contains(verbs, arg)
+		}
+	}
+
+	for i < len(args) {
+		arg := args[i]
+		i++
+		switch {
+		case arg == "--":
+			c.Passthrough = args[i:]
+			i = len(args)
+		case isFlag(arg):
+			i = c.parseFlag(args, i, arg, c.takesValue)
+		case c.SubVerb == "" && len(c.Positionals) == 0 && slices.Contains(subVerbs, c.Verb):
+			c.SubVerb = arg
+		default:
+			c.Positionals = append(c.Positionals, arg)
+		}
+	}
+
+	c.Resources, c.Names = resourcesAndNames(c.Positionals, implicitResources[c.Verb])
+	return c
+}
+
+// Flag returns the value of the first of the named flags that was given. When a flag was
+// repeated the last value wins.
+func (c Command) Flag(names ...string) (string, bool) {
+	for _, name := range names {
+		if values := c.Flags[LongFlag(name)]; len(values) > 0 {
+			return values[len(values)-1], true
+		}
+	}
+	return "", false
+}
+
+// BoolFlag reports whether any of the named boolean flags is set to true.
+func (c Command) BoolFlag(names ...string) bool {
+	value, ok := c.Flag(names...)
+	return ok && IsTrue(value)
+}
+
+// IsTrue reports whether the value of a boolean flag enables it, parsed like kubectl parses
+// it, so "--force=0" and "--force=False" are off. Values kubectl rejects count as true, to err
+// on the side of protection.
+func IsTrue(value string) bool {
+	b, err := strconv.ParseBool(value)
+	return err != nil || b
+}
+
+// takesValue reports whether a flag after the verb takes a value.
+func (c *Command) takesValue(name string) bool {
+	return slices.Contains(valueFlags, name) && !slices.Contains(verbBoolFlags[c.Verb], name)
+}
+
+func isFlag(arg string) bool {
+	return len(arg) > 1 && strings.HasPrefix(arg, "-")
+}
+
+// parseFlag records the flag at args[i-1] and returns the index of the next argument to
+// look at. takesValue decides whether a flag given without "=" consumes the next argument.
+func (c *Command) parseFlag(args []string, i int, arg string, takesValue func(string) bool) int {
+	if strings.HasPrefix(arg, "--") {
+		name, value, hasValue := strings.Cut(arg[2:], "=")
+		switch {
+		case hasValue:
+			c.addFlag(name, value)
+		case takesValue(name) && i < len(args):
+			c.addFlag(name, args[i])
+			return i + 1
+		default:
+			c.addFlag(name, "true")
+		}
+		return i
+	}
+
+	// Shorthand flags can be grouped ("-it") and can carry their value inline ("-nprod", "-n=prod").
+	shorthands := arg[1:]
+	for j := 0; j < len(shorthands); j++ {
+		name := shorthands[j : j+1]
+		rest := shorthands[j+1:]
+		switch {
+		case strings.HasPrefix(rest, "="):
+			c.addFlag(name, rest[1:])
+			return i
+		case !takesValue(name):
+			c.addFlag(name, "true")
+		case rest != "":
+			c.addFlag(name, rest)
+			return i
+		case i < len(args):
+			c.addFlag(name, args[i])
+			return i + 1
+		default:
+			c.addFlag(name, "")
+			return i
+		}
+	}
+	return i
+}
+
+func (c *Command) addFlag(name, value string) {
+	name = LongFlag(name)
+	c.Flags[name] = append(c.Flags[name], value)
+}
+
+// LongFlag returns the long name of a well-known shorthand flag, or name itself.
+func LongFlag(name string) string {
+	if long, ok := longFlags[name]; ok {
+		return long
+	}
+	return name
+}
+
+// resourcesAndNames splits positional arguments into resource types and names, supporting
+// both "TYPE NAME..." and "TYPE/NAME..." forms as well as comma separated types. When
+// implicit is set, a bare first argument is a name of that type instead of a type.
+func resourcesAndNames(positionals []string, implicit string) ([]string, []string) {
+	var resources, names []string
+	for i, arg := range positionals {
+		if resource, name, ok := strings.Cut(arg, "/"); ok {
+			resources = appendUnique(resources, resource)
+			names = append(names, name)
+			continue
+		}
+		if i == 0 && implicit != "" {
+			resources = appendUnique(resources, implicit)
+			names = append(names, arg)
+			continue
+		}
+		if i == 0 {
+			for resource := range strings.SplitSeq(arg, ",") {
+				if resource != "" {
+					resources = appendUnique(resources, resource)
+				}
+			}
+			continue
+		}
+		names = append(names, arg)
+	}
+	return resources, names
+}
+
+func appendUnique(list []string, value string) []string {
+	if slices.Contains(list, value) {
+		return list
+	}
+	return append(list, value)
+}
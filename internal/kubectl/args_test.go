@@ -0,0 +1,240 @@
+package kubectl
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		verb        string
+		subVerb     string
+		plugin      bool
+		resources   []string
+		names       []string
+		passthrough []string
+	}{
+		{
+			name:      "simple command",
+			args:      []string{"delete", "pod", "x"},
+			verb:      "delete",
+			resources: []string{"pod"},
+			names:     []string{"x"},
+		},
+		{
+			name:      "namespace shorthand before verb",
+			args:      []string{"-n", "prod", "delete", "pod", "x"},
+			verb:      "delete",
+			resources: []string{"pod"},
+			names:     []string{"x"},
+		},
+		{
+			name: "context flag before verb",
+			args: []string{"--context", "foo", "apply", "-f", "."},
+			verb: "apply",
+		},
+		{
+			name: "flags with inline values before verb",
+			args: []string{"--context=foo", "-nprod", "-v=6", "apply", "-f", "."},
+			verb: "apply",
+		},
+		{
+			name:      "boolean global flag before verb",
+			args:      []string{"--insecure-skip-tls-verify", "delete", "ns", "prod"},
+			verb:      "delete",
+			resources: []string{"ns"},
+			names:     []string{"prod"},
+		},
+		{
+			name:      "separator before verb",
+			args:      []string{"--", "delete", "pod", "x"},
+			verb:      "delete",
+			resources: []string{"pod"},
+			names:     []string{"x"},
+		},
+		{
+			name:      "type/name form",
+			args:      []string{"delete", "deploy/a", "svc/b", "--wait=false"},
+			verb:      "delete",
+			resources: []string{"deploy", "svc"},
+			names:     []string{"a", "b"},
+		},
+		{
+			name:      "comma separated types",
+			args:      []string{"get", "pods,svc", "-l", "app=web"},
+			verb:      "get",
+			resources: []string{"pods", "svc"},
+		},
+		{
+			name:      "subverb",
+			args:      []string{"rollout", "restart", "deployment/web", "-n", "prod"},
+			verb:      "rollout",
+			subVerb:   "restart",
+			resources: []string{"deployment"},
+			names:     []string{"web"},
+		},
+		{
+			name:        "exec passthrough",
+			args:        []string{"exec", "-it", "web-0", "-c", "app", "--", "rm", "-rf", "/"},
+			verb:        "exec",
+			resources:   []string{"pod"},
+			names:       []string{"web-0"},
+			passthrough: []string{"rm", "-rf", "/"},
+		},
+		{
+			name:   "plugin",
+			args:   []string{"cnpg", "destroy", "cluster-1"},
+			verb:   "cnpg",
+			plugin: true,
+		},
+		{
+			name: "no verb",
+			args: []string{"--context", "foo"},
+		},
+		{
+			name: "empty",
+			args: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Parse(tt.args)
+			if c.Verb != tt.verb {
+				t.Errorf("Verb = %q, want %q", c.Verb, tt.verb)
+			}
+			if c.SubVerb != tt.subVerb {
+				t.Errorf("SubVerb = %q, want %q", c.SubVerb, tt.subVerb)
+			}
+			if c.Plugin != tt.plugin {
+				t.Errorf("Plugin = %v, want %v", c.Plugin, tt.plugin)
+			}
+			if !tt.plugin && !slices.Equal(c.Resources, tt.resources) {
+				t.Errorf("Resources = %v, want %v", c.Resources, tt.resources)
+			}
+			if !tt.plugin && !slices.Equal(c.Names, tt.names) {
+				t.Errorf("Names = %v, want %v", c.Names, tt.names)
+			}
+			if !slices.Equal(c.Passthrough, tt.passthrough) {
+				t.Errorf("Passthrough = %v, want %v", c.Passthrough, tt.passthrough)
+			}
+		})
+	}
+}
+
+func TestParse_Flags(t *testing.T) {
+	c := Parse([]string{"--context", "foo", "-nprod", "delete", "pods", "--all", "--force", "--grace-period=0", "-A", "-it"})
+
+	tests := []struct {
+		names []string
+		want  string
+		found bool
+	}{
+		{names: []string{"context"}, want: "foo", found: true},
+		{names: []string{"namespace", "n"}, want: "prod", found: true},
+		{names: []string{"all"}, want: "true", found: true},
+		{names: []string{"force"}, want: "true", found: true},
+		{names: []string{"grace-period"}, want: "0", found: true},
+		{names: []string{"all-namespaces", "A"}, want: "true", found: true},
+		{names: []string{"i"}, want: "true", found: true},
+		{names: []string{"t"}, want: "true", found: true},
+		{names: []string{"kubeconfig"}, want: "", found: false},
+	}
+
+	for _, tt := range tests {
+		got, found := c.Flag(tt.names...)
+		if got != tt.want || found != tt.found {
+			t.Errorf("Flag(%v) = (%q, %v), want (%q, %v)", tt.names, got, found, tt.want, tt.found)
+		}
+	}
+}
+
+func TestParse_ValueFlags(t *testing.T) {
+	tests := []struct {
+		args      []string
+		flag      string
+		value     string
+		resources []string
+		names     []string
+	}{
+		{args: []string{"get", "pods", "--chunk-size", "500"}, flag: "chunk-size", value: "500", resources: []string{"pods"}},
+		{args: []string{"logs", "--max-log-requests", "10", "web-0"}, flag: "max-log-requests", value: "10", resources: []string{"pod"}, names: []string{"web-0"}},
+		{args: []string{"get", "pods", "--sort-by", ".metadata.name", "web"}, flag: "sort-by", value: ".metadata.name", resources: []string{"pods"}, names: []string{"web"}},
+		{args: []string{"delete", "pod", "--force", "web"}, flag: "force", value: "true", resources: []string{"pod"}, names: []string{"web"}},
+		{args: []string{"logs", "-p", "web-0"}, flag: "p", value: "true", resources: []string{"pod"}, names: []string{"web-0"}},
+		{args: []string{"patch", "deploy", "web", "-p", `{"spec":{}}`}, flag: "p", value: `{"spec":{}}`, resources: []string{"deploy"}, names: []string{"web"}},
+		{args: []string{"drain", "--pod-selector", "app=x", "node1"}, flag: "pod-selector", value: "app=x", resources: []string{"node"}, names: []string{"node1"}},
+		{args: []string{"drain", "node1", "--timeout", "5m", "--selector", "pool=a"}, flag: "timeout", value: "5m", resources: []string{"node"}, names: []string{"node1"}},
+		{args: []string{"port-forward", "--address", "0.0.0.0", "web-0", "8080"}, flag: "address", value: "0.0.0.0", resources: []string{"pod"}, names: []string{"web-0", "8080"}},
+		{args: []string{"debug", "--copy-to", "web-debug", "web-0"}, flag: "copy-to", value: "web-debug", resources: []string{"pod"}, names: []string{"web-0"}},
+	}
+
+	for _, tt := range tests {
+		c := Parse(tt.args)
+		if got, _ := c.Flag(tt.flag); got != tt.value {
+			t.Errorf("Parse(%v).Flag(%s) = %q, want %q", tt.args, tt.flag, got, tt.value)
+		}
+		if !slices.Equal(c.Resources, tt.resources) || !slices.Equal(c.Names, tt.names) {
+			t.Errorf("Parse(%v) = resources %v, names %v; want %v, %v", tt.args, c.Resources, c.Names, tt.resources, tt.names)
+		}
+	}
+}
+
+func TestCommand_BoolFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{args: []string{"delete", "pods", "--all"}, want: true},
+		{args: []string{"delete", "pods", "--all=true"}, want: true},
+		{args: []string{"delete", "pods", "--all=false"}, want: false},
+		{args: []string{"delete", "pods", "--all=0"}, want: false},
+		{args: []string{"delete", "pods", "--all=False"}, want: false},
+		{args: []string{"delete", "pods", "--all=1"}, want: true},
+		{args: []string{"delete", "pods", "--all=maybe"}, want: true},
+		{args: []string{"delete", "pods", "x"}, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := Parse(tt.args).BoolFlag("all"); got != tt.want {
+			t.Errorf("BoolFlag(all) for %v = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestCommand_MatchesVerb(t *testing.T) {
+	c := Parse([]string{"rollout", "restart", "deploy/web"})
+
+	for verb, want := range map[string]bool{
+		"rollout":         true,
+		"rollout restart": true,
+		"rollout status":  false,
+		"restart":         false,
+		"":                false,
+	} {
+		if got := c.MatchesVerb(verb); got != want {
+			t.Errorf("MatchesVerb(%q) = %v, want %v", verb, got, want)
+		}
+	}
+}
+
+func TestCanonicalResource(t *testing.T) {
+	tests := map[string]string{
+		"pod":              "pod",
+		"pods":             "pod",
+		"po":               "pod",
+		"ns":               "namespace",
+		"Namespaces":       "namespace",
+		"deployments.apps": "deployment",
+		"deploy":           "deployment",
+		"widgets.acme.io":  "widgets",
+	}
+
+	for input, want := range tests {
+		if got := CanonicalResource(input); got != want {
+			t.Errorf("CanonicalResource(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
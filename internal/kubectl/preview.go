@@ -0,0 +1,72 @@
+package kubectl
+
+import (
+	"slices"
+	"sort"
+)
+
+// diffFlags are the flags of "kubectl apply" that "kubectl diff" understands as well.
+var diffFlags = slices.Concat(globalFlags, boolFlags, []string{
+	"concurrency", "field-manager", "filename", "force-conflicts", "kustomize", "prune",
+	"prune-allowlist", "recursive", "selector", "server-side",
+})
+
+// Preview returns the arguments of a kubectl invocation that shows what the command would
+// change without changing anything: "kubectl diff" for apply, and a server-side dry run for
+// patch, replace and set. It returns false for commands that cannot be previewed, such as
+// commands that already are a dry run or read their input from stdin.
+func Preview(c Command) ([]string, bool) {
+	if dryRun, ok := c.Flag("dry-run"); ok && dryRun != "none" {
+		return nil, false
+	}
+	if c.BoolFlag("help") || slices.Contains(c.Flags["filename"], "-") {
+		return nil, false
+	}
+
+	switch {
+	case c.Verb == "apply" && c.SubVerb == "":
+		return diffArgs(c), true
+	case c.Verb == "patch", c.Verb == "replace", c.Verb == "set" && c.SubVerb != "":
+		return dryRunArgs(c), true
+	}
+	return nil, false
+}
+
+// diffArgs turns an apply into a diff, keeping only the flags diff accepts.
+func diffArgs(c Command) []string {
+	names := make([]string, 0, len(c.Flags))
+	for name := range c.Flags {
+		if slices.Contains(diffFlags, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	args := []string{"diff"}
+	for _, name := range names {
+		prefix := "--"
+		if len(name) == 1 {
+			prefix = "-"
+		}
+		for _, value := range c.Flags[name] {
+			args = append(args, prefix+name+"="+value)
+		}
+	}
+	return args
+}
+
+// dryRunArgs adds a server-side dry run to the command, printing the resulting objects unless
+// an output format was given.
+func dryRunArgs(c Command) []string {
+	extra := []string{"--dry-run=server"}
+	if _, ok := c.Flag("output"); !ok {
+		extra = append(extra, "--output=yaml")
+	}
+
+	// Flags have to go before a "--" separator.
+	end := len(c.Args)
+	if len(c.Passthrough) > 0 || (end > 0 && c.Args[end-1] == "--") {
+		end = len(c.Args) - len(c.Passthrough) - 1
+	}
+	return slices.Concat(c.Args[:end], extra, c.Args[end:])
+}
@@ -0,0 +1,72 @@
+package kubectl
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPreview(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "apply becomes diff",
+			args: []string{"--context", "prod", "apply", "-f", "deploy.yaml", "-n", "web", "--server-side"},
+			want: []string{"diff", "--context=prod", "--filename=deploy.yaml", "--namespace=web", "--server-side=true"},
+		},
+		{
+			name: "apply flags diff does not know are dropped",
+			args: []string{"apply", "-f", "a.yaml", "-f", "b.yaml", "--wait", "--timeout=1m", "-v=6"},
+			want: []string{"diff", "--filename=a.yaml", "--filename=b.yaml", "-v=6"},
+		},
+		{
+			name: "patch gets a server-side dry run",
+			args: []string{"patch", "deploy", "web", "-p", `{"spec":{"replicas":2}}`},
+			want: []string{"patch", "deploy", "web", "-p", `{"spec":{"replicas":2}}`, "--dry-run=server", "--output=yaml"},
+		},
+		{
+			name: "output format is kept",
+			args: []string{"set", "image", "deploy/web", "web=nginx:1.27", "-o", "name"},
+			want: []string{"set", "image", "deploy/web", "web=nginx:1.27", "-o", "name", "--dry-run=server"},
+		},
+		{
+			name: "flags go before the separator",
+			args: []string{"replace", "-f", "pod.yaml", "--"},
+			want: []string{"replace", "-f", "pod.yaml", "--dry-run=server", "--output=yaml", "--"},
+		},
+		{
+			name: "already a dry run",
+			args: []string{"apply", "-f", "deploy.yaml", "--dry-run=server"},
+		},
+		{
+			name: "reads from stdin",
+			args: []string{"apply", "-f", "-"},
+		},
+		{
+			name: "apply subcommand",
+			args: []string{"apply", "view-last-applied", "deploy/web"},
+		},
+		{
+			name: "set without subcommand",
+			args: []string{"set", "--help"},
+		},
+		{
+			name: "other verb",
+			args: []string{"delete", "pod", "x"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Preview(Parse(tt.args))
+			if ok != (tt.want != nil) {
+				t.Fatalf("Preview() ok = %v, want %v", ok, tt.want != nil)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Preview() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
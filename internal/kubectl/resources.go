@@ -0,0 +1,59 @@
+package kubectl
+
+import "strings"
+
+// resourceAliases maps the plural and short names kubectl accepts for common resource types
+// to their singular name.
+var resourceAliases = map[string]string{
+	"clusterrolebindings":       "clusterrolebinding",
+	"clusterroles":              "clusterrole",
+	"cm":                        "configmap",
+	"configmaps":                "configmap",
+	"cj":                        "cronjob",
+	"cronjobs":                  "cronjob",
+	"crd":                       "customresourcedefinition",
+	"crds":                      "customresourcedefinition",
+	"customresourcedefinitions": "customresourcedefinition",
+	"daemonsets":                "daemonset",
+	"deploy":                    "deployment",
+	"deployments":               "deployment",
+	"ds":                        "daemonset",
+	"hpa":                       "horizontalpodautoscaler",
+	"horizontalpodautoscalers":  "horizontalpodautoscaler",
+	"ing":                       "ingress",
+	"ingresses":                 "ingress",
+	"jobs":                      "job",
+	"namespaces":                "namespace",
+	"no":                        "node",
+	"nodes":                     "node",
+	"ns":                        "namespace",
+	"persistentvolumeclaims":    "persistentvolumeclaim",
+	"persistentvolumes":         "persistentvolume",
+	"po":                        "pod",
+	"pods":                      "pod",
+	"pv":                        "persistentvolume",
+	"pvc":                       "persistentvolumeclaim",
+	"replicasets":               "replicaset",
+	"rolebindings":              "rolebinding",
+	"roles":                     "role",
+	"rs":                        "replicaset",
+	"sa":                        "serviceaccount",
+	"secrets":                   "secret",
+	"serviceaccounts":           "serviceaccount",
+	"services":                  "service",
+	"statefulsets":              "statefulset",
+	"sts":                       "statefulset",
+	"svc":                       "service",
+}
+
+// CanonicalResource returns the singular, lower case name of a resource type as written
+// on the command line, dropping any API group suffix ("deploy", "deployments" and
+// "deployments.apps" all become "deployment").
+func CanonicalResource(resource string) string {
+	resource = strings.ToLower(resource)
+	resource, _, _ = strings.Cut(resource, ".")
+	if canonical, ok := resourceAliases[resource]; ok {
+		return canonical
+	}
+	return resource
+}
@@ -19,11 +19,19 @@ const (
 	// ShellOriginalKubeconfigEnvVar is the environment variable that is set to the path of the original kubeconfig file.
 	ShellOriginalKubeconfigEnvVar = "KUBERT_SHELL_ORIGINAL_KUBECONFIG"
 
+	// OriginalKubeconfigEnvVar is set to the KUBECONFIG of the user in the shells kubert starts,
+	// where KUBECONFIG points to the kubeconfig of the shell instead.
+	OriginalKubeconfigEnvVar = "KUBERT_ORIGINAL_KUBECONFIG"
+
 	// ShellContextEnvVar is the environment variable that is set to the context name.
 	// Only set when ShellInitEnvVar is present; without the shell function there is no
 	// mechanism to keep it updated after in-place switches.
 	ShellContextEnvVar = "KUBERT_SHELL_CONTEXT"
 
+	// ShellReadonlyEnvVar is set to "1" in shells started with "kubert ctx --readonly", whose
+	// kubeconfig impersonates a read-only identity.
+	ShellReadonlyEnvVar = "KUBERT_SHELL_READONLY"
+
 	// ShellInitEnvVar is exported by the kubert shell function (see "kubert shell-init").
 	// Its presence tells the binary that env-var updates can be delivered via an env-update file.
 	ShellInitEnvVar = "KUBERT_SHELL_INIT"
@@ -0,0 +1,94 @@
+package kubert
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/adrg/xdg"
+)
+
+// ShimDir returns the directory with the shims that kubert puts in front of PATH in kubert shells.
+func ShimDir() string {
+	return filepath.Join(xdg.RuntimeDir, "kubert", "shims")
+}
+
+// InstallShims writes a shim to dir for every name in shims, which runs kubert with the given
+// arguments followed by the arguments of the shim, e.g. "kubectl" -> ["kubectl"] makes
+// "kubectl get pods" run "kubert kubectl get pods". Existing shims are replaced.
+func InstallShims(dir, kubertPath string, shims map[string][]string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create shim directory: %w", err)
+	}
+
+	for name, args := range shims {
+		quoted := make([]string, 0, len(args)+1)
+		for _, arg := range append([]string{kubertPath}, args...) {
+			quoted = append(quoted, shellQuote(arg))
+		}
+		script := fmt.Sprintf("#!/bin/sh\n# Generated by kubert, runs %s through context protection.\nexec %s \"$@\"\n", name, strings.Join(quoted, " "))
+
+		// Write to a temporary file first, so shells using the shim never see a partial script.
+		path := filepath.Join(dir, name)
+		tmp := path + ".tmp"
+		// #nosec G306 -- the shim has to be executable
+		if err := os.WriteFile(tmp, []byte(script), 0o700); err != nil {
+			return fmt.Errorf("failed to write shim %s: %w", name, err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return fmt.Errorf("failed to write shim %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// PrependPath returns the PATH value with dir in front, unless it is already in it.
+func PrependPath(path, dir string) string {
+	for _, entry := range filepath.SplitList(path) {
+		if filepath.Clean(entry) == filepath.Clean(dir) {
+			return path
+		}
+	}
+	if path == "" {
+		return dir
+	}
+	return dir + string(os.PathListSeparator) + path
+}
+
+// PathWithoutShims returns the PATH value without the shim directory.
+func PathWithoutShims(path string) string {
+	shimDir := filepath.Clean(ShimDir())
+	var dirs []string
+	for _, dir := range filepath.SplitList(path) {
+		if dir != "" && filepath.Clean(dir) == shimDir {
+			continue
+		}
+		dirs = append(dirs, dir)
+	}
+	return strings.Join(dirs, string(os.PathListSeparator))
+}
+
+// LookPath searches PATH for an executable like exec.LookPath, but skips the shim directory,
+// so kubert runs the real binary instead of calling its own shim again.
+func LookPath(name string) (string, error) {
+	if strings.Contains(name, string(os.PathSeparator)) {
+		return exec.LookPath(name)
+	}
+
+	for _, dir := range filepath.SplitList(PathWithoutShims(os.Getenv("PATH"))) {
+		if dir == "" {
+			dir = "."
+		}
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() && info.Mode()&0o111 != 0 {
+			return path, nil
+		}
+	}
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
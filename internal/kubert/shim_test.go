@@ -0,0 +1,92 @@
+package kubert
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adrg/xdg"
+)
+
+func TestInstallShims(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "shims")
+	err := InstallShims(dir, "/opt/kubert's/kubert", map[string][]string{
+		"kubectl": {"kubectl"},
+		"helm":    {"wrap", "helm"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "helm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `exec '/opt/kubert'\''s/kubert' 'wrap' 'helm' "$@"`) {
+		t.Errorf("unexpected helm shim:\n%s", data)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "kubectl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&0o100 == 0 {
+		t.Errorf("Expected kubectl shim to be executable, got mode %v", info.Mode())
+	}
+}
+
+func TestPrependPath(t *testing.T) {
+	sep := string(os.PathListSeparator)
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{path: "/usr/bin" + sep + "/bin", expected: "/shims" + sep + "/usr/bin" + sep + "/bin"},
+		{path: "/shims/" + sep + "/usr/bin", expected: "/shims/" + sep + "/usr/bin"},
+		{path: "", expected: "/shims"},
+	}
+
+	for _, tt := range tests {
+		if got := PrependPath(tt.path, "/shims"); got != tt.expected {
+			t.Errorf("PrependPath(%q) = %q, want %q", tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestLookPath_SkipsShims(t *testing.T) {
+	runtimeDir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	xdg.Reload()
+	t.Cleanup(xdg.Reload)
+
+	if err := InstallShims(ShimDir(), "/usr/local/bin/kubert", map[string][]string{"kubectl": {"kubectl"}}); err != nil {
+		t.Fatal(err)
+	}
+	binDir := t.TempDir()
+	real := filepath.Join(binDir, "kubectl")
+	if err := os.WriteFile(real, []byte("#!/bin/sh\n"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", ShimDir()+string(os.PathListSeparator)+binDir)
+
+	if path, _ := exec.LookPath("kubectl"); path != filepath.Join(ShimDir(), "kubectl") {
+		t.Fatalf("exec.LookPath() = %q, expected the shim to come first", path)
+	}
+	path, err := LookPath("kubectl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != real {
+		t.Errorf("LookPath() = %q, want %q", path, real)
+	}
+
+	if got := PathWithoutShims(os.Getenv("PATH")); got != binDir {
+		t.Errorf("PathWithoutShims() = %q, want %q", got, binDir)
+	}
+
+	if _, err := LookPath("helm"); err == nil {
+		t.Error("Expected an error for a binary that is not in PATH")
+	}
+}
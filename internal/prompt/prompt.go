@@ -0,0 +1,102 @@
+package prompt
+
+import (
+	"bufio"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// Mode is how a protected command has to be confirmed.
+type Mode string
+
+const (
+	// ModeYes accepts "y" or "yes".
+	ModeYes Mode = "yes"
+	// ModeContext requires typing the exact context name.
+	ModeContext Mode = "context"
+	// ModeChallenge requires typing a randomly chosen word.
+	ModeChallenge Mode = "challenge"
+)
+
+// ParseMode validates a confirmation mode from the config. An empty string is ModeYes.
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case "", ModeYes:
+		return ModeYes, nil
+	case ModeContext, ModeChallenge:
+		return Mode(s), nil
+	}
+	return "", fmt.Errorf("invalid confirmation mode %q, must be one of yes, context or challenge", s)
+}
+
+// Strictest returns the mode that is hardest to confirm.
+func Strictest(a, b Mode) Mode {
+	rank := map[Mode]int{ModeYes: 0, ModeContext: 1, ModeChallenge: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+var challengeWords = []string{
+	"anchor", "basalt", "cobalt", "delta", "ember", "falcon", "glacier", "harbor",
+	"indigo", "juniper", "kestrel", "lantern", "meadow", "nickel", "orchid", "pepper",
+	"quartz", "raven", "saffron", "timber", "umber", "velvet", "willow", "zephyr",
+}
+
+// Confirm asks for confirmation on the terminal. It reads from /dev/tty rather than stdin so
+// it works when stdin is in use by the command, e.g. "kubectl apply -f -". Without a terminal
+// the command is not confirmed.
+func Confirm(mode Mode, context string) bool {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot ask for confirmation, no terminal available: %v\n", err)
+		return false
+	}
+	defer func() { _ = tty.Close() }()
+
+	word, err := challengeWord()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot ask for confirmation: %v\n", err)
+		return false
+	}
+	return confirm(tty, tty, mode, context, word)
+}
+
+func confirm(in io.Reader, out io.Writer, mode Mode, context, word string) bool {
+	var expected string
+	switch mode {
+	case ModeContext:
+		expected = context
+		fmt.Fprintf(out, "Type the context name %q to continue: ", context)
+	case ModeChallenge:
+		expected = word
+		fmt.Fprintf(out, "Type %q to continue: ", word)
+	default:
+		fmt.Fprint(out, "Are you sure you want to continue? [y/N]: ")
+	}
+
+	response, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && response == "" {
+		fmt.Fprintln(out)
+		return false
+	}
+	response = strings.TrimSpace(response)
+
+	if mode == ModeContext || mode == ModeChallenge {
+		return response == expected
+	}
+	return strings.EqualFold(response, "y") || strings.EqualFold(response, "yes")
+}
+
+func challengeWord() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(challengeWords))))
+	if err != nil {
+		return "", fmt.Errorf("failed to pick challenge word: %w", err)
+	}
+	return challengeWords[n.Int64()], nil
+}
@@ -0,0 +1,91 @@
+package prompt
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     Mode
+		input    string
+		expected bool
+	}{
+		{name: "yes", mode: ModeYes, input: "y\n", expected: true},
+		{name: "yes long form", mode: ModeYes, input: "YES\n", expected: true},
+		{name: "no", mode: ModeYes, input: "n\n", expected: false},
+		{name: "empty", mode: ModeYes, input: "\n", expected: false},
+		{name: "eof", mode: ModeYes, input: "", expected: false},
+		{name: "context name", mode: ModeContext, input: "prod-eu\n", expected: true},
+		{name: "context name without newline", mode: ModeContext, input: "prod-eu", expected: true},
+		{name: "y is not the context name", mode: ModeContext, input: "y\n", expected: false},
+		{name: "wrong context name", mode: ModeContext, input: "prod-us\n", expected: false},
+		{name: "challenge", mode: ModeChallenge, input: "falcon\n", expected: true},
+		{name: "wrong challenge", mode: ModeChallenge, input: "prod-eu\n", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			got := confirm(strings.NewReader(tt.input), &out, tt.mode, "prod-eu", "falcon")
+			if got != tt.expected {
+				t.Errorf("confirm() = %v, want %v (prompt: %q)", got, tt.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestConfirm_PromptMentionsExpectedInput(t *testing.T) {
+	var out bytes.Buffer
+	confirm(strings.NewReader("\n"), &out, ModeContext, "prod-eu", "falcon")
+	if !strings.Contains(out.String(), "prod-eu") {
+		t.Errorf("Expected prompt to name the context, got %q", out.String())
+	}
+
+	out.Reset()
+	confirm(strings.NewReader("\n"), &out, ModeChallenge, "prod-eu", "falcon")
+	if !strings.Contains(out.String(), "falcon") {
+		t.Errorf("Expected prompt to show the challenge word, got %q", out.String())
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for _, s := range []string{"", "yes", "context", "challenge"} {
+		if _, err := ParseMode(s); err != nil {
+			t.Errorf("ParseMode(%q) returned error: %v", s, err)
+		}
+	}
+	if _, err := ParseMode("word"); err == nil {
+		t.Error("ParseMode(word) should fail")
+	}
+}
+
+func TestChallengeWord(t *testing.T) {
+	word, err := challengeWord()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(challengeWords, word) {
+		t.Errorf("challengeWord() = %q, not in word list", word)
+	}
+}
+
+func TestStrictest(t *testing.T) {
+	tests := []struct {
+		a, b, expected Mode
+	}{
+		{ModeYes, ModeYes, ModeYes},
+		{ModeYes, ModeContext, ModeContext},
+		{ModeChallenge, ModeContext, ModeChallenge},
+		{ModeContext, ModeChallenge, ModeChallenge},
+	}
+
+	for _, tt := range tests {
+		if got := Strictest(tt.a, tt.b); got != tt.expected {
+			t.Errorf("Strictest(%s, %s) = %s, want %s", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package protection
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/idebeijer/kubert/internal/config"
+)
+
+// ActiveFreeze is a change freeze that applies to a context right now.
+type ActiveFreeze struct {
+	Name  string
+	Until time.Time
+
+	// Deny is set when protected commands are denied instead of prompted during the freeze.
+	Deny bool
+}
+
+type freeze struct {
+	name     string
+	contexts *regexp.Regexp
+	location *time.Location
+	weekly   []weeklyWindow
+	dates    []dateRange
+	deny     bool
+}
+
+type weeklyWindow struct {
+	days [7]bool
+	// start and end are minutes since midnight, end may be 24:00.
+	start, end int
+}
+
+type dateRange struct {
+	start, end time.Time
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+func compileFreeze(index int, f config.ProtectionFreeze) (freeze, error) {
+	compiled := freeze{name: f.Name, location: time.Local}
+	if compiled.name == "" {
+		compiled.name = fmt.Sprintf("freezes[%d]", index)
+	}
+	fail := func(format string, args ...any) (freeze, error) {
+		return freeze{}, fmt.Errorf("protection freeze %q: %s", compiled.name, fmt.Sprintf(format, args...))
+	}
+
+	switch f.Action {
+	case "", "protect":
+	case "deny":
+		compiled.deny = true
+	default:
+		return fail("invalid action %q, must be protect or deny", f.Action)
+	}
+
+	if f.Contexts != "" {
+		regex, err := regexp.Compile(f.Contexts)
+		if err != nil {
+			return fail("failed to compile contexts regex: %v", err)
+		}
+		compiled.contexts = regex
+	}
+
+	if f.Timezone != "" {
+		location, err := time.LoadLocation(f.Timezone)
+		if err != nil {
+			return fail("invalid timezone %q: %v", f.Timezone, err)
+		}
+		compiled.location = location
+	}
+
+	if len(f.Weekly) == 0 && len(f.Dates) == 0 {
+		return fail("no weekly windows or dates")
+	}
+
+	for _, w := range f.Weekly {
+		var window weeklyWindow
+		if len(w.Days) == 0 {
+			return fail("weekly window without days")
+		}
+		for _, day := range w.Days {
+			weekday, ok := weekdays[strings.ToLower(day)]
+			if !ok {
+				return fail("invalid day %q", day)
+			}
+			window.days[weekday] = true
+		}
+
+		var err error
+		if window.start, err = parseClock(w.Start); err != nil {
+			return fail("%v", err)
+		}
+		if window.end, err = parseClock(w.End); err != nil {
+			return fail("%v", err)
+		}
+		if window.start >= window.end {
+			return fail("window start %s is not before end %s, split windows that cross midnight", w.Start, w.End)
+		}
+		compiled.weekly = append(compiled.weekly, window)
+	}
+
+	for _, d := range f.Dates {
+		start, _, err := parseFreezeDate(d.Start, compiled.location)
+		if err != nil {
+			return fail("%v", err)
+		}
+		end, dateOnly, err := parseFreezeDate(d.End, compiled.location)
+		if err != nil {
+			return fail("%v", err)
+		}
+		if dateOnly {
+			// A date as end includes the whole day.
+			end = end.AddDate(0, 0, 1)
+		}
+		if !start.Before(end) {
+			return fail("date range start %s is not before end %s", d.Start, d.End)
+		}
+		compiled.dates = append(compiled.dates, dateRange{start: start, end: end})
+	}
+
+	return compiled, nil
+}
+
+// parseClock parses "HH:MM" into minutes since midnight, allowing "24:00".
+func parseClock(s string) (int, error) {
+	var hours, minutes int
+	if _, err := fmt.Sscanf(s, "%d:%d", &hours, &minutes); err != nil || len(s) != 5 {
+		return 0, fmt.Errorf("invalid time %q, must be HH:MM", s)
+	}
+	total := hours*60 + minutes
+	if hours < 0 || minutes < 0 || minutes > 59 || total > 24*60 {
+		return 0, fmt.Errorf("invalid time %q, must be between 00:00 and 24:00", s)
+	}
+	return total, nil
+}
+
+// parseFreezeDate parses a date ("2006-01-02") or a time ("2006-01-02T15:04") and reports
+// whether it was a date.
+func parseFreezeDate(s string, location *time.Location) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, s, location); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04", s, location); err == nil {
+		return t, false, nil
+	}
+	return time.Time{}, false, fmt.Errorf("invalid date %q, must be 2006-01-02 or 2006-01-02T15:04", s)
+}
+
+// activeUntil reports whether the freeze is active at now and when it ends. Windows that
+// follow each other without a gap, like Saturday and Sunday, count as one.
+func (f freeze) activeUntil(now time.Time) (time.Time, bool) {
+	end, ok := f.windowEnd(now)
+	if !ok {
+		return time.Time{}, false
+	}
+	// Bounded, a freeze that is always active would otherwise loop forever.
+	for range 32 {
+		next, ok := f.windowEnd(end)
+		if !ok || !next.After(end) {
+			break
+		}
+		end = next
+	}
+	return end, true
+}
+
+// windowEnd returns the latest end of the windows and date ranges active at t.
+func (f freeze) windowEnd(t time.Time) (time.Time, bool) {
+	t = t.In(f.location)
+	var end time.Time
+	found := false
+	update := func(candidate time.Time) {
+		if !found || candidate.After(end) {
+			end = candidate
+		}
+		found = true
+	}
+
+	year, month, day := t.Date()
+	for _, w := range f.weekly {
+		if !w.days[t.Weekday()] {
+			continue
+		}
+		start := time.Date(year, month, day, 0, w.start, 0, 0, f.location)
+		stop := time.Date(year, month, day, 0, w.end, 0, 0, f.location)
+		if !t.Before(start) && t.Before(stop) {
+			update(stop)
+		}
+	}
+
+	for _, d := range f.dates {
+		if !t.Before(d.start) && t.Before(d.end) {
+			update(d.end)
+		}
+	}
+
+	return end, found
+}
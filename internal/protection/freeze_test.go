@@ -0,0 +1,232 @@
+package protection
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/idebeijer/kubert/internal/config"
+	"github.com/idebeijer/kubert/internal/kubectl"
+	"github.com/idebeijer/kubert/internal/state"
+)
+
+func TestFreeze_ActiveUntil(t *testing.T) {
+	weekend := config.ProtectionFreeze{
+		Name:     "weekend",
+		Timezone: "UTC",
+		Weekly: []config.FreezeWindow{
+			{Days: []string{"fri"}, Start: "16:00", End: "24:00"},
+			{Days: []string{"Saturday", "sun"}, Start: "00:00", End: "24:00"},
+		},
+	}
+	xmas := config.ProtectionFreeze{
+		Name:     "xmas-2026",
+		Timezone: "UTC",
+		Dates:    []config.FreezeDates{{Start: "2026-12-20", End: "2027-01-03"}},
+	}
+	release := config.ProtectionFreeze{
+		Name:     "release",
+		Timezone: "UTC",
+		Dates:    []config.FreezeDates{{Start: "2026-03-10T09:00", End: "2026-03-10T11:30"}},
+	}
+
+	tests := []struct {
+		name          string
+		freeze        config.ProtectionFreeze
+		now           time.Time
+		expectedUntil time.Time
+		active        bool
+	}{
+		{
+			name:   "weekday before window",
+			freeze: weekend,
+			now:    time.Date(2026, 3, 13, 15, 59, 0, 0, time.UTC), // Friday
+		},
+		{
+			name:          "friday evening runs through the weekend",
+			freeze:        weekend,
+			now:           time.Date(2026, 3, 13, 16, 0, 0, 0, time.UTC),
+			expectedUntil: time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC),
+			active:        true,
+		},
+		{
+			name:          "sunday",
+			freeze:        weekend,
+			now:           time.Date(2026, 3, 15, 23, 0, 0, 0, time.UTC),
+			expectedUntil: time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC),
+			active:        true,
+		},
+		{
+			name:   "monday",
+			freeze: weekend,
+			now:    time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "date range includes the end date",
+			freeze:        xmas,
+			now:           time.Date(2027, 1, 3, 22, 0, 0, 0, time.UTC),
+			expectedUntil: time.Date(2027, 1, 4, 0, 0, 0, 0, time.UTC),
+			active:        true,
+		},
+		{
+			name:   "after date range",
+			freeze: xmas,
+			now:    time.Date(2027, 1, 4, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "time range",
+			freeze:        release,
+			now:           time.Date(2026, 3, 10, 10, 0, 0, 0, time.UTC),
+			expectedUntil: time.Date(2026, 3, 10, 11, 30, 0, 0, time.UTC),
+			active:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := compileFreeze(0, tt.freeze)
+			if err != nil {
+				t.Fatal(err)
+			}
+			until, active := f.activeUntil(tt.now)
+			if active != tt.active {
+				t.Fatalf("activeUntil() active = %v, want %v", active, tt.active)
+			}
+			if active && !until.Equal(tt.expectedUntil) {
+				t.Errorf("activeUntil() = %v, want %v", until, tt.expectedUntil)
+			}
+		})
+	}
+}
+
+func TestFreeze_Timezone(t *testing.T) {
+	f, err := compileFreeze(0, config.ProtectionFreeze{
+		Timezone: "America/New_York",
+		Weekly:   []config.FreezeWindow{{Days: []string{"mon"}, Start: "09:00", End: "17:00"}},
+	})
+	if err != nil {
+		t.Skipf("timezone data not available: %v", err)
+	}
+
+	// 13:00 UTC is 09:00 in New York (EDT) on this Monday.
+	if _, active := f.activeUntil(time.Date(2026, 6, 1, 13, 0, 0, 0, time.UTC)); !active {
+		t.Error("Expected freeze to be active at 09:00 New York time")
+	}
+	if _, active := f.activeUntil(time.Date(2026, 6, 1, 12, 59, 0, 0, time.UTC)); active {
+		t.Error("Expected freeze to be inactive before 09:00 New York time")
+	}
+}
+
+func TestCompileFreeze_Invalid(t *testing.T) {
+	window := []config.FreezeWindow{{Days: []string{"mon"}, Start: "09:00", End: "17:00"}}
+	tests := []struct {
+		name     string
+		freeze   config.ProtectionFreeze
+		contains string
+	}{
+		{name: "no windows", freeze: config.ProtectionFreeze{Name: "x"}, contains: "no weekly windows or dates"},
+		{name: "invalid action", freeze: config.ProtectionFreeze{Weekly: window, Action: "block"}, contains: "invalid action"},
+		{name: "invalid day", freeze: config.ProtectionFreeze{Weekly: []config.FreezeWindow{{Days: []string{"funday"}, Start: "09:00", End: "17:00"}}}, contains: "invalid day"},
+		{name: "invalid time", freeze: config.ProtectionFreeze{Weekly: []config.FreezeWindow{{Days: []string{"mon"}, Start: "9:00", End: "17:00"}}}, contains: "invalid time"},
+		{name: "window crosses midnight", freeze: config.ProtectionFreeze{Weekly: []config.FreezeWindow{{Days: []string{"mon"}, Start: "22:00", End: "02:00"}}}, contains: "not before end"},
+		{name: "invalid date", freeze: config.ProtectionFreeze{Dates: []config.FreezeDates{{Start: "20-12-2026", End: "2026-12-24"}}}, contains: "invalid date"},
+		{name: "invalid timezone", freeze: config.ProtectionFreeze{Weekly: window, Timezone: "Mars/Olympus"}, contains: "invalid timezone"},
+		{name: "invalid contexts regex", freeze: config.ProtectionFreeze{Weekly: window, Contexts: "["}, contains: "contexts regex"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := compileFreeze(0, tt.freeze)
+			if err == nil || !strings.Contains(err.Error(), tt.contains) {
+				t.Errorf("compileFreeze() error = %v, want error containing %q", err, tt.contains)
+			}
+		})
+	}
+}
+
+func TestEngine_Freezes(t *testing.T) {
+	prodRegex := "^prod"
+	now := time.Date(2026, 12, 24, 12, 0, 0, 0, time.UTC)
+	xmas := []config.FreezeDates{{Start: "2026-12-20", End: "2027-01-03"}}
+	deletePod := kubectl.Parse([]string{"delete", "pod", "x"})
+
+	newEngine := func(t *testing.T, sm *state.Manager, action string) *Engine {
+		t.Helper()
+		e, err := NewEngine(config.Config{Protection: config.Protection{
+			Regex:    &prodRegex,
+			Commands: []string{"delete"},
+			Prompt:   true,
+			Rules: []config.ProtectionRule{
+				{Name: "preview-patch", Verbs: []string{"patch"}, Action: "preview"},
+			},
+			Freezes: []config.ProtectionFreeze{
+				{Name: "xmas-2026", Contexts: "^(prod|staging)", Timezone: "UTC", Dates: xmas, Action: action},
+			},
+		}}, sm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.now = func() time.Time { return now }
+		return e
+	}
+
+	t.Run("protect freeze protects matching contexts", func(t *testing.T) {
+		e := newEngine(t, newTestStateManager(t), "protect")
+
+		status, _ := e.Status("staging-eu")
+		if !status.Protected || status.Source != SourceFreeze || status.Freeze == nil || status.Freeze.Name != "xmas-2026" {
+			t.Errorf("Status(staging-eu) = %+v, want protected by freeze", status)
+		}
+		if !status.Freeze.Until.Equal(time.Date(2027, 1, 4, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("Freeze.Until = %v", status.Freeze.Until)
+		}
+
+		decision, _ := e.Evaluate(Request{Context: "staging-eu", Command: deletePod})
+		if decision.Action != ActionPrompt {
+			t.Errorf("Evaluate() = %s, want prompt", decision.Action)
+		}
+
+		status, _ = e.Status("dev")
+		if status.Protected || status.Freeze != nil {
+			t.Errorf("Status(dev) = %+v, want unprotected", status)
+		}
+	})
+
+	t.Run("protect freeze overrides explicit unprotect but not a lift", func(t *testing.T) {
+		sm := newTestStateManager(t)
+		_ = sm.EnsureContextExists("staging-eu")
+		_ = sm.SetContextProtection("staging-eu", false)
+		e := newEngine(t, sm, "protect")
+
+		if status, _ := e.Status("staging-eu"); status.Source != SourceFreeze {
+			t.Errorf("Status() = %+v, want protected by freeze", status)
+		}
+
+		_ = sm.LiftContextProtection("staging-eu", now.Add(time.Hour), state.LiftInfo{Reason: "hotfix"})
+		if status, _ := e.Status("staging-eu"); status.Source != SourceLift || status.Freeze == nil {
+			t.Errorf("Status() = %+v, want lifted during freeze", status)
+		}
+	})
+
+	t.Run("deny freeze escalates prompts and ignores lifts", func(t *testing.T) {
+		sm := newTestStateManager(t)
+		_ = sm.EnsureContextExists("prod-eu")
+		_ = sm.LiftContextProtection("prod-eu", now.Add(time.Hour), state.LiftInfo{Reason: "hotfix"})
+		e := newEngine(t, sm, "deny")
+
+		decision, _ := e.Evaluate(Request{Context: "prod-eu", Command: deletePod})
+		if decision.Action != ActionDeny || decision.Status.Source != SourceFreeze || !strings.Contains(decision.Reason, "xmas-2026") {
+			t.Errorf("Evaluate() = %+v, want deny during freeze", decision)
+		}
+
+		decision, _ = e.Evaluate(Request{Context: "prod-eu", Command: kubectl.Parse([]string{"patch", "deploy", "web", "-p", "{}"})})
+		if decision.Action != ActionDeny || decision.Rule != "preview-patch" {
+			t.Errorf("Evaluate(patch) = %+v, want deny during freeze", decision)
+		}
+
+		decision, _ = e.Evaluate(Request{Context: "prod-eu", Command: kubectl.Parse([]string{"get", "pods"})})
+		if decision.Action != ActionAllow {
+			t.Errorf("Evaluate(get) = %s, want allow", decision.Action)
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package protection
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+
+	"github.com/idebeijer/kubert/internal/kubeconfig"
+	"github.com/idebeijer/kubert/internal/state"
+)
+
+// Overrides are the explicit protection settings of contexts, as written by "kubert protection
+// export" and read by "kubert protection import". Lifts and other local state are not part of it.
+type Overrides struct {
+	Contexts map[string]ContextOverrides `json:"contexts" yaml:"contexts"`
+}
+
+// ContextOverrides are the explicit protection settings of a single context.
+type ContextOverrides struct {
+	Protected  *bool           `json:"protected,omitempty" yaml:"protected,omitempty"`
+	Profile    string          `json:"profile,omitempty" yaml:"profile,omitempty"`
+	Namespaces map[string]bool `json:"namespaces,omitempty" yaml:"namespaces,omitempty"`
+}
+
+func (o ContextOverrides) empty() bool {
+	return o.Protected == nil && o.Profile == "" && len(o.Namespaces) == 0
+}
+
+// overridesOf returns the explicit protection settings in the state of a context.
+func overridesOf(info state.ContextInfo) ContextOverrides {
+	return ContextOverrides{Protected: info.Protected, Profile: info.Profile, Namespaces: info.Namespaces}
+}
+
+// ExportOverrides returns the explicit protection settings of all contexts that have any.
+func (e *Engine) ExportOverrides() Overrides {
+	overrides := Overrides{Contexts: make(map[string]ContextOverrides)}
+	for _, context := range e.state.ListContexts() {
+		info, _ := e.state.ContextInfo(context)
+		if o := overridesOf(info); !o.empty() {
+			overrides.Contexts[context] = o
+		}
+	}
+	return overrides
+}
+
+// ImportOverrides applies explicit protection settings. Settings are merged into the existing
+// ones: a context keeps the settings the import does not mention, and namespace overrides are
+// added to those it already has. With replace, all existing explicit settings are removed first,
+// so afterwards only the imported ones apply. Lifts are kept either way.
+func (e *Engine) ImportOverrides(overrides Overrides, replace bool) error {
+	for context, o := range overrides.Contexts {
+		if o.Profile != "" && !e.HasProfile(o.Profile) {
+			return fmt.Errorf("protection profile %q of context %q is not defined in the config", o.Profile, context)
+		}
+	}
+
+	return e.state.UpdateContexts(func(infos map[string]state.ContextInfo) error {
+		if replace {
+			for context, info := range infos {
+				info.Protected = nil
+				info.Profile = ""
+				info.Namespaces = nil
+				infos[context] = info
+			}
+		}
+
+		for context, o := range overrides.Contexts {
+			info := infos[context]
+			if o.Protected != nil {
+				protected := *o.Protected
+				info.Protected = &protected
+			}
+			if o.Profile != "" {
+				info.Profile = ProfileName(o.Profile)
+			}
+			for namespace, protected := range o.Namespaces {
+				if info.Namespaces == nil {
+					info.Namespaces = make(map[string]bool)
+				}
+				info.Namespaces[namespace] = protected
+			}
+			infos[context] = info
+		}
+		return nil
+	})
+}
+
+// InheritRenamed copies the explicit protection settings stored under the kubeconfig name of a
+// context that was renamed for a duplicate name to its new name, if nothing is stored under the
+// new name yet. Otherwise a context protected before a second kubeconfig with the same name was
+// added would silently lose its protection.
+func InheritRenamed(sm *state.Manager, contexts []kubeconfig.Context) error {
+	inherit := make(map[string]string)
+	for _, c := range contexts {
+		if c.OriginalName == "" {
+			continue
+		}
+		if _, exists := sm.ContextInfo(c.Name); exists {
+			continue
+		}
+		if info, exists := sm.ContextInfo(c.OriginalName); exists && !overridesOf(info).empty() {
+			inherit[c.Name] = c.OriginalName
+		}
+	}
+	if len(inherit) == 0 {
+		return nil
+	}
+
+	return sm.UpdateContexts(func(infos map[string]state.ContextInfo) error {
+		for context, original := range inherit {
+			info := infos[original]
+			infos[context] = state.ContextInfo{
+				Protected:  info.Protected,
+				Profile:    info.Profile,
+				Namespaces: maps.Clone(info.Namespaces),
+			}
+			slog.Warn("Context was renamed because another kubeconfig defines the same name, it keeps the protection settings stored under its old name",
+				"context", context, "previous", original)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,100 @@
+package protection
+
+import (
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/idebeijer/kubert/internal/config"
+	"github.com/idebeijer/kubert/internal/state"
+)
+
+func TestEngine_ImportOverrides(t *testing.T) {
+	yes, no := true, false
+	cfg := config.Config{Protection: config.Protection{Profiles: map[string]config.ProtectionProfile{"strict": {}}}}
+
+	tests := []struct {
+		name     string
+		replace  bool
+		expected map[string]ContextOverrides
+	}{
+		{
+			name:    "merge",
+			replace: false,
+			expected: map[string]ContextOverrides{
+				"dev":     {Protected: &no},
+				"prod":    {Protected: &yes, Profile: "strict"},
+				"shared":  {Namespaces: map[string]bool{"payments": true, "billing": true}},
+				"staging": {Protected: &yes},
+			},
+		},
+		{
+			name:    "replace",
+			replace: true,
+			expected: map[string]ContextOverrides{
+				"prod":    {Protected: &yes, Profile: "strict"},
+				"shared":  {Namespaces: map[string]bool{"billing": true}},
+				"staging": {Protected: &yes},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := newTestStateManager(t)
+			for _, context := range []string{"dev", "prod", "shared"} {
+				if err := sm.EnsureContextExists(context); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_ = sm.SetContextProtection("dev", false)
+			_ = sm.SetContextProtection("prod", false)
+			_ = sm.SetNamespaceProtection("shared", "payments", true)
+			liftedUntil := time.Now().Add(time.Hour)
+			_ = sm.LiftContextProtection("prod", liftedUntil, state.LiftInfo{Reason: "incident"})
+
+			e, err := NewEngine(cfg, sm)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = e.ImportOverrides(Overrides{Contexts: map[string]ContextOverrides{
+				"prod":    {Protected: &yes, Profile: "strict"},
+				"shared":  {Namespaces: map[string]bool{"billing": true}},
+				"staging": {Protected: &yes},
+			}}, tt.replace)
+			if err != nil {
+				t.Fatalf("ImportOverrides() error = %v", err)
+			}
+
+			got := e.ExportOverrides().Contexts
+			if !maps.EqualFunc(got, tt.expected, equalContextOverrides) {
+				t.Errorf("ExportOverrides() = %+v, want %+v", got, tt.expected)
+			}
+
+			// Lifts are local state and survive an import.
+			if info, _ := sm.ContextInfo("prod"); info.Lift == nil || info.Lift.Reason != "incident" {
+				t.Errorf("lift of prod = %+v, want it kept", info.Lift)
+			}
+		})
+	}
+
+	t.Run("unknown profile", func(t *testing.T) {
+		sm := newTestStateManager(t)
+		e, _ := NewEngine(cfg, sm)
+		err := e.ImportOverrides(Overrides{Contexts: map[string]ContextOverrides{"prod": {Profile: "lenient"}}}, false)
+		if err == nil {
+			t.Fatal("expected an error for a profile that is not in the config")
+		}
+		if len(sm.ListContexts()) != 0 {
+			t.Errorf("contexts = %v, want nothing imported", sm.ListContexts())
+		}
+	})
+}
+
+func equalContextOverrides(a, b ContextOverrides) bool {
+	if (a.Protected == nil) != (b.Protected == nil) || (a.Protected != nil && *a.Protected != *b.Protected) {
+		return false
+	}
+	return a.Profile == b.Profile && maps.Equal(a.Namespaces, b.Namespaces)
+}
@@ -0,0 +1,575 @@
+package protection
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/idebeijer/kubert/internal/config"
+	"github.com/idebeijer/kubert/internal/kubeconfig"
+	"github.com/idebeijer/kubert/internal/kubectl"
+	"github.com/idebeijer/kubert/internal/kubert"
+	"github.com/idebeijer/kubert/internal/prompt"
+	"github.com/idebeijer/kubert/internal/state"
+)
+
+// Action is what kubert does with a command.
+type Action string
+
+const (
+	ActionAllow  Action = "allow"
+	ActionPrompt Action = "prompt"
+	ActionDeny   Action = "deny"
+
+	// ActionPreview prompts like ActionPrompt, after showing what the command would change.
+	ActionPreview Action = "preview"
+)
+
+// Confirms reports whether the action asks for confirmation before the command runs.
+func (a Action) Confirms() bool {
+	return a == ActionPrompt || a == ActionPreview
+}
+
+// Source describes where the protection status of a context comes from.
+type Source string
+
+const (
+	SourceNone     Source = "none"
+	SourceLift     Source = "lift"
+	SourceFreeze   Source = "freeze"
+	SourceExplicit Source = "explicit"
+	SourceProfile  Source = "profile"
+	SourceRegex    Source = "regex"
+
+	// SourceNamespace is only used in decisions, for commands in an otherwise unprotected
+	// context that target a protected namespace.
+	SourceNamespace Source = "namespace"
+)
+
+// AllNamespaces is the Status.Namespace of commands across all namespaces.
+const AllNamespaces = "*"
+
+// Status is the protection status of a context.
+type Status struct {
+	Context   string
+	Protected bool
+	Source    Source
+
+	// LiftedUntil is set when protection is temporarily lifted for this shell, Lift describes
+	// the lift. A lift limited to some commands leaves the context protected.
+	LiftedUntil *time.Time
+	Lift        *state.LiftInfo
+
+	// Regex is the regex that matched the context, set when the status comes from the
+	// default regex or a context profile.
+	Regex string
+
+	// Profile is the protection profile that applies to the context, empty for the
+	// top-level protection settings.
+	Profile string
+
+	// Freeze is the change freeze that applies to the context right now, if any.
+	Freeze *ActiveFreeze
+
+	// Namespace is the protected namespace a command targets, set when the protection comes
+	// from namespace protection. It is AllNamespaces for commands across all namespaces.
+	Namespace string
+}
+
+// Request is a command that is about to run against a context.
+type Request struct {
+	Context string
+
+	// Namespace is the namespace the command targets, AllNamespaces is set for commands
+	// that target every namespace.
+	Namespace     string
+	AllNamespaces bool
+
+	Command kubectl.Command
+
+	// Opaque is set for commands that are not kubectl invocations and therefore cannot be
+	// inspected. They are handled as protected commands.
+	Opaque bool
+
+	// ToolCommand is the protected command of a tool other than kubectl, e.g. "helm upgrade".
+	// Requests for protected tool commands are opaque.
+	ToolCommand string
+}
+
+// Decision is the outcome of evaluating a Request.
+type Decision struct {
+	Status Status
+	Action Action
+
+	// Rule is the name of the rule that decided, empty if the decision was not made by a rule.
+	Rule string
+
+	// Reason explains the decision in a few words.
+	Reason string
+
+	// Confirm is how a prompt has to be answered.
+	Confirm prompt.Mode
+}
+
+// Engine evaluates protection for contexts and commands. It is shared by "kubert kubectl",
+// "kubert exec" and "kubert protection".
+type Engine struct {
+	state           *state.Manager
+	regex           *regexp.Regexp
+	namespaceRegex  *regexp.Regexp
+	defaults        settings
+	profiles        map[string]settings
+	contextProfiles []contextProfile
+	freezes         []freeze
+	readonly        []readonlyIdentity
+	aliases         *kubeconfig.Aliases
+	now             func() time.Time
+
+	// session is the shell kubeconfig of the current kubert shell, used for lifts limited to
+	// a single shell.
+	session string
+}
+
+// settings are the commands, prompt behavior and rules that apply to a protected context,
+// either from the top-level protection config or from a profile.
+type settings struct {
+	commands []string
+	prompt   bool
+	confirm  prompt.Mode
+	rules    []rule
+}
+
+type readonlyIdentity struct {
+	regex    *regexp.Regexp
+	identity config.ReadonlyIdentity
+}
+
+type contextProfile struct {
+	regex   *regexp.Regexp
+	profile string
+}
+
+// NewEngine creates an Engine from the protection config, with explicit overrides and lifts
+// read from the state manager.
+func NewEngine(cfg config.Config, sm *state.Manager) (*Engine, error) {
+	e := &Engine{
+		state:    sm,
+		profiles: make(map[string]settings),
+		now:      time.Now,
+		session:  os.Getenv(kubert.ShellKubeconfigEnvVar),
+	}
+
+	aliases, err := kubeconfig.NewAliases(cfg.Aliases)
+	if err != nil {
+		return nil, err
+	}
+	e.aliases = aliases
+
+	if cfg.Protection.Regex != nil {
+		regex, err := regexp.Compile(*cfg.Protection.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile regex: %w", err)
+		}
+		e.regex = regex
+	}
+
+	if cfg.Protection.NamespaceRegex != nil {
+		regex, err := regexp.Compile(*cfg.Protection.NamespaceRegex)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile namespace regex: %w", err)
+		}
+		e.namespaceRegex = regex
+	}
+
+	defaults, err := compileSettings(cfg.Protection.Commands, cfg.Protection.Prompt, cfg.Protection.Confirm, cfg.Protection.Rules)
+	if err != nil {
+		return nil, err
+	}
+	e.defaults = defaults
+
+	for name, profile := range cfg.Protection.Profiles {
+		compiled, err := compileProfile(defaults, profile, cfg.Protection.Rules)
+		if err != nil {
+			return nil, fmt.Errorf("protection profile %q: %w", name, err)
+		}
+		e.profiles[ProfileName(name)] = compiled
+	}
+
+	for _, cp := range cfg.Protection.ContextProfiles {
+		if !e.HasProfile(cp.Profile) {
+			return nil, fmt.Errorf("context profile for %q refers to unknown protection profile %q", cp.Regex, cp.Profile)
+		}
+		regex, err := regexp.Compile(cp.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile context profile regex: %w", err)
+		}
+		e.contextProfiles = append(e.contextProfiles, contextProfile{regex: regex, profile: ProfileName(cp.Profile)})
+	}
+
+	for i, f := range cfg.Protection.Freezes {
+		compiled, err := compileFreeze(i, f)
+		if err != nil {
+			return nil, err
+		}
+		e.freezes = append(e.freezes, compiled)
+	}
+
+	for i, identity := range cfg.Protection.Readonly {
+		if identity.User == "" {
+			return nil, fmt.Errorf("readonly identity %d has no user, groups can only be impersonated together with a user", i)
+		}
+		regex, err := regexp.Compile(identity.Contexts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile readonly contexts regex: %w", err)
+		}
+		e.readonly = append(e.readonly, readonlyIdentity{regex: regex, identity: identity})
+	}
+
+	return e, nil
+}
+
+func compileSettings(commands []string, promptEnabled bool, confirm string, rules []config.ProtectionRule) (settings, error) {
+	mode, err := prompt.ParseMode(confirm)
+	if err != nil {
+		return settings{}, err
+	}
+
+	s := settings{commands: commands, prompt: promptEnabled, confirm: mode}
+	for i, r := range rules {
+		compiled, err := compileRule(i, r)
+		if err != nil {
+			return settings{}, err
+		}
+		s.rules = append(s.rules, compiled)
+	}
+	return s, nil
+}
+
+// compileProfile compiles a profile, falling back to the top-level settings for every field
+// the profile leaves unset.
+func compileProfile(defaults settings, profile config.ProtectionProfile, defaultRules []config.ProtectionRule) (settings, error) {
+	commands := defaults.commands
+	if profile.Commands != nil {
+		commands = profile.Commands
+	}
+	promptEnabled := defaults.prompt
+	if profile.Prompt != nil {
+		promptEnabled = *profile.Prompt
+	}
+	confirm := string(defaults.confirm)
+	if profile.Confirm != "" {
+		confirm = profile.Confirm
+	}
+	rules := defaultRules
+	if profile.Rules != nil {
+		rules = profile.Rules
+	}
+	return compileSettings(commands, promptEnabled, confirm, rules)
+}
+
+// ReadonlyIdentity returns the identity to impersonate for a read-only shell of the context.
+func (e *Engine) ReadonlyIdentity(context string) (config.ReadonlyIdentity, bool) {
+	for _, r := range e.readonly {
+		if e.matchContext(r.regex, context) {
+			return r.identity, true
+		}
+	}
+	return config.ReadonlyIdentity{}, false
+}
+
+// HasProfile reports whether a protection profile with the given name is configured.
+func (e *Engine) HasProfile(name string) bool {
+	_, ok := e.profiles[ProfileName(name)]
+	return ok
+}
+
+// ProfileName returns the name a protection profile is stored and looked up under. Profiles
+// are a map in the config and viper lowercases map keys, so profile names are case-insensitive.
+func ProfileName(name string) string {
+	return strings.ToLower(name)
+}
+
+// Status returns the protection status of a context. A freeze that denies commands takes
+// precedence over everything, then a lift, a freeze that protects, an explicit override,
+// context profiles and finally the default regex. A lift limited to some commands is reported
+// on the status but leaves the context protected.
+func (e *Engine) Status(context string) (Status, error) {
+	status := Status{Context: context, Source: SourceNone, Freeze: e.activeFreeze(context)}
+	if status.Freeze != nil && status.Freeze.Deny {
+		status.Source = SourceFreeze
+		status.Protected = true
+	}
+
+	// The profile is resolved up front so a lifted or unprotected context still reports
+	// which profile applies once protection is back.
+	info, exists := e.state.ContextInfo(context)
+	status.Profile = ProfileName(info.Profile)
+	var profileRegex string
+	if status.Profile == "" {
+		status.Profile, profileRegex = e.contextProfile(context)
+	}
+	if status.Profile != "" && !e.HasProfile(status.Profile) {
+		return Status{}, fmt.Errorf("context %q uses unknown protection profile %q", context, status.Profile)
+	}
+
+	if status.Source == SourceFreeze {
+		return status, nil
+	}
+
+	if exists {
+		if info.ProtectedUntil != nil {
+			if e.now().Before(*info.ProtectedUntil) {
+				if e.liftApplies(info.Lift) {
+					status.LiftedUntil = info.ProtectedUntil
+					status.Lift = info.Lift
+					if info.Lift == nil || len(info.Lift.Commands) == 0 {
+						status.Source = SourceLift
+						return status, nil
+					}
+				}
+			} else {
+				// Lift has expired, clean up (best effort)
+				_ = e.state.ClearProtectedUntil(context)
+			}
+		}
+	}
+
+	if status.Freeze != nil {
+		status.Source = SourceFreeze
+		status.Protected = true
+		return status, nil
+	}
+
+	if exists {
+		if info.Protected != nil {
+			status.Source = SourceExplicit
+			status.Protected = *info.Protected
+			return status, nil
+		}
+	}
+
+	if profileRegex != "" {
+		status.Source = SourceProfile
+		status.Regex = profileRegex
+		status.Protected = true
+		return status, nil
+	}
+
+	if e.regex != nil {
+		status.Source = SourceRegex
+		status.Regex = e.regex.String()
+		status.Protected = e.matchContext(e.regex, context)
+	}
+
+	return status, nil
+}
+
+// activeFreeze returns the freeze that applies to the context right now. A freeze that denies
+// commands wins over one that only protects.
+func (e *Engine) activeFreeze(context string) *ActiveFreeze {
+	var active *ActiveFreeze
+	now := e.now()
+	for _, f := range e.freezes {
+		if f.contexts != nil && !e.matchContext(f.contexts, context) {
+			continue
+		}
+		until, ok := f.activeUntil(now)
+		if !ok {
+			continue
+		}
+		if active == nil || (f.deny && !active.Deny) {
+			active = &ActiveFreeze{Name: f.name, Until: until, Deny: f.deny}
+		}
+	}
+	return active
+}
+
+// NamespaceStatus reports whether a namespace of the context is protected and whether that
+// comes from an explicit override or the namespace regex.
+func (e *Engine) NamespaceStatus(context, namespace string) (bool, Source) {
+	if protected, ok := e.NamespaceOverrides(context)[namespace]; ok {
+		return protected, SourceExplicit
+	}
+	if e.namespaceRegex != nil {
+		return e.namespaceRegex.MatchString(namespace), SourceRegex
+	}
+	return false, SourceNone
+}
+
+// NamespaceOverrides returns the explicit namespace protection overrides of the context.
+func (e *Engine) NamespaceOverrides(context string) map[string]bool {
+	info, _ := e.state.ContextInfo(context)
+	return info.Namespaces
+}
+
+// NamespaceRegex returns the namespace regex from the config, empty if none is configured.
+func (e *Engine) NamespaceRegex() string {
+	if e.namespaceRegex == nil {
+		return ""
+	}
+	return e.namespaceRegex.String()
+}
+
+// protectedNamespace returns the protected namespace a request targets. Commands on namespace
+// resources target the namespaces they name, so "delete ns payments" targets "payments". A
+// request across all namespaces targets a protected namespace if any namespace can be
+// protected in the context.
+func (e *Engine) protectedNamespace(req Request) (string, bool) {
+	if slices.ContainsFunc(req.Command.Resources, isNamespaceResource) {
+		for _, name := range req.Command.Names {
+			if protected, _ := e.NamespaceStatus(req.Context, name); protected {
+				return name, true
+			}
+		}
+	}
+
+	if req.AllNamespaces {
+		if e.namespaceRegex != nil {
+			return AllNamespaces, true
+		}
+		for _, protected := range e.NamespaceOverrides(req.Context) {
+			if protected {
+				return AllNamespaces, true
+			}
+		}
+		return "", false
+	}
+
+	protected, _ := e.NamespaceStatus(req.Context, req.Namespace)
+	return req.Namespace, protected
+}
+
+// isNamespaceResource reports whether a resource type refers to namespaces.
+func isNamespaceResource(resource string) bool {
+	switch strings.ToLower(resource) {
+	case "namespace", "namespaces", "ns":
+		return true
+	}
+	return false
+}
+
+// liftApplies reports whether a lift applies to the current shell. Lifts without lift info
+// predate scoped lifts and apply everywhere.
+func (e *Engine) liftApplies(lift *state.LiftInfo) bool {
+	return lift == nil || lift.Session == "" || lift.Session == e.session
+}
+
+// contextProfile returns the profile of the first context profile matching the context,
+// along with its regex.
+func (e *Engine) contextProfile(context string) (string, string) {
+	for _, cp := range e.contextProfiles {
+		if e.matchContext(cp.regex, context) {
+			return cp.profile, cp.regex.String()
+		}
+	}
+	return "", ""
+}
+
+// matchContext reports whether the regex matches the name or the alias of the context.
+func (e *Engine) matchContext(regex *regexp.Regexp, context string) bool {
+	if regex.MatchString(context) {
+		return true
+	}
+	alias := e.aliases.Alias(context)
+	return alias != "" && regex.MatchString(alias)
+}
+
+// settingsFor returns the settings of the given profile, or the top-level settings.
+func (e *Engine) settingsFor(profile string) settings {
+	if s, ok := e.profiles[ProfileName(profile)]; ok {
+		return s
+	}
+	return e.defaults
+}
+
+// Evaluate decides what to do with a command. Commands in unprotected contexts are always
+// allowed, unless they target a protected namespace and protection is not lifted. In protected
+// contexts the first matching rule decides, falling back to the configured command list.
+// Commands covered by a lift limited to some commands are allowed. During a freeze that denies
+// commands, commands that would ask for confirmation are denied.
+func (e *Engine) Evaluate(req Request) (Decision, error) {
+	status, err := e.Status(req.Context)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	if !status.Protected && status.Source != SourceLift {
+		if namespace, ok := e.protectedNamespace(req); ok {
+			status.Protected = true
+			status.Source = SourceNamespace
+			status.Namespace = namespace
+			status.Regex = ""
+		}
+	}
+
+	decision := e.decide(req, status)
+	if decision.Action.Confirms() && status.Freeze != nil && status.Freeze.Deny {
+		decision.Action = ActionDeny
+		decision.Reason += fmt.Sprintf(" during freeze %q", status.Freeze.Name)
+	}
+	return decision, nil
+}
+
+func (e *Engine) decide(req Request, status Status) Decision {
+	decision := Decision{Status: status, Action: ActionAllow}
+	if !status.Protected {
+		decision.Reason = "context is not protected"
+		return decision
+	}
+
+	if lift := status.Lift; lift != nil && !req.Opaque && slices.ContainsFunc(lift.Commands, req.Command.MatchesVerb) {
+		decision.Reason = fmt.Sprintf("protection lifted for %q", req.Command.Verb)
+		return decision
+	}
+
+	s := e.settingsFor(status.Profile)
+	decision.Confirm = s.confirm
+	for _, r := range s.rules {
+		if r.matches(req) {
+			decision.Action = r.action
+			decision.Rule = r.name
+			decision.Reason = fmt.Sprintf("matches rule %q", r.name)
+			return decision
+		}
+	}
+
+	switch {
+	case req.ToolCommand != "":
+		decision.Reason = fmt.Sprintf("%q is a protected command", req.ToolCommand)
+	case req.Opaque:
+		decision.Reason = "command cannot be inspected"
+	case s.isCommandProtected(req.Command):
+		decision.Reason = fmt.Sprintf("%q is a protected command", req.Command.Verb)
+	default:
+		decision.Reason = "command is not protected"
+		return decision
+	}
+
+	decision.Action = ActionDeny
+	if s.prompt {
+		decision.Action = ActionPrompt
+	}
+	return decision
+}
+
+// Rules returns the names of the rules that apply under the given profile, in evaluation order.
+func (e *Engine) Rules(profile string) []string {
+	s := e.settingsFor(profile)
+	names := make([]string, 0, len(s.rules))
+	for _, r := range s.rules {
+		names = append(names, r.name)
+	}
+	return names
+}
+
+func (s settings) isCommandProtected(command kubectl.Command) bool {
+	for _, blocked := range s.commands {
+		if command.MatchesVerb(blocked) {
+			return true
+		}
+	}
+	return false
+}
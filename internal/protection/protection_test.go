@@ -0,0 +1,652 @@
+package protection
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adrg/xdg"
+
+	"github.com/idebeijer/kubert/internal/config"
+	"github.com/idebeijer/kubert/internal/kubectl"
+	"github.com/idebeijer/kubert/internal/prompt"
+	"github.com/idebeijer/kubert/internal/state"
+)
+
+func newTestStateManager(t *testing.T) *state.Manager {
+	t.Helper()
+	original := xdg.DataHome
+	xdg.DataHome = t.TempDir()
+	t.Cleanup(func() { xdg.DataHome = original })
+
+	sm, err := state.NewManager()
+	if err != nil {
+		t.Fatalf("Failed to create state manager: %v", err)
+	}
+	return sm
+}
+
+func TestEngine_Status(t *testing.T) {
+	prodRegex := "^prod"
+	cfg := config.Config{Protection: config.Protection{Regex: &prodRegex}}
+
+	t.Run("regex", func(t *testing.T) {
+		e, err := NewEngine(cfg, newTestStateManager(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		status, err := e.Status("prod-cluster")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !status.Protected || status.Source != SourceRegex || status.Regex != prodRegex {
+			t.Errorf("Status(prod-cluster) = %+v, want protected by regex", status)
+		}
+
+		status, _ = e.Status("dev-cluster")
+		if status.Protected || status.Source != SourceRegex {
+			t.Errorf("Status(dev-cluster) = %+v, want unprotected by regex", status)
+		}
+	})
+
+	t.Run("regex matches alias", func(t *testing.T) {
+		aliased := cfg
+		aliased.Aliases = config.Aliases{Rewrites: []config.AliasRewrite{
+			{Regex: `^arn:aws:eks:[^:]+:\d+:cluster/(.+)$`, Replacement: "$1"},
+		}}
+		e, err := NewEngine(aliased, newTestStateManager(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		status, _ := e.Status("arn:aws:eks:eu-west-1:1234:cluster/prod-a")
+		if !status.Protected || status.Source != SourceRegex {
+			t.Errorf("Status() = %+v, want protected because the alias matches the regex", status)
+		}
+	})
+
+	t.Run("no protection configured", func(t *testing.T) {
+		e, err := NewEngine(config.Config{}, newTestStateManager(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		status, _ := e.Status("prod-cluster")
+		if status.Protected || status.Source != SourceNone {
+			t.Errorf("Status() = %+v, want unprotected without source", status)
+		}
+	})
+
+	t.Run("explicit override wins over regex", func(t *testing.T) {
+		sm := newTestStateManager(t)
+		_ = sm.EnsureContextExists("prod-cluster")
+		_ = sm.SetContextProtection("prod-cluster", false)
+
+		e, _ := NewEngine(cfg, sm)
+		status, _ := e.Status("prod-cluster")
+		if status.Protected || status.Source != SourceExplicit {
+			t.Errorf("Status() = %+v, want explicitly unprotected", status)
+		}
+	})
+
+	t.Run("lift wins over explicit override", func(t *testing.T) {
+		sm := newTestStateManager(t)
+		_ = sm.EnsureContextExists("dev-cluster")
+		_ = sm.SetContextProtection("dev-cluster", true)
+		_ = sm.LiftContextProtection("dev-cluster", time.Now().Add(time.Hour), state.LiftInfo{})
+
+		e, _ := NewEngine(cfg, sm)
+		status, _ := e.Status("dev-cluster")
+		if status.Protected || status.Source != SourceLift || status.LiftedUntil == nil {
+			t.Errorf("Status() = %+v, want lifted", status)
+		}
+	})
+
+	t.Run("expired lift is ignored", func(t *testing.T) {
+		sm := newTestStateManager(t)
+		_ = sm.EnsureContextExists("dev-cluster")
+		_ = sm.SetContextProtection("dev-cluster", true)
+		_ = sm.LiftContextProtection("dev-cluster", time.Now().Add(-time.Minute), state.LiftInfo{})
+
+		e, _ := NewEngine(cfg, sm)
+		status, _ := e.Status("dev-cluster")
+		if !status.Protected || status.Source != SourceExplicit {
+			t.Errorf("Status() = %+v, want explicitly protected", status)
+		}
+	})
+}
+
+func TestEngine_ScopedLift(t *testing.T) {
+	prodRegex := "^prod"
+	cfg := config.Config{Protection: config.Protection{Regex: &prodRegex, Commands: []string{"delete", "apply"}}}
+	deletePod := kubectl.Parse([]string{"delete", "pod", "x"})
+	applyFile := kubectl.Parse([]string{"apply", "-f", "."})
+
+	t.Run("lift limited to commands", func(t *testing.T) {
+		sm := newTestStateManager(t)
+		_ = sm.EnsureContextExists("prod")
+		_ = sm.LiftContextProtection("prod", time.Now().Add(time.Hour), state.LiftInfo{Reason: "cleanup", Commands: []string{"delete"}})
+
+		e, _ := NewEngine(cfg, sm)
+		decision, _ := e.Evaluate(Request{Context: "prod", Command: deletePod})
+		if decision.Action != ActionAllow || !decision.Status.Protected || decision.Status.Lift == nil {
+			t.Errorf("Evaluate(delete) = %+v, want allow by lift in protected context", decision)
+		}
+
+		decision, _ = e.Evaluate(Request{Context: "prod", Command: applyFile})
+		if decision.Action != ActionDeny {
+			t.Errorf("Evaluate(apply) = %s, want deny", decision.Action)
+		}
+
+		decision, _ = e.Evaluate(Request{Context: "prod", Opaque: true})
+		if decision.Action != ActionDeny {
+			t.Errorf("Evaluate(opaque) = %s, want deny", decision.Action)
+		}
+	})
+
+	t.Run("lift limited to a session", func(t *testing.T) {
+		sm := newTestStateManager(t)
+		_ = sm.EnsureContextExists("prod")
+		_ = sm.LiftContextProtection("prod", time.Now().Add(time.Hour), state.LiftInfo{Session: "/tmp/kubert-a.yaml"})
+
+		e, _ := NewEngine(cfg, sm)
+		e.session = "/tmp/kubert-a.yaml"
+		status, _ := e.Status("prod")
+		if status.Protected || status.Source != SourceLift {
+			t.Errorf("Status() in lifted session = %+v, want lifted", status)
+		}
+
+		e.session = "/tmp/kubert-b.yaml"
+		status, _ = e.Status("prod")
+		if !status.Protected || status.Source != SourceRegex || status.LiftedUntil != nil {
+			t.Errorf("Status() in other session = %+v, want protected by regex", status)
+		}
+	})
+}
+
+func TestNewEngine_InvalidConfig(t *testing.T) {
+	invalidRegex := "["
+	tests := []struct {
+		name     string
+		cfg      config.Protection
+		contains string
+	}{
+		{
+			name:     "invalid regex",
+			cfg:      config.Protection{Regex: &invalidRegex},
+			contains: "failed to compile regex",
+		},
+		{
+			name:     "invalid namespace regex",
+			cfg:      config.Protection{NamespaceRegex: &invalidRegex},
+			contains: "failed to compile namespace regex",
+		},
+		{
+			name:     "invalid action",
+			cfg:      config.Protection{Rules: []config.ProtectionRule{{Name: "x", Action: "block"}}},
+			contains: "invalid action",
+		},
+		{
+			name:     "invalid pattern",
+			cfg:      config.Protection{Rules: []config.ProtectionRule{{Names: []string{"["}, Action: "deny"}}},
+			contains: "invalid pattern",
+		},
+		{
+			name:     "invalid confirm mode",
+			cfg:      config.Protection{Confirm: "maybe"},
+			contains: "invalid confirmation mode",
+		},
+		{
+			name:     "readonly identity without user",
+			cfg:      config.Protection{Readonly: []config.ReadonlyIdentity{{Groups: []string{"view"}}}},
+			contains: "has no user",
+		},
+		{
+			name:     "invalid readonly contexts regex",
+			cfg:      config.Protection{Readonly: []config.ReadonlyIdentity{{Contexts: "[", User: "viewer"}}},
+			contains: "failed to compile readonly contexts regex",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewEngine(config.Config{Protection: tt.cfg}, &state.Manager{})
+			if err == nil || !strings.Contains(err.Error(), tt.contains) {
+				t.Errorf("NewEngine() error = %v, want error containing %q", err, tt.contains)
+			}
+		})
+	}
+}
+
+func TestEngine_ReadonlyIdentity(t *testing.T) {
+	e, err := NewEngine(config.Config{Protection: config.Protection{Readonly: []config.ReadonlyIdentity{
+		{Contexts: "^prod-", User: "prod-viewer"},
+		{User: "viewer", Groups: []string{"view"}},
+	}}}, &state.Manager{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if identity, ok := e.ReadonlyIdentity("prod-eu"); !ok || identity.User != "prod-viewer" {
+		t.Errorf("ReadonlyIdentity(prod-eu) = %+v, %v, want the first matching identity", identity, ok)
+	}
+	if identity, ok := e.ReadonlyIdentity("dev"); !ok || identity.User != "viewer" {
+		t.Errorf("ReadonlyIdentity(dev) = %+v, %v, want the identity for all contexts", identity, ok)
+	}
+
+	e, _ = NewEngine(config.Config{}, &state.Manager{})
+	if _, ok := e.ReadonlyIdentity("dev"); ok {
+		t.Error("ReadonlyIdentity() should find nothing without configured identities")
+	}
+}
+
+func TestEngine_Evaluate(t *testing.T) {
+	all := ""
+	cfg := config.Config{
+		Protection: config.Protection{
+			Regex:    &all,
+			Commands: []string{"delete", "apply"},
+			Prompt:   true,
+			Rules: []config.ProtectionRule{
+				{Name: "no-bulk-delete", Verbs: []string{"delete"}, Flags: []string{"--all", "-A", "--grace-period=0"}, Action: "deny"},
+				{Name: "no-namespace-delete", Verbs: []string{"delete"}, Resources: []string{"ns"}, Action: "deny"},
+				{Name: "system", Namespaces: []string{"kube-*"}, Action: "deny"},
+				{Name: "scratch-pods", Verbs: []string{"delete"}, Resources: []string{"pod"}, Names: []string{"scratch-*"}, Action: "allow"},
+				{Name: "preview-deployments", Verbs: []string{"apply", "patch"}, Resources: []string{"deploy"}, Action: "preview"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		args      []string
+		namespace string
+		allNs     bool
+		opaque    bool
+		action    Action
+		rule      string
+	}{
+		{name: "rule allows", args: []string{"delete", "pod", "scratch-1"}, action: ActionAllow, rule: "scratch-pods"},
+		{name: "fallback prompts", args: []string{"delete", "pod", "web-0"}, action: ActionPrompt},
+		{name: "rule previews", args: []string{"patch", "deployment", "web", "-p", "{}"}, action: ActionPreview, rule: "preview-deployments"},
+		{name: "not a protected command", args: []string{"get", "pods"}, action: ActionAllow},
+		{name: "resource alias", args: []string{"delete", "namespaces", "payments"}, action: ActionDeny, rule: "no-namespace-delete"},
+		{name: "dangerous flag", args: []string{"delete", "pods", "--all"}, action: ActionDeny, rule: "no-bulk-delete"},
+		{name: "dangerous flag disabled", args: []string{"delete", "pods", "x", "--all=false"}, action: ActionPrompt},
+		{name: "dangerous flag disabled with 0", args: []string{"delete", "pods", "x", "--all=0"}, action: ActionPrompt},
+		{name: "value flag before name", args: []string{"delete", "pod", "--chunk-size", "500", "scratch-1"}, action: ActionAllow, rule: "scratch-pods"},
+		{name: "flag value", args: []string{"delete", "pod", "scratch-1", "--grace-period=0"}, action: ActionDeny, rule: "no-bulk-delete"},
+		{name: "shorthand of long flag", args: []string{"delete", "pods", "--all-namespaces", "-l", "app=x"}, action: ActionDeny, rule: "no-bulk-delete"},
+		{name: "namespace pattern", args: []string{"get", "pods"}, namespace: "kube-system", action: ActionDeny, rule: "system"},
+		{name: "all namespaces hits namespace rule", args: []string{"get", "pods"}, allNs: true, action: ActionDeny, rule: "system"},
+		{name: "opaque command skips inspecting rules", args: nil, opaque: true, action: ActionPrompt},
+		{name: "opaque command hits namespace rule", args: nil, opaque: true, namespace: "kube-public", action: ActionDeny, rule: "system"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewEngine(cfg, &state.Manager{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			namespace := tt.namespace
+			if namespace == "" {
+				namespace = "default"
+			}
+			decision, err := e.Evaluate(Request{
+				Context:       "prod",
+				Namespace:     namespace,
+				AllNamespaces: tt.allNs,
+				Command:       kubectl.Parse(tt.args),
+				Opaque:        tt.opaque,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.Action != tt.action || decision.Rule != tt.rule {
+				t.Errorf("Evaluate() = (%s, %q), want (%s, %q): %s", decision.Action, decision.Rule, tt.action, tt.rule, decision.Reason)
+			}
+		})
+	}
+
+	t.Run("unprotected context", func(t *testing.T) {
+		e, _ := NewEngine(config.Config{Protection: config.Protection{Commands: []string{"delete"}}}, &state.Manager{})
+		decision, _ := e.Evaluate(Request{Context: "dev", Command: kubectl.Parse([]string{"delete", "ns", "x"})})
+		if decision.Action != ActionAllow {
+			t.Errorf("Evaluate() = %s, want allow", decision.Action)
+		}
+	})
+
+	t.Run("regex with contexts not in state", func(t *testing.T) {
+		prodRegex := "^prod.*"
+		e, err := NewEngine(config.Config{Protection: config.Protection{Regex: &prodRegex, Commands: []string{"delete"}, Prompt: true}}, newTestStateManager(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		command := kubectl.Parse([]string{"delete", "pod", "web-0"})
+
+		decision, err := e.Evaluate(Request{Context: "prod-cluster", Namespace: "default", Command: command})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decision.Action != ActionPrompt {
+			t.Errorf("Evaluate(prod-cluster) = %s, want prompt", decision.Action)
+		}
+		decision, err = e.Evaluate(Request{Context: "dev-cluster", Namespace: "default", Command: command})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decision.Action != ActionAllow {
+			t.Errorf("Evaluate(dev-cluster) = %s, want allow", decision.Action)
+		}
+	})
+
+	t.Run("no prompt denies", func(t *testing.T) {
+		noPrompt := cfg
+		noPrompt.Protection.Prompt = false
+		e, _ := NewEngine(noPrompt, &state.Manager{})
+		decision, _ := e.Evaluate(Request{Context: "prod", Namespace: "default", Command: kubectl.Parse([]string{"apply", "-f", "."})})
+		if decision.Action != ActionDeny {
+			t.Errorf("Evaluate() = %s, want deny", decision.Action)
+		}
+	})
+}
+
+func TestIsCommandProtected(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		blockedCmds []string
+		expected    bool
+	}{
+		{
+			name:        "command in blocked list",
+			args:        []string{"apply", "-f", "deployment.yaml"},
+			blockedCmds: []string{"apply", "delete", "edit"},
+			expected:    true,
+		},
+		{
+			name:        "command not in blocked list",
+			args:        []string{"get", "pods"},
+			blockedCmds: []string{"apply", "delete", "edit"},
+			expected:    false,
+		},
+		{
+			name:        "empty args",
+			args:        []string{},
+			blockedCmds: []string{"apply", "delete"},
+			expected:    false,
+		},
+		{
+			name:        "empty blocked list",
+			args:        []string{"apply"},
+			blockedCmds: []string{},
+			expected:    false,
+		},
+		{
+			name:        "case sensitive match",
+			args:        []string{"Apply"},
+			blockedCmds: []string{"apply"},
+			expected:    false,
+		},
+		{
+			name:        "namespace flag before command",
+			args:        []string{"-n", "prod", "delete", "pod", "x"},
+			blockedCmds: []string{"delete"},
+			expected:    true,
+		},
+		{
+			name:        "context flag before command",
+			args:        []string{"--context", "foo", "apply", "-f", "."},
+			blockedCmds: []string{"apply"},
+			expected:    true,
+		},
+		{
+			name:        "separator before command",
+			args:        []string{"--", "delete", "pod", "x"},
+			blockedCmds: []string{"delete"},
+			expected:    true,
+		},
+		{
+			name:        "flag value matching a blocked command",
+			args:        []string{"get", "pods", "-l", "delete"},
+			blockedCmds: []string{"delete"},
+			expected:    false,
+		},
+		{
+			name:        "verb and subverb",
+			args:        []string{"rollout", "restart", "deploy/web"},
+			blockedCmds: []string{"rollout restart"},
+			expected:    true,
+		},
+		{
+			name:        "verb with other subverb",
+			args:        []string{"rollout", "status", "deploy/web"},
+			blockedCmds: []string{"rollout restart"},
+			expected:    false,
+		},
+		{
+			name:        "plugin",
+			args:        []string{"--context", "prod", "cnpg", "destroy", "db"},
+			blockedCmds: []string{"cnpg"},
+			expected:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := settings{commands: tt.blockedCmds}
+			result := s.isCommandProtected(kubectl.Parse(tt.args))
+			if result != tt.expected {
+				t.Errorf("isCommandProtected(%v, %v) = %v, want %v",
+					tt.args, tt.blockedCmds, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEngine_Profiles(t *testing.T) {
+	noPrompt := false
+	cfg := config.Config{
+		Protection: config.Protection{
+			Commands: []string{"delete"},
+			Prompt:   true,
+			Profiles: map[string]config.ProtectionProfile{
+				"strict":   {Prompt: &noPrompt},
+				"readonly": {Commands: []string{"apply", "create", "delete", "edit", "patch"}, Prompt: &noPrompt},
+				"careful": {Rules: []config.ProtectionRule{
+					{Name: "pods", Verbs: []string{"delete"}, Resources: []string{"pod"}, Action: "allow"},
+				}},
+			},
+			ContextProfiles: []config.ContextProfile{
+				{Regex: "^prod", Profile: "strict"},
+				{Regex: "^staging", Profile: "careful"},
+			},
+		},
+	}
+
+	t.Run("context profile protects and applies settings", func(t *testing.T) {
+		e, err := NewEngine(cfg, newTestStateManager(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decision, _ := e.Evaluate(Request{Context: "prod-eu", Command: kubectl.Parse([]string{"delete", "pod", "x"})})
+		if !decision.Status.Protected || decision.Status.Source != SourceProfile || decision.Status.Profile != "strict" {
+			t.Errorf("Status = %+v, want protected by profile strict", decision.Status)
+		}
+		if decision.Action != ActionDeny {
+			t.Errorf("Action = %s, want deny", decision.Action)
+		}
+
+		decision, _ = e.Evaluate(Request{Context: "staging-eu", Command: kubectl.Parse([]string{"delete", "pod", "x"})})
+		if decision.Action != ActionAllow || decision.Rule != "pods" {
+			t.Errorf("Evaluate() = (%s, %q), want allow by rule pods", decision.Action, decision.Rule)
+		}
+
+		decision, _ = e.Evaluate(Request{Context: "staging-eu", Command: kubectl.Parse([]string{"delete", "svc", "x"})})
+		if decision.Action != ActionPrompt {
+			t.Errorf("Action = %s, want prompt inherited from top-level settings", decision.Action)
+		}
+
+		decision, _ = e.Evaluate(Request{Context: "dev", Command: kubectl.Parse([]string{"delete", "pod", "x"})})
+		if decision.Status.Protected || decision.Action != ActionAllow {
+			t.Errorf("Evaluate() = %+v, want unprotected", decision)
+		}
+	})
+
+	t.Run("explicit profile wins over context profile", func(t *testing.T) {
+		sm := newTestStateManager(t)
+		_ = sm.EnsureContextExists("prod-eu")
+		_ = sm.SetContextProtection("prod-eu", true)
+		_ = sm.SetContextProfile("prod-eu", "readonly")
+
+		e, _ := NewEngine(cfg, sm)
+		decision, _ := e.Evaluate(Request{Context: "prod-eu", Command: kubectl.Parse([]string{"apply", "-f", "."})})
+		if decision.Status.Source != SourceExplicit || decision.Status.Profile != "readonly" || decision.Action != ActionDeny {
+			t.Errorf("Evaluate() = %+v, want deny by readonly profile", decision)
+		}
+	})
+
+	t.Run("profile names are case-insensitive", func(t *testing.T) {
+		sm := newTestStateManager(t)
+		_ = sm.EnsureContextExists("dev")
+		_ = sm.SetContextProtection("dev", true)
+		_ = sm.SetContextProfile("dev", "ReadOnly")
+
+		mixedCase := cfg
+		mixedCase.Protection.ContextProfiles = []config.ContextProfile{{Regex: "^prod", Profile: "Strict"}}
+		e, err := NewEngine(mixedCase, sm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !e.HasProfile("Strict") {
+			t.Error("HasProfile(Strict) = false, want the strict profile")
+		}
+
+		decision, _ := e.Evaluate(Request{Context: "dev", Command: kubectl.Parse([]string{"apply", "-f", "."})})
+		if decision.Status.Profile != "readonly" || decision.Action != ActionDeny {
+			t.Errorf("Evaluate() = %+v, want deny by readonly profile", decision)
+		}
+		decision, _ = e.Evaluate(Request{Context: "prod-eu", Command: kubectl.Parse([]string{"delete", "pod", "x"})})
+		if decision.Status.Profile != "strict" || decision.Action != ActionDeny {
+			t.Errorf("Evaluate() = %+v, want deny by strict profile", decision)
+		}
+	})
+
+	t.Run("unknown profile in state", func(t *testing.T) {
+		sm := newTestStateManager(t)
+		_ = sm.EnsureContextExists("dev")
+		_ = sm.SetContextProtection("dev", true)
+		_ = sm.SetContextProfile("dev", "removed")
+
+		e, _ := NewEngine(cfg, sm)
+		if _, err := e.Status("dev"); err == nil {
+			t.Error("Expected error for unknown profile")
+		}
+	})
+
+	t.Run("context profile with unknown profile", func(t *testing.T) {
+		bad := cfg
+		bad.Protection.ContextProfiles = []config.ContextProfile{{Regex: ".*", Profile: "missing"}}
+		if _, err := NewEngine(bad, &state.Manager{}); err == nil {
+			t.Error("Expected error for context profile referring to unknown profile")
+		}
+	})
+
+	t.Run("confirm mode", func(t *testing.T) {
+		withConfirm := cfg
+		withConfirm.Protection.Confirm = "context"
+		withConfirm.Protection.Profiles = map[string]config.ProtectionProfile{
+			"strict":   {Confirm: "challenge"},
+			"readonly": {},
+			"careful":  {},
+		}
+
+		e, err := NewEngine(withConfirm, newTestStateManager(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decision, _ := e.Evaluate(Request{Context: "prod-eu", Command: kubectl.Parse([]string{"delete", "pod", "x"})})
+		if decision.Confirm != prompt.ModeChallenge {
+			t.Errorf("Confirm = %s, want challenge from profile", decision.Confirm)
+		}
+
+		decision, _ = e.Evaluate(Request{Context: "staging-eu", Command: kubectl.Parse([]string{"delete", "pod", "x"})})
+		if decision.Confirm != prompt.ModeContext {
+			t.Errorf("Confirm = %s, want context inherited from top-level settings", decision.Confirm)
+		}
+	})
+}
+
+func TestEngine_NamespaceProtection(t *testing.T) {
+	nsRegex := "^(payments|kube-system)$"
+	prodRegex := "^prod"
+	cfg := config.Config{Protection: config.Protection{
+		Regex:          &prodRegex,
+		NamespaceRegex: &nsRegex,
+		Commands:       []string{"delete"},
+		Prompt:         true,
+	}}
+	deletePod := kubectl.Parse([]string{"delete", "pod", "x"})
+
+	sm := newTestStateManager(t)
+	_ = sm.EnsureContextExists("shared")
+	_ = sm.SetNamespaceProtection("shared", "orders", true)
+	_ = sm.SetNamespaceProtection("shared", "payments", false)
+	_ = sm.EnsureContextExists("lifted")
+	_ = sm.LiftContextProtection("lifted", time.Now().Add(time.Hour), state.LiftInfo{Reason: "migration"})
+
+	tests := []struct {
+		name      string
+		context   string
+		namespace string
+		allNs     bool
+		command   []string
+		action    Action
+		source    Source
+		protected string
+	}{
+		{name: "namespace regex", context: "dev", namespace: "kube-system", action: ActionPrompt, source: SourceNamespace, protected: "kube-system"},
+		{name: "unprotected namespace", context: "dev", namespace: "scratch", action: ActionAllow, source: SourceRegex},
+		{name: "explicit namespace", context: "shared", namespace: "orders", action: ActionPrompt, source: SourceNamespace, protected: "orders"},
+		{name: "explicit override wins over namespace regex", context: "shared", namespace: "payments", action: ActionAllow, source: SourceRegex},
+		{name: "all namespaces", context: "dev", allNs: true, action: ActionPrompt, source: SourceNamespace, protected: AllNamespaces},
+		{name: "protected context", context: "prod-eu", namespace: "scratch", action: ActionPrompt, source: SourceRegex},
+		{name: "lift applies to namespaces", context: "lifted", namespace: "payments", action: ActionAllow, source: SourceLift},
+		{name: "delete protected namespace", context: "dev", namespace: "default", command: []string{"delete", "namespace", "payments"}, action: ActionPrompt, source: SourceNamespace, protected: "payments"},
+		{name: "delete protected namespace by type/name", context: "dev", namespace: "default", command: []string{"delete", "ns/payments"}, action: ActionPrompt, source: SourceNamespace, protected: "payments"},
+		{name: "delete unprotected namespace", context: "dev", namespace: "default", command: []string{"delete", "namespaces", "scratch"}, action: ActionAllow, source: SourceRegex},
+		{name: "pod named like a protected namespace", context: "dev", namespace: "default", command: []string{"delete", "pod", "payments"}, action: ActionAllow, source: SourceRegex},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewEngine(cfg, sm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			command := deletePod
+			if tt.command != nil {
+				command = kubectl.Parse(tt.command)
+			}
+			decision, _ := e.Evaluate(Request{Context: tt.context, Namespace: tt.namespace, AllNamespaces: tt.allNs, Command: command})
+			if decision.Action != tt.action || decision.Status.Source != tt.source || decision.Status.Namespace != tt.protected {
+				t.Errorf("Evaluate() = (%s, %s, %q), want (%s, %s, %q)", decision.Action, decision.Status.Source, decision.Status.Namespace, tt.action, tt.source, tt.protected)
+			}
+		})
+	}
+
+	t.Run("namespace status", func(t *testing.T) {
+		e, _ := NewEngine(cfg, sm)
+		if protected, source := e.NamespaceStatus("shared", "orders"); !protected || source != SourceExplicit {
+			t.Errorf("NamespaceStatus(orders) = (%v, %s), want explicitly protected", protected, source)
+		}
+		if protected, source := e.NamespaceStatus("dev", "payments"); !protected || source != SourceRegex {
+			t.Errorf("NamespaceStatus(payments) = (%v, %s), want protected by regex", protected, source)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package protection
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/idebeijer/kubert/internal/config"
+	"github.com/idebeijer/kubert/internal/kubectl"
+)
+
+type rule struct {
+	name       string
+	verbs      []string
+	resources  []string
+	namespaces []string
+	names      []string
+	flags      []flagMatcher
+	action     Action
+}
+
+type flagMatcher struct {
+	name     string
+	value    string
+	hasValue bool
+}
+
+func compileRule(index int, r config.ProtectionRule) (rule, error) {
+	compiled := rule{
+		name:       r.Name,
+		verbs:      r.Verbs,
+		namespaces: r.Namespaces,
+		names:      r.Names,
+		action:     Action(r.Action),
+	}
+	if compiled.name == "" {
+		compiled.name = fmt.Sprintf("rules[%d]", index)
+	}
+
+	switch compiled.action {
+	case ActionAllow, ActionPrompt, ActionPreview, ActionDeny:
+	default:
+		return rule{}, fmt.Errorf("protection rule %q: invalid action %q, must be one of allow, prompt, preview or deny", compiled.name, r.Action)
+	}
+
+	for _, resource := range r.Resources {
+		compiled.resources = append(compiled.resources, kubectl.CanonicalResource(resource))
+	}
+
+	for _, pattern := range slices.Concat(r.Namespaces, r.Names) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return rule{}, fmt.Errorf("protection rule %q: invalid pattern %q: %w", compiled.name, pattern, err)
+		}
+	}
+
+	for _, flag := range r.Flags {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(flag, "-"), "=")
+		if name == "" {
+			return rule{}, fmt.Errorf("protection rule %q: invalid flag %q", compiled.name, flag)
+		}
+		compiled.flags = append(compiled.flags, flagMatcher{name: kubectl.LongFlag(name), value: value, hasValue: hasValue})
+	}
+
+	return compiled, nil
+}
+
+// inspectsCommand reports whether the rule looks at the kubectl command itself, which
+// opaque commands cannot satisfy.
+func (r rule) inspectsCommand() bool {
+	return len(r.verbs) > 0 || len(r.resources) > 0 || len(r.names) > 0 || len(r.flags) > 0
+}
+
+func (r rule) matches(req Request) bool {
+	if req.Opaque && r.inspectsCommand() {
+		return false
+	}
+
+	if len(r.verbs) > 0 && !slices.ContainsFunc(r.verbs, req.Command.MatchesVerb) {
+		return false
+	}
+
+	if len(r.resources) > 0 && !slices.ContainsFunc(req.Command.Resources, func(resource string) bool {
+		return slices.Contains(r.resources, kubectl.CanonicalResource(resource))
+	}) {
+		return false
+	}
+
+	// A command across all namespaces touches every namespace a rule could name.
+	if len(r.namespaces) > 0 && !req.AllNamespaces && !matchesAny(r.namespaces, req.Namespace) {
+		return false
+	}
+
+	if len(r.names) > 0 && !slices.ContainsFunc(req.Command.Names, func(name string) bool {
+		return matchesAny(r.names, name)
+	}) {
+		return false
+	}
+
+	if len(r.flags) > 0 && !slices.ContainsFunc(r.flags, func(f flagMatcher) bool {
+		return f.matches(req.Command)
+	}) {
+		return false
+	}
+
+	return true
+}
+
+func (f flagMatcher) matches(command kubectl.Command) bool {
+	value, ok := command.Flag(f.name)
+	if !ok {
+		return false
+	}
+	if f.hasValue {
+		return value == f.value
+	}
+	return kubectl.IsTrue(value)
+}
+
+func matchesAny(patterns []string, value string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		matched, _ := path.Match(pattern, value)
+		return matched
+	})
+}
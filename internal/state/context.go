@@ -17,6 +17,24 @@ type ContextInfo struct {
 	LastNamespace  string     `json:"last_namespace"`
 	Protected      *bool      `json:"protected,omitempty"`
 	ProtectedUntil *time.Time `json:"protected_until,omitempty"`
+	// Lift describes the active lift, set together with ProtectedUntil.
+	Lift *LiftInfo `json:"lift,omitempty"`
+	// Profile is the protection profile explicitly assigned to the context.
+	Profile string `json:"profile,omitempty"`
+	// Namespaces are explicit protection overrides for namespaces of the context.
+	Namespaces map[string]bool `json:"namespaces,omitempty"`
+}
+
+// LiftInfo describes why, by whom and for what protection of a context was lifted.
+type LiftInfo struct {
+	Reason string `json:"reason,omitempty"`
+	// Commands limits the lift to these kubectl commands, empty lifts protection for all commands.
+	Commands []string `json:"commands,omitempty"`
+	// Session limits the lift to the kubert shell with this shell kubeconfig, empty lifts
+	// protection for every shell.
+	Session string    `json:"session,omitempty"`
+	By      string    `json:"by,omitempty"`
+	At      time.Time `json:"at"`
 }
 
 func (m *Manager) ContextInfo(context string) (ContextInfo, bool) {
@@ -34,6 +52,16 @@ func (m *Manager) SetContextInfo(context string, info ContextInfo) error {
 	})
 }
 
+// UpdateContexts calls fn with the state of all contexts and saves the changes it makes at once.
+func (m *Manager) UpdateContexts(fn func(contexts map[string]ContextInfo) error) error {
+	return m.withLock(func() error {
+		if err := fn(m.state.Contexts); err != nil {
+			return err
+		}
+		return m.saveState()
+	})
+}
+
 func (m *Manager) RemoveContext(context string) error {
 	return m.withLock(func() error {
 		delete(m.state.Contexts, context)
@@ -76,6 +104,51 @@ func (m *Manager) SetContextProtection(context string, locked bool) error {
 	})
 }
 
+// SetContextProfile assigns a protection profile to the context, an empty profile clears it.
+func (m *Manager) SetContextProfile(context, profile string) error {
+	return m.withLock(func() error {
+		info, exists := m.state.Contexts[context]
+		if !exists {
+			return &ContextNotFoundError{Context: context}
+		}
+		info.Profile = profile
+		m.state.Contexts[context] = info
+		return m.saveState()
+	})
+}
+
+// SetNamespaceProtection sets an explicit protection override for a namespace of the context.
+func (m *Manager) SetNamespaceProtection(context, namespace string, protected bool) error {
+	return m.withLock(func() error {
+		info, exists := m.state.Contexts[context]
+		if !exists {
+			return &ContextNotFoundError{Context: context}
+		}
+		if info.Namespaces == nil {
+			info.Namespaces = make(map[string]bool)
+		}
+		info.Namespaces[namespace] = protected
+		m.state.Contexts[context] = info
+		return m.saveState()
+	})
+}
+
+// DeleteNamespaceProtection removes the explicit protection override for a namespace of the context.
+func (m *Manager) DeleteNamespaceProtection(context, namespace string) error {
+	return m.withLock(func() error {
+		info, exists := m.state.Contexts[context]
+		if !exists {
+			return &ContextNotFoundError{Context: context}
+		}
+		delete(info.Namespaces, namespace)
+		if len(info.Namespaces) == 0 {
+			info.Namespaces = nil
+		}
+		m.state.Contexts[context] = info
+		return m.saveState()
+	})
+}
+
 func (m *Manager) DeleteContextProtection(context string) error {
 	return m.withLock(func() error {
 		info, exists := m.state.Contexts[context]
@@ -83,6 +156,7 @@ func (m *Manager) DeleteContextProtection(context string) error {
 			return &ContextNotFoundError{Context: context}
 		}
 		info.Protected = nil
+		info.Profile = ""
 		m.state.Contexts[context] = info
 		return m.saveState()
 	})
@@ -113,19 +187,20 @@ func (m *Manager) IsContextProtected(context string) (bool, error) {
 }
 
 // LiftContextProtection temporarily lifts protection for the given context until the specified time
-func (m *Manager) LiftContextProtection(context string, until time.Time) error {
+func (m *Manager) LiftContextProtection(context string, until time.Time, lift LiftInfo) error {
 	return m.withLock(func() error {
 		info, exists := m.state.Contexts[context]
 		if !exists {
 			return &ContextNotFoundError{Context: context}
 		}
 		info.ProtectedUntil = &until
+		info.Lift = &lift
 		m.state.Contexts[context] = info
 		return m.saveState()
 	})
 }
 
-// ClearProtectedUntil clears the ProtectedUntil field for a context
+// ClearProtectedUntil clears the ProtectedUntil and Lift fields for a context
 func (m *Manager) ClearProtectedUntil(context string) error {
 	return m.withLock(func() error {
 		info, exists := m.state.Contexts[context]
@@ -133,6 +208,7 @@ func (m *Manager) ClearProtectedUntil(context string) error {
 			return &ContextNotFoundError{Context: context}
 		}
 		info.ProtectedUntil = nil
+		info.Lift = nil
 		m.state.Contexts[context] = info
 		return m.saveState()
 	})
@@ -397,7 +397,8 @@ func TestManager_LiftContextProtection(t *testing.T) {
 
 	// Lift protection for 1 hour
 	liftUntil := time.Now().Add(1 * time.Hour)
-	if err := manager.LiftContextProtection(context, liftUntil); err != nil {
+	lift := LiftInfo{Reason: "incident 42", Commands: []string{"delete"}, Session: "/tmp/kubert-1.yaml", By: "alice"}
+	if err := manager.LiftContextProtection(context, liftUntil, lift); err != nil {
 		t.Fatal(err)
 	}
 
@@ -421,6 +422,10 @@ func TestManager_LiftContextProtection(t *testing.T) {
 	if !info.ProtectedUntil.Equal(liftUntil) {
 		t.Errorf("ProtectedUntil = %v, want %v", info.ProtectedUntil, liftUntil)
 	}
+	if info.Lift == nil || info.Lift.Reason != lift.Reason || info.Lift.Session != lift.Session || info.Lift.By != lift.By ||
+		len(info.Lift.Commands) != 1 || info.Lift.Commands[0] != "delete" {
+		t.Errorf("Lift = %+v, want %+v", info.Lift, lift)
+	}
 }
 
 func TestManager_LiftContextProtection_Expired(t *testing.T) {
@@ -440,7 +445,7 @@ func TestManager_LiftContextProtection_Expired(t *testing.T) {
 
 	// Lift protection with an already-expired time
 	expiredTime := time.Now().Add(-1 * time.Hour)
-	if err := manager.LiftContextProtection(context, expiredTime); err != nil {
+	if err := manager.LiftContextProtection(context, expiredTime, LiftInfo{}); err != nil {
 		t.Fatal(err)
 	}
 
@@ -469,7 +474,7 @@ func TestManager_ClearProtectedUntil(t *testing.T) {
 		t.Fatal(err)
 	}
 	liftUntil := time.Now().Add(1 * time.Hour)
-	if err := manager.LiftContextProtection(context, liftUntil); err != nil {
+	if err := manager.LiftContextProtection(context, liftUntil, LiftInfo{}); err != nil {
 		t.Fatal(err)
 	}
 
@@ -498,13 +503,16 @@ func TestManager_ClearProtectedUntil(t *testing.T) {
 	if info.ProtectedUntil != nil {
 		t.Error("ProtectedUntil should be nil after clear")
 	}
+	if info.Lift != nil {
+		t.Error("Lift should be nil after clear")
+	}
 }
 
 func TestManager_LiftContextProtection_NonExistingContext(t *testing.T) {
 	manager, tempDir := setupTestManager(t)
 	defer cleanupTestManager(tempDir)
 
-	err := manager.LiftContextProtection("non-existing", time.Now().Add(1*time.Hour))
+	err := manager.LiftContextProtection("non-existing", time.Now().Add(1*time.Hour), LiftInfo{})
 	if err == nil {
 		t.Error("LiftContextProtection should fail for non-existing context")
 	}
@@ -529,3 +537,78 @@ func TestManager_ClearProtectedUntil_NonExistingContext(t *testing.T) {
 		t.Errorf("Expected ContextNotFoundError, got %T", err)
 	}
 }
+
+func TestManager_SetContextProfile(t *testing.T) {
+	manager, tempDir := setupTestManager(t)
+	defer cleanupTestManager(tempDir)
+
+	if err := manager.SetContextProfile("non-existing", "strict"); err == nil {
+		t.Error("SetContextProfile should fail for non-existing context")
+	}
+
+	if err := manager.EnsureContextExists(testContextName); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.SetContextProtection(testContextName, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.SetContextProfile(testContextName, "strict"); err != nil {
+		t.Fatal(err)
+	}
+
+	info, _ := manager.ContextInfo(testContextName)
+	if info.Profile != "strict" {
+		t.Errorf("Expected profile 'strict', got %q", info.Profile)
+	}
+
+	if err := manager.DeleteContextProtection(testContextName); err != nil {
+		t.Fatal(err)
+	}
+
+	info, _ = manager.ContextInfo(testContextName)
+	if info.Profile != "" || info.Protected != nil {
+		t.Errorf("Expected DeleteContextProtection to clear protection and profile, got %+v", info)
+	}
+}
+
+func TestManager_NamespaceProtection(t *testing.T) {
+	manager, tempDir := setupTestManager(t)
+	defer cleanupTestManager(tempDir)
+
+	if err := manager.SetNamespaceProtection("non-existing", "payments", true); err == nil {
+		t.Error("SetNamespaceProtection should fail for non-existing context")
+	}
+
+	if err := manager.EnsureContextExists(testContextName); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.SetNamespaceProtection(testContextName, "payments", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.SetNamespaceProtection(testContextName, "scratch", false); err != nil {
+		t.Fatal(err)
+	}
+
+	info, _ := manager.ContextInfo(testContextName)
+	if protected, ok := info.Namespaces["payments"]; !ok || !protected {
+		t.Errorf("Expected namespace 'payments' to be protected, got %v", info.Namespaces)
+	}
+	if protected, ok := info.Namespaces["scratch"]; !ok || protected {
+		t.Errorf("Expected namespace 'scratch' to be unprotected, got %v", info.Namespaces)
+	}
+
+	if err := manager.DeleteContextProtection(testContextName); err != nil {
+		t.Fatal(err)
+	}
+	info, _ = manager.ContextInfo(testContextName)
+	if len(info.Namespaces) != 2 {
+		t.Errorf("Expected DeleteContextProtection to keep namespace overrides, got %v", info.Namespaces)
+	}
+
+	_ = manager.DeleteNamespaceProtection(testContextName, "payments")
+	_ = manager.DeleteNamespaceProtection(testContextName, "scratch")
+	info, _ = manager.ContextInfo(testContextName)
+	if info.Namespaces != nil {
+		t.Errorf("Expected no namespace overrides, got %v", info.Namespaces)
+	}
+}
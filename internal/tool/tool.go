@@ -0,0 +1,188 @@
+// Package tool describes command line tools other than kubectl that kubert guards with context
+// protection, such as helm and flux.
+package tool
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/idebeijer/kubert/internal/config"
+)
+
+// builtins are the tools kubert knows without configuration. kustomize is not one of them: it
+// only renders manifests, which reach the cluster through "kubert kubectl apply -k".
+var builtins = map[string]config.ProtectionTool{
+	"helm": {
+		Commands:    []string{"install", "upgrade", "uninstall", "delete", "rollback"},
+		ContextFlag: "--kube-context",
+		ValueFlags: []string{
+			"--burst-limit", "--description", "--filter", "--history-max", "--kube-apiserver",
+			"--kube-as-group", "--kube-as-user", "--kube-ca-file", "--kube-tls-server-name",
+			"--kube-token", "--max", "--offset", "--output", "--password", "--post-renderer",
+			"--qps", "--registry-config", "--repo", "--repository-cache", "--repository-config",
+			"--selector", "--set", "--set-file", "--set-json", "--set-literal", "--set-string",
+			"--timeout", "--username", "--values", "--version", "-f", "-l", "-o",
+		},
+	},
+	"flux": {
+		Commands: []string{"bootstrap", "install", "uninstall", "create", "delete", "reconcile", "resume", "suspend"},
+		ValueFlags: []string{
+			"--as", "--cluster", "--components", "--export", "--interval", "--label", "--output",
+			"--path", "--source", "--timeout", "--user", "-o",
+		},
+	},
+	"argocd": {
+		Commands: []string{
+			"app create", "app delete", "app edit", "app patch", "app rollback", "app set",
+			"app sync", "app terminate-op", "app unset", "appset create", "appset delete",
+			"cluster rm", "proj delete", "repo rm",
+		},
+		ContextFlag: "--kube-context",
+		ValueFlags: []string{
+			"--app-namespace", "--argocd-context", "--auth-token", "--config", "--header",
+			"--output", "--port-forward-namespace", "--resource", "--revision", "--selector",
+			"--server", "--timeout", "-N", "-l", "-o",
+		},
+	},
+}
+
+// Tool is a configured or built-in tool with its defaults applied.
+type Tool struct {
+	Name               string
+	Binary             string
+	Commands           []string
+	ContextFlag        string
+	KubeconfigFlag     string
+	NamespaceFlags     []string
+	AllNamespacesFlags []string
+	ValueFlags         []string
+}
+
+// Lookup returns the tool with the given name, from the config or the built-in tools.
+func Lookup(cfg config.Config, name string) (Tool, error) {
+	t, ok := cfg.Protection.Tools[name]
+	if !ok {
+		t, ok = builtins[name]
+	}
+	if !ok {
+		return Tool{}, fmt.Errorf("unknown tool %q, add it to protection.tools in the config (known tools: %s)", name, strings.Join(Names(cfg), ", "))
+	}
+
+	tool := Tool{
+		Name:               name,
+		Binary:             t.Binary,
+		Commands:           t.Commands,
+		ContextFlag:        t.ContextFlag,
+		KubeconfigFlag:     t.KubeconfigFlag,
+		NamespaceFlags:     t.NamespaceFlags,
+		AllNamespacesFlags: t.AllNamespacesFlags,
+		ValueFlags:         t.ValueFlags,
+	}
+	if tool.Binary == "" {
+		tool.Binary = name
+	}
+	if tool.ContextFlag == "" {
+		tool.ContextFlag = "--context"
+	}
+	if tool.KubeconfigFlag == "" {
+		tool.KubeconfigFlag = "--kubeconfig"
+	}
+	if len(tool.NamespaceFlags) == 0 {
+		tool.NamespaceFlags = []string{"--namespace", "-n"}
+	}
+	if len(tool.AllNamespacesFlags) == 0 {
+		tool.AllNamespacesFlags = []string{"--all-namespaces", "-A"}
+	}
+	return tool, nil
+}
+
+// Names returns the names of the configured and built-in tools, sorted.
+func Names(cfg config.Config) []string {
+	names := make([]string, 0, len(builtins)+len(cfg.Protection.Tools))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	for name := range cfg.Protection.Tools {
+		if _, ok := builtins[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Invocation is a tool invocation broken down into the parts that matter for protection.
+type Invocation struct {
+	// Command is the protected command the invocation runs, empty if it runs none.
+	Command string
+
+	Context       string
+	Kubeconfig    string
+	Namespace     string
+	AllNamespaces bool
+}
+
+// Parse breaks a tool argument list (without the binary) down into an Invocation. Flags given
+// without "=" consume the next argument if they are known to take a value; other flags are
+// assumed to be boolean.
+func (t Tool) Parse(args []string) Invocation {
+	var inv Invocation
+	var positionals []string
+
+	valueFlags := slices.Concat([]string{t.ContextFlag, t.KubeconfigFlag}, t.NamespaceFlags, t.ValueFlags)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		if len(arg) < 2 || !strings.HasPrefix(arg, "-") {
+			positionals = append(positionals, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !hasValue && !strings.HasPrefix(arg, "--") && len(arg) > 2 && slices.Contains(valueFlags, arg[:2]) {
+			// A shorthand with its value attached, e.g. "-nprod".
+			name, value, hasValue = arg[:2], arg[2:], true
+		}
+		takesValue := slices.Contains(valueFlags, name)
+		if takesValue && !hasValue && i+1 < len(args) {
+			i++
+			value, hasValue = args[i], true
+		}
+
+		switch {
+		case name == t.ContextFlag:
+			inv.Context = value
+		case name == t.KubeconfigFlag:
+			inv.Kubeconfig = value
+		case slices.Contains(t.NamespaceFlags, name):
+			inv.Namespace = value
+		case slices.Contains(t.AllNamespacesFlags, name):
+			inv.AllNamespaces = !hasValue || (value != "false" && value != "0")
+		}
+	}
+
+	inv.Command = t.protectedCommand(positionals)
+	return inv
+}
+
+// protectedCommand returns the first protected command whose words appear next to each other
+// in the positional arguments. They are not required to come first, so the value of a flag
+// that is not known to take a value cannot hide a protected command.
+func (t Tool) protectedCommand(positionals []string) string {
+	for _, command := range t.Commands {
+		words := strings.Fields(command)
+		if len(words) == 0 {
+			continue
+		}
+		for i := 0; i+len(words) <= len(positionals); i++ {
+			if slices.Equal(positionals[i:i+len(words)], words) {
+				return command
+			}
+		}
+	}
+	return ""
+}
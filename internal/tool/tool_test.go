@@ -0,0 +1,98 @@
+package tool
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/idebeijer/kubert/internal/config"
+)
+
+func TestLookup(t *testing.T) {
+	cfg := config.Config{Protection: config.Protection{Tools: map[string]config.ProtectionTool{
+		"helm":  {Binary: "helm3", Commands: []string{"upgrade"}},
+		"tanka": {Commands: []string{"apply", "delete"}},
+	}}}
+
+	helm, err := Lookup(cfg, "helm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if helm.Binary != "helm3" || !slices.Equal(helm.Commands, []string{"upgrade"}) || helm.ContextFlag != "--context" {
+		t.Errorf("Lookup(helm) = %+v, want the configured tool with defaults", helm)
+	}
+
+	flux, err := Lookup(cfg, "flux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flux.Binary != "flux" || flux.KubeconfigFlag != "--kubeconfig" || !slices.Equal(flux.NamespaceFlags, []string{"--namespace", "-n"}) {
+		t.Errorf("Lookup(flux) = %+v, want the built-in tool with defaults", flux)
+	}
+
+	if _, err := Lookup(cfg, "terraform"); err == nil || !strings.Contains(err.Error(), "tanka") {
+		t.Errorf("Lookup(terraform) error = %v, want unknown tool listing known tools", err)
+	}
+
+	if names := Names(cfg); !slices.Equal(names, []string{"argocd", "flux", "helm", "tanka"}) {
+		t.Errorf("Names() = %v", names)
+	}
+}
+
+func TestTool_Parse(t *testing.T) {
+	helm, _ := Lookup(config.Config{}, "helm")
+	argocd, _ := Lookup(config.Config{}, "argocd")
+	flux, _ := Lookup(config.Config{}, "flux")
+
+	tests := []struct {
+		name     string
+		tool     Tool
+		args     []string
+		expected Invocation
+	}{
+		{
+			name:     "helm upgrade",
+			tool:     helm,
+			args:     []string{"--kube-context", "prod", "upgrade", "web", "./chart", "-n", "payments", "-f", "values.yaml"},
+			expected: Invocation{Command: "upgrade", Context: "prod", Namespace: "payments"},
+		},
+		{
+			name:     "helm list",
+			tool:     helm,
+			args:     []string{"list", "-A", "--filter", "upgrade"},
+			expected: Invocation{AllNamespaces: true},
+		},
+		{
+			name:     "inline values",
+			tool:     helm,
+			args:     []string{"uninstall", "web", "--kube-context=staging", "-npayments", "--kubeconfig=/tmp/kc"},
+			expected: Invocation{Command: "uninstall", Context: "staging", Namespace: "payments", Kubeconfig: "/tmp/kc"},
+		},
+		{
+			name:     "unknown value flag does not hide the command",
+			tool:     flux,
+			args:     []string{"--verbose-level", "2", "reconcile", "kustomization", "apps"},
+			expected: Invocation{Command: "reconcile"},
+		},
+		{
+			name:     "nested command",
+			tool:     argocd,
+			args:     []string{"app", "sync", "web", "--kube-context", "prod"},
+			expected: Invocation{Command: "app sync", Context: "prod"},
+		},
+		{
+			name:     "nested command not matched by a single word",
+			tool:     argocd,
+			args:     []string{"app", "get", "sync"},
+			expected: Invocation{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.tool.Parse(tt.args); got != tt.expected {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -15,3 +15,11 @@ func KubeClientConfig() (*api.Config, error) {
 	}
 	return clientConfig, nil
 }
+
+// LoadKubeClientConfig loads the kubeconfig the same way kubectl does: an explicit path (as given
+// with --kubeconfig) is used on its own, otherwise all files listed in KUBECONFIG are merged.
+func LoadKubeClientConfig(explicitPath string) (*api.Config, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = explicitPath
+	return rules.Load()
+}
@@ -0,0 +1,14 @@
+package util
+
+import (
+	"os"
+	"os/user"
+)
+
+// CurrentUser returns the name of the user running kubert, falling back to $USER.
+func CurrentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
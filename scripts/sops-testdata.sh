@@ -0,0 +1,10 @@
+#!/bin/sh
+# Encrypts the sops test fixture with the sops CLI, so the sops support of kubert is tested
+# against files written by sops itself. Run it again when kubeconfig.yaml changes.
+set -e
+dir=internal/kubeconfig/testdata/sops
+recipient=$(sed -n 's/^# public key: //p' "$dir/keys.txt")
+
+sops --encrypt --age "$recipient" --input-type yaml --output-type yaml \
+	"$dir/kubeconfig.yaml" >"$dir/kubeconfig.sops.yaml"
+SOPS_AGE_KEY_FILE="$dir/keys.txt" sops --decrypt "$dir/kubeconfig.sops.yaml" >/dev/null